@@ -12,7 +12,9 @@ import (
 
 	// "github.com/Sumitwarrior7/social/docs"
 	"github.com/Sumitwarrior7/social/internal/auth"
+	"github.com/Sumitwarrior7/social/internal/blob"
 	"github.com/Sumitwarrior7/social/internal/mailer"
+	"github.com/Sumitwarrior7/social/internal/moderation"
 	"github.com/Sumitwarrior7/social/internal/ratelimiter"
 	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/Sumitwarrior7/social/internal/store/cache"
@@ -30,6 +32,13 @@ type application struct {
 	authenticator auth.Authenticator
 	cacheStorage  cache.Storage
 	rateLimiter   ratelimiter.Limiter
+	blobStore     blob.Store
+	mediaQueue    chan int64
+	exportQueue   chan int64
+	timelineQueue chan fanOutJob
+
+	moderation      *moderation.Pipeline
+	moderationRules *moderation.RulesCheck // Kept to drop its cached rules when admins change them
 }
 
 type config struct {
@@ -42,6 +51,94 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	media       mediaConfig
+	blob        blobConfig
+	uploads     uploadsConfig
+	exports     exportsConfig
+	trash       trashConfig
+
+	requireIfMatch bool              // When set, updates without an If-Match header are rejected instead of overwriting blindly
+	cacheControl   map[string]string // Cache-Control policy of successful reads, keyed by route group
+	postMaxLength  map[string]int    // Longest content of a post, keyed by the role of its author
+	moderation     moderationConfig
+	trending       trendingConfig
+	rankedFeed     store.FeedWeights // Scoring of the feed with mode=ranked
+	timeline       timelineConfig
+	publisher      publisherConfig
+}
+
+/* Timeline related configutaions */
+type timelineConfig struct {
+	largeAccountFollowers int // From how many followers posts are merged into timelines on read instead of pushed
+}
+
+/* Trending related configutaions */
+type trendingConfig struct {
+	interval  time.Duration // How often the rankings are recomputed
+	limit     int           // Posts and tags kept per window
+	authorCap int           // At most how many posts of one author count per ranking
+}
+
+/* Content moderation related configutaions */
+type moderationConfig struct {
+	rulesRefresh       time.Duration // How long the rules are cached before they are loaded again
+	duplicateThreshold float64       // Similarity from 0 to 1 at which a post is held as a copy of a recent one
+	duplicateWindow    time.Duration
+	duplicateLookback  int // At most how many recent posts of the author are compared
+}
+
+/* Media related configutaions */
+type mediaConfig struct {
+	maxImageSize      int64
+	maxVideoSize      int64
+	maxImagePixels    int // Rejects decompression bombs
+	maxImageDimension int
+	workers           int
+}
+
+/* Resumable upload related configutaions */
+type uploadsConfig struct {
+	dir             string // Partial uploads are staged here until complete
+	expiry          time.Duration
+	cleanupInterval time.Duration
+	userQuota       int64
+}
+
+/* Data export related configutaions */
+type exportsConfig struct {
+	expiry time.Duration // How long a finished archive can be downloaded
+}
+
+/* Scheduled posts related configutaions */
+type publisherConfig struct {
+	interval time.Duration // How often due scheduled posts are published
+}
+
+/* Trash related configutaions */
+type trashConfig struct {
+	retention     time.Duration // Deleted posts and comments can be restored for this long
+	purgeInterval time.Duration
+}
+
+/* Blob storage related configutaions */
+type blobConfig struct {
+	backend   string // "local" or "s3"
+	urlExpiry time.Duration
+	local     localBlobConfig
+	s3        s3BlobConfig
+}
+type localBlobConfig struct {
+	dir     string
+	baseUrl string
+	secret  string
+}
+type s3BlobConfig struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
 }
 
 /* Redis related configutaions */
@@ -106,9 +203,9 @@ func (app *application) mount() http.Handler {
 	// Basic CORS
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", "If-Modified-Since", "Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset"},
+		ExposedHeaders:   []string{"Link", "Location", "ETag", "Last-Modified", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Expires", "Upload-Length", "Upload-Metadata", "Upload-Offset"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -121,21 +218,39 @@ func (app *application) mount() http.Handler {
 		r.Get("/health", app.healthCheckHandler)
 		r.Get("/all-users", app.getAllUsersHandler)
 		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
+		r.Get("/blobs/*", app.serveBlobHandler) // Authorized by the signature in the url
 
 		// docsUrl := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		// r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsUrl)))
 
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.TokenAuthMiddleware())
+			r.Use(app.CacheControlMiddleware("posts"))
 			r.Post("/", app.createPostHandler)
 			r.Get("/", app.getAllPostsHandler)
 			r.Get("/user/{userId}", app.getAllPostsByUserIdHandler)
+			r.Get("/drafts", app.getDraftsHandler)
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postContextMiddleware)
 				r.Get("/", app.getPostHandler)
 				r.Delete("/", app.CheckPostOwnership("admin", app.deletePostHandler))
 				r.Patch("/", app.CheckPostOwnership("moderator", app.updatePostHandler))
 
+				r.Put("/bookmark", app.bookmarkPostHandler)
+				r.Delete("/bookmark", app.removeBookmarkHandler)
+				r.Put("/repost", app.repostPostHandler)
+				r.Delete("/repost", app.undoRepostHandler)
+				r.Post("/poll/votes", app.votePollHandler)
+				r.Put("/schedule", app.CheckPostOwnership("admin", app.schedulePostHandler))
+				r.Delete("/schedule", app.CheckPostOwnership("admin", app.unschedulePostHandler))
+				r.Post("/publish", app.CheckPostOwnership("admin", app.publishPostHandler))
+
+				r.Route("/revisions", func(r chi.Router) {
+					r.Get("/", app.getPostRevisionsHandler)
+					r.Get("/diff", app.diffPostRevisionsHandler)
+					r.With(app.CacheControlMiddleware("revisions")).Get("/{version}", app.getPostRevisionHandler)
+				})
+
 				r.Route("/comments", func(r chi.Router) {
 					r.Post("/", app.createCommentHandler)
 					r.Route("/{commentId}", func(r chi.Router) {
@@ -148,21 +263,156 @@ func (app *application) mount() http.Handler {
 			})
 		})
 
+		r.Route("/bookmarks", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.Get("/", app.getBookmarksHandler)
+			r.Route("/folders", func(r chi.Router) {
+				r.Get("/", app.getBookmarkFoldersHandler)
+				r.Post("/", app.createBookmarkFolderHandler)
+				r.Delete("/{folderID}", app.deleteBookmarkFolderHandler)
+			})
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.With(app.CacheControlMiddleware("feed")).Get("/{tag}", app.getTagPostsHandler)
+		})
+
+		r.Route("/trending", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.With(app.CacheControlMiddleware("trending")).Get("/", app.getTrendingHandler)
+		})
+
+		r.Route("/trash", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.Get("/", app.getTrashHandler)
+			r.Post("/posts/{postID}/restore", app.restorePostHandler)
+			r.Post("/comments/{commentId}/restore", app.restoreCommentHandler)
+		})
+
+		r.Route("/reports", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.Post("/", app.createReportHandler)
+		})
+
+		r.Route("/moderation", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.Use(app.RequireRoleMiddleware("moderator"))
+			r.Route("/reports", func(r chi.Router) {
+				r.Get("/", app.getReportQueueHandler)
+				r.Route("/{reportID}", func(r chi.Router) {
+					r.Use(app.reportContextMiddleware)
+					r.Get("/", app.getReportHandler)
+					r.Post("/claim", app.claimReportHandler)
+					r.Post("/resolve", app.resolveReportHandler)
+				})
+			})
+			r.Route("/decisions", func(r chi.Router) {
+				r.Get("/", app.getModerationDecisionsHandler)
+				r.Route("/{decisionID}", func(r chi.Router) {
+					r.Use(app.decisionContextMiddleware)
+					r.Get("/", app.getModerationDecisionHandler)
+					r.Post("/approve", app.approveModerationDecisionHandler)
+					r.Post("/reject", app.rejectModerationDecisionHandler)
+				})
+			})
+			r.Route("/rules", func(r chi.Router) {
+				r.Use(app.RequireRoleMiddleware("admin"))
+				r.Get("/", app.getModerationRulesHandler)
+				r.Post("/", app.createModerationRuleHandler)
+				r.Delete("/{ruleID}", app.deleteModerationRuleHandler)
+			})
+		})
+
+		r.Route("/media", func(r chi.Router) {
+			r.Use(app.TokenAuthMiddleware())
+			r.Post("/", app.uploadMediaHandler)
+			r.Route("/{mediaID}", func(r chi.Router) {
+				r.Use(app.mediaContextMiddleware)
+				r.Get("/", app.getMediaHandler)
+				r.Get("/content", app.getMediaContentHandler)
+				r.Patch("/", app.updateMediaHandler)
+				r.Delete("/", app.deleteMediaHandler)
+			})
+		})
+
+		r.Route("/uploads", func(r chi.Router) {
+			r.Options("/", app.uploadOptionsHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.TokenAuthMiddleware())
+				r.Use(app.tusVersionMiddleware)
+				r.Post("/", app.createUploadHandler)
+				r.Route("/{uploadID}", func(r chi.Router) {
+					r.Use(app.uploadContextMiddleware)
+					r.Head("/", app.headUploadHandler)
+					r.Get("/", app.getUploadHandler)
+					r.Patch("/", app.patchUploadHandler)
+					r.Delete("/", app.deleteUploadHandler)
+				})
+			})
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
 
 			r.Route("/{userId}", func(r chi.Router) {
 				r.Use(app.TokenAuthMiddleware())
-				r.Get("/", app.getUserHandler)
+				r.With(app.CacheControlMiddleware("users")).Get("/", app.getUserHandler)
 
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Put("/unblock", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Put("/unmute", app.unmuteUserHandler)
+				r.Put("/close-friend", app.addCloseFriendHandler)
+				r.Delete("/close-friend", app.removeCloseFriendHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.profileContextMiddleware)
+					r.Get("/followers", app.getFollowersHandler)
+					r.Get("/following", app.getFollowingHandler)
+					r.Get("/mutuals", app.getMutualsHandler)
+					r.Get("/relationship", app.getRelationshipHandler)
+					r.Get("/mentions", app.getUserMentionsHandler)
+				})
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.TokenAuthMiddleware())
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.CacheControlMiddleware("feed")).Get("/feed", app.getUserFeedHandler)
 				r.Get("/current-user", app.getCurrentUserHandler)
+
+				r.Route("/me", func(r chi.Router) {
+					r.Patch("/", app.updateUserProfileHandler)
+
+					r.Route("/notifications", func(r chi.Router) {
+						r.Get("/", app.getNotificationsHandler)
+						r.Post("/read", app.readAllNotificationsHandler)
+						r.Post("/{notificationID}/read", app.readNotificationHandler)
+					})
+
+					r.Get("/blocks", app.getBlockedUsersHandler)
+					r.Get("/mutes", app.getMutedUsersHandler)
+					r.Get("/close-friends", app.getCloseFriendsHandler)
+					r.Route("/muted-words", func(r chi.Router) {
+						r.Get("/", app.getMutedWordsHandler)
+						r.Post("/", app.addMutedWordHandler)
+						r.Delete("/{wordID}", app.deleteMutedWordHandler)
+					})
+
+					r.Route("/follow-requests", func(r chi.Router) {
+						r.Get("/", app.getFollowRequestsHandler)
+						r.Post("/{userId}/approve", app.approveFollowRequestHandler)
+						r.Post("/{userId}/reject", app.rejectFollowRequestHandler)
+					})
+
+					r.Route("/export", func(r chi.Router) {
+						r.Post("/", app.requestDataExportHandler)
+						r.Get("/", app.getDataExportsHandler)
+						r.Get("/{exportID}", app.getDataExportHandler)
+					})
+				})
 				r.Get("/followed-users", app.getFollowedUsersHandler)
 				// r.Get("/all-users", app.getAllUsersHandler)
 			})
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Sumitwarrior7/social/internal/blob"
+	"github.com/go-chi/chi/v5"
+)
+
+// Serves blobs for stores whose signed urls point back to the API (e.g. the local filesystem store).
+// Stores that sign urls for the storage provider itself never route downloads through here.
+func (app *application) serveBlobHandler(w http.ResponseWriter, r *http.Request) {
+	verifier, ok := app.blobStore.(blob.URLVerifier)
+	if !ok {
+		app.notFoundError(w, r, blob.ErrNotFound)
+		return
+	}
+
+	key := chi.URLParam(r, "*")
+	if err := verifier.VerifySignedURL(key, r.URL.Query()); err != nil {
+		app.forbidenWarning(w, r)
+		return
+	}
+
+	obj, err := app.blobStore.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer obj.Body.Close()
+
+	// The url stops working once it expires, so caches must not keep the response longer than that
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(app.config.blob.urlExpiry.Seconds())))
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+
+	if seeker, ok := obj.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", obj.ModTime, seeker)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, obj.Body)
+}
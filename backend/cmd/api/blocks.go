@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type MutedWordPayload struct {
+	Word string `json:"word" validate:"required,max=100"`
+}
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Cuts both users off from each other. The follows between them are removed and neither can follow or comment on the other.
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error	"User already blocked"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Block)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Lifts a block, the follows removed by it are not restored
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Unblock)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Hides the posts and comments of a user from the current user, the muted user is not told
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error	"User already muted"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Mutes.Mute)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Mutes.Unmute)
+}
+
+// GetBlockedUsers godoc
+//
+//	@Summary		Fetches the users blocked by the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getUserFromCtx(r).Id, app.store.Blocks.GetBlocked)
+}
+
+// GetMutedUsers godoc
+//
+//	@Summary		Fetches the users muted by the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) getMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getUserFromCtx(r).Id, app.store.Mutes.GetMuted)
+}
+
+// GetMutedWords godoc
+//
+//	@Summary		Fetches the muted words of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.MutedWord
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words [get]
+func (app *application) getMutedWordsHandler(w http.ResponseWriter, r *http.Request) {
+	words, err := app.store.Mutes.GetWords(r.Context(), getUserFromCtx(r).Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, words); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AddMutedWord godoc
+//
+//	@Summary		Mutes a word
+//	@Description	Hides posts and comments containing the word from the current user, the case does not matter
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MutedWordPayload	true	"Muted word"
+//	@Success		201		{object}	store.MutedWord
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"Word already muted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words [post]
+func (app *application) addMutedWordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MutedWordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	payload.Word = strings.TrimSpace(payload.Word)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	word := &store.MutedWord{
+		UserId: getUserFromCtx(r).Id,
+		Word:   payload.Word,
+	}
+	if err := app.store.Mutes.AddWord(r.Context(), word); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, word); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteMutedWord godoc
+//
+//	@Summary		Unmutes a word
+//	@Tags			users
+//	@Param			wordID	path	int	true	"Muted word ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/muted-words/{wordID} [delete]
+func (app *application) deleteMutedWordHandler(w http.ResponseWriter, r *http.Request) {
+	wordId, err := strconv.ParseInt(chi.URLParam(r, "wordID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Mutes.DeleteWord(r.Context(), getUserFromCtx(r).Id, wordId); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* Helper Functions */
+
+// Applies a change from the current user to the user in the url, such as a block or a mute
+func (app *application) changeRelation(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(context.Context, int64, int64) error,
+) {
+	otherId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	userId := getUserFromCtx(r).Id
+	if err := change(r.Context(), userId, otherId); err != nil {
+		switch err {
+		case store.ErrSelfBlock, store.ErrSelfMute, store.ErrSelfCloseFriend:
+			app.badRequestError(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		case store.ErrConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	// Fan-out skips users who muted the author, so the timeline misses their posts after an unmute
+	app.invalidateTimeline(r.Context(), userId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Blocks between the viewer and the users listed, whoever blocked whom
+type blockedUsers struct {
+	store.MockBlocksStore
+	blocked map[int64]bool
+}
+
+func (b *blockedUsers) IsBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	return b.blocked[otherId], nil
+}
+
+// Follows which are refused, one of the users blocked the other
+type blockedFollows struct {
+	store.MockFollowersStore
+}
+
+func (f *blockedFollows) Follow(ctx context.Context, followerId int64, userId int64) (bool, error) {
+	return false, store.ErrBlocked
+}
+
+func TestBlockUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"should block other users", "/v1/users/2/block", http.StatusNoContent},
+		{"should not allow blocking yourself", "/v1/users/1/block", http.StatusBadRequest},
+		{"should unblock users", "/v1/users/2/unblock", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestBlockedInteractions(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Followers = &blockedFollows{}
+	// The mocked posts belong to user 0
+	app.store.Blocks = &blockedUsers{blocked: map[int64]bool{0: true}}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"should not follow blocked users", http.MethodPut, "/v1/users/2/follow", ""},
+		{"should not comment on posts of blocked users", http.MethodPost, "/v1/posts/10/comments", `{"content": "Hello"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusForbidden, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type BookmarkPayload struct {
+	FolderId *int64 `json:"folder_id"` // Nil keeps the bookmark outside of any folder
+}
+
+type BookmarkFolderPayload struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves the post for later, in a folder of the user if one is given. Bookmarking a bookmarked post moves it to the folder.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		BookmarkPayload	false	"Folder of the bookmark"
+//	@Success		200		{object}	store.Bookmark
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Post or folder not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	// The folder is optional, so is the whole body
+	var payload BookmarkPayload
+	if err := readJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	bookmark := &store.Bookmark{
+		UserId:   getUserFromCtx(r).Id,
+		PostId:   getPostFromCtx(r).Id,
+		FolderId: payload.FolderId,
+	}
+	if err := app.store.Bookmarks.Add(r.Context(), bookmark); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, errors.New("folder not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, bookmark); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RemoveBookmark godoc
+//
+//	@Summary		Removes a bookmark
+//	@Tags			bookmarks
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204
+//	@Failure		404	{object}	error	"The post is not bookmarked"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) removeBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Bookmarks.Remove(r.Context(), getUserFromCtx(r).Id, getPostFromCtx(r).Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Fetches the bookmarks of the current user
+//	@Description	Lists the bookmarks with their posts, the newest bookmark first unless sort=asc. Posts the user can not see anymore are left out.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			folder	query		int		false	"Only the bookmarks in the folder"
+//	@Param			tags	query		string	false	"Only posts with any of the comma separated tags"
+//	@Param			search	query		string	false	"Search"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.Bookmark
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if fq.Tags, err = normalizeTags(fq.Tags); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var folderId *int64
+	if folder := r.URL.Query().Get("folder"); folder != "" {
+		id, err := strconv.ParseInt(folder, 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		folderId = &id
+	}
+
+	ctx := r.Context()
+	bookmarks, err := app.store.Bookmarks.GetByUserId(ctx, getUserFromCtx(r).Id, folderId, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	posts := make([]*store.Post, len(bookmarks))
+	for i := range bookmarks {
+		posts[i] = &bookmarks[i].Post.Post
+	}
+	if err := app.attachPostMedia(ctx, posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachShares(ctx, getUserFromCtx(r).Id, posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachPolls(ctx, getUserFromCtx(r).Id, posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, bookmarks); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetBookmarkFolders godoc
+//
+//	@Summary		Fetches the bookmark folders of the current user
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{array}		store.BookmarkFolder
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/folders [get]
+func (app *application) getBookmarkFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	folders, err := app.store.Bookmarks.GetFolders(r.Context(), getUserFromCtx(r).Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, folders); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateBookmarkFolder godoc
+//
+//	@Summary		Creates a bookmark folder
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		BookmarkFolderPayload	true	"Folder payload"
+//	@Success		201		{object}	store.BookmarkFolder
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"A folder with the name exists already"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/folders [post]
+func (app *application) createBookmarkFolderHandler(w http.ResponseWriter, r *http.Request) {
+	var payload BookmarkFolderPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	folder := &store.BookmarkFolder{UserId: getUserFromCtx(r).Id, Name: payload.Name}
+	if err := app.store.Bookmarks.CreateFolder(r.Context(), folder); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("a folder with the name exists already"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, folder); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteBookmarkFolder godoc
+//
+//	@Summary		Deletes a bookmark folder
+//	@Description	The bookmarks in the folder are kept outside of any folder
+//	@Tags			bookmarks
+//	@Param			folderID	path	int	true	"Folder ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/folders/{folderID} [delete]
+func (app *application) deleteBookmarkFolderHandler(w http.ResponseWriter, r *http.Request) {
+	folderId, err := strconv.ParseInt(chi.URLParam(r, "folderID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Bookmarks.DeleteFolder(r.Context(), getUserFromCtx(r).Id, folderId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* Helper Functions */
+
+// Marks the posts the viewer bookmarked with a single query
+func (app *application) attachBookmarks(ctx context.Context, viewerId int64, posts ...*store.Post) error {
+	postIds := make([]int64, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.Id
+	}
+
+	bookmarked, err := app.store.Bookmarks.GetBookmarkedIds(ctx, viewerId, postIds)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Bookmarked = bookmarked[post.Id]
+	}
+	return nil
+}
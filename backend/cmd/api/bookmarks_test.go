@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Bookmarks of a user who already has a folder named "Reading"
+type folderBookmarks struct {
+	store.MockBookmarksStore
+}
+
+func (f *folderBookmarks) CreateFolder(ctx context.Context, folder *store.BookmarkFolder) error {
+	if folder.Name == "Reading" {
+		return store.ErrConflict
+	}
+	return f.MockBookmarksStore.CreateFolder(ctx, folder)
+}
+
+func TestCreateBookmarkFolder(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Bookmarks = &folderBookmarks{}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should create the folder", `{"name": "Recipes"}`, http.StatusCreated},
+		{"should reject blank names", `{"name": "   "}`, http.StatusBadRequest},
+		{"should reject long names", `{"name": "` + strings.Repeat("a", 51) + `"}`, http.StatusBadRequest},
+		{"should reject a second folder with the name", `{"name": " Reading "}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/v1/bookmarks/folders", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestGetBookmarks(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should reject folders which are not ids", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/bookmarks?folder=reading", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+)
+
+// AddCloseFriend godoc
+//
+//	@Summary		Adds a user to the close friends
+//	@Description	Lets the user see the posts the current user shares with close friends, the user is not told
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error	"User already a close friend"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/close-friend [put]
+func (app *application) addCloseFriendHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.CloseFriends.Add)
+}
+
+// RemoveCloseFriend godoc
+//
+//	@Summary		Removes a user from the close friends
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/close-friend [delete]
+func (app *application) removeCloseFriendHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.CloseFriends.Remove)
+}
+
+// GetCloseFriends godoc
+//
+//	@Summary		Fetches the close friends of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/close-friends [get]
+func (app *application) getCloseFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getUserFromCtx(r).Id, app.store.CloseFriends.GetByUserId)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Sumitwarrior7/social/internal/moderation"
 	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -34,17 +35,48 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 
 	user := getUserFromCtx(r)
 	post := getPostFromCtx(r)
+	if post.Status != store.PostPublished {
+		app.forbiddenError(w, r, errors.New("the post is not published yet"))
+		return
+	}
+
+	// A post can still be opened by its link, but blocked users can not talk to each other
+	ctx := r.Context()
+	blocked, err := app.store.Blocks.IsBlocked(ctx, user.Id, post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.forbiddenError(w, r, store.ErrBlocked)
+		return
+	}
+
+	content := &moderation.Content{
+		Kind:     moderation.KindComment,
+		AuthorId: user.Id,
+		Body:     payload.Content,
+	}
+	moderated, ok := app.checkContent(w, r, content)
+	if !ok {
+		return
+	}
+
 	comment := &store.Comment{
 		PostId:  post.Id,
 		UserId:  user.Id,
 		Content: payload.Content,
+		HeldAt:  holdTime(moderated),
 	}
-
-	ctx := r.Context()
 	if err := app.store.Comments.Create(ctx, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.recordModerationDecision(ctx, content, &comment.Id, moderated)
+	if comment.HeldAt == nil {
+		app.syncMentions(ctx, user.Id, post.Id, &comment.Id, comment.Content)
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -61,6 +93,10 @@ func (app *application) getCommentByIdHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if notModified(w, r, commentETag(comment), parseTimestamp(comment.UpdatedAt)) {
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -70,8 +106,9 @@ func (app *application) getCommentByIdHandler(w http.ResponseWriter, r *http.Req
 func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	comment := getCommentFromCtx(r)
+	user := getUserFromCtx(r)
 
-	if err := app.store.Comments.Delete(ctx, comment.Id); err != nil {
+	if err := app.store.Comments.SoftDelete(ctx, comment.Id, user.Id); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, r, err)
@@ -99,19 +136,42 @@ func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	updatedComment := &store.Comment{
-		Id:      comment.Id,
-		Content: payload.Content,
+	if !app.checkIfMatch(w, r, commentETag(comment)) {
+		return
 	}
-	// if payload.Content != "" {
-	// 	comment.Content = payload.Content
-	// }
 
-	if err := app.store.Comments.Update(ctx, updatedComment); err != nil {
-		app.internalServerError(w, r, err)
+	content := &moderation.Content{
+		Id:       comment.Id,
+		Kind:     moderation.KindComment,
+		AuthorId: comment.UserId,
+		Body:     payload.Content,
+	}
+	moderated, ok := app.checkContent(w, r, content)
+	if !ok {
+		return
+	}
+	if comment.HeldAt == nil {
+		comment.HeldAt = holdTime(moderated)
+	}
+
+	// The version read by the context middleware guards against updates racing this one
+	comment.Content = payload.Content
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
-	if err := app.jsonResponse(w, http.StatusOK, updatedComment); err != nil {
+	app.recordModerationDecision(ctx, content, &comment.Id, moderated)
+	if comment.HeldAt == nil {
+		app.syncMentions(ctx, comment.UserId, comment.PostId, &comment.Id, comment.Content)
+	}
+
+	w.Header().Set("ETag", commentETag(comment))
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -137,6 +197,25 @@ func (app *application) commentContextMiddleware(next http.Handler) http.Handler
 			}
 			return
 		}
+		// A comment is only found under its own post, the checks on the post in the path must not
+		// let other posts' comments through
+		if comment.PostId != getPostFromCtx(r).Id {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		if comment.HeldAt != nil {
+			visible, err := app.canViewHeld(ctx, getUserFromCtx(r), comment.UserId)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if !visible {
+				app.notFoundError(w, r, store.ErrNotFound)
+				return
+			}
+		}
+
 		ctx = context.WithValue(ctx, commentCtx, comment)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Comments which are all on post 10
+type commentsOfPost struct {
+	store.MockCommentsStore
+}
+
+func (c *commentsOfPost) GetById(ctx context.Context, commentId int64) (*store.Comment, error) {
+	return &store.Comment{Id: commentId, PostId: 10}, nil
+}
+
+func TestGetComment(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Comments = &commentsOfPost{}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"should get comments through their post", "/v1/posts/10/comments/3", http.StatusOK},
+		{"should not get comments through another post", "/v1/posts/11/comments/3", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
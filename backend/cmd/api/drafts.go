@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Due posts are published in batches, a backlog is worked off over the following ticks
+const scheduledPublishBatchSize = 100
+
+type SchedulePostPayload struct {
+	PublishAt time.Time `json:"publish_at" validate:"required"`
+}
+
+// GetDrafts godoc
+//
+//	@Summary		Fetches the unpublished posts of the user
+//	@Description	Lists the drafts and scheduled posts of the user. Scheduled posts come first in the order they are published, then drafts, the most recently edited first.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			status	query		string	false	"draft or scheduled, both by default"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != store.PostDraft && status != store.PostScheduled {
+		app.badRequestError(w, r, errors.New("status must be draft or scheduled"))
+		return
+	}
+
+	ctx := r.Context()
+	posts, err := app.store.Posts.GetUnpublished(ctx, getUserFromCtx(r).Id, status, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	unpublished := make([]*store.Post, len(posts))
+	for i := range posts {
+		unpublished[i] = &posts[i]
+	}
+	if err := app.attachPostMedia(ctx, unpublished...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachPolls(ctx, getUserFromCtx(r).Id, unpublished...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SchedulePost godoc
+//
+//	@Summary		Schedules a post
+//	@Description	Schedules a draft, or moves a scheduled post to another time. The post is published once the time passed.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Post ID"
+//	@Param			payload	body		SchedulePostPayload	true	"When to publish the post"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"The post is already published"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/schedule [put]
+func (app *application) schedulePostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SchedulePostPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if _, err := postStatus(store.PostScheduled, &payload.PublishAt); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	app.schedulePost(w, r, &payload.PublishAt)
+}
+
+// UnschedulePost godoc
+//
+//	@Summary		Unschedules a post
+//	@Description	Turns a scheduled post back into a draft
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"The post is already published"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/schedule [delete]
+func (app *application) unschedulePostHandler(w http.ResponseWriter, r *http.Request) {
+	app.schedulePost(w, r, nil)
+}
+
+// PublishPost godoc
+//
+//	@Summary		Publishes a post
+//	@Description	Publishes a draft or scheduled post right away
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"The post is already published"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/publish [post]
+func (app *application) publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if post.Status == store.PostPublished {
+		app.conflictError(w, r, errors.New("the post is already published"))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+	if err := app.publishPost(ctx, post, user.Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// The publisher job got to it first
+			app.conflictError(w, r, errors.New("the post is already published"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.loadPostDetails(ctx, post, user.Id); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", app.postResponseETag(post))
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+/* Helper Functions */
+
+// Works out the status of a new post from the requested one and its publish time, scheduled
+// posts need a time in the future and only they can have one
+func postStatus(status string, publishAt *time.Time) (string, error) {
+	if publishAt == nil {
+		switch status {
+		case "":
+			return store.PostPublished, nil
+		case store.PostScheduled:
+			return "", errors.New("scheduled posts need a publish_at")
+		default:
+			return status, nil
+		}
+	}
+
+	if status != "" && status != store.PostScheduled {
+		return "", errors.New("only scheduled posts can have a publish_at")
+	}
+	if !publishAt.After(time.Now()) {
+		return "", errors.New("publish_at must be in the future")
+	}
+	return store.PostScheduled, nil
+}
+
+// Schedules the post of the request for the provided time, or turns it into a draft for a nil
+// time, and writes the updated post
+func (app *application) schedulePost(w http.ResponseWriter, r *http.Request, publishAt *time.Time) {
+	post := getPostFromCtx(r)
+	if post.Status == store.PostPublished {
+		app.conflictError(w, r, errors.New("the post is already published"))
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Posts.Schedule(ctx, post, publishAt, getUserFromCtx(r).Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictError(w, r, errors.New("the post is already published"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.loadPostDetails(ctx, post, getUserFromCtx(r).Id); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", app.postResponseETag(post))
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Publishes a draft or scheduled post and does what creating a published post does, the
+// mentioned users are notified and the post goes out to the timelines. Held posts still wait
+// for a moderator, they are announced once approved. The editor is who published it.
+func (app *application) publishPost(ctx context.Context, post *store.Post, editorId int64) error {
+	if err := app.store.Posts.Publish(ctx, post, editorId); err != nil {
+		return err
+	}
+
+	if post.HeldAt == nil {
+		app.syncMentions(ctx, post.UserId, post.Id, nil, post.Title+"\n"+post.Content)
+	}
+	app.enqueueFanOut(post)
+	return nil
+}
+
+// Publishes the scheduled posts which are due right away and then on each interval, it blocks
+// until the context is cancelled
+func (app *application) runScheduledPublisher(ctx context.Context) {
+	ticker := time.NewTicker(app.config.publisher.interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := app.store.Posts.GetDueIds(ctx, time.Now(), scheduledPublishBatchSize)
+		if err != nil {
+			app.logger.Errorw("error loading due posts", "error", err)
+		}
+		for _, id := range ids {
+			// A post published or deleted by hand in the meantime is not found, it is simply skipped.
+			// The author published it by scheduling it.
+			post, err := app.store.Posts.GetById(ctx, id)
+			if err == nil {
+				err = app.publishPost(ctx, post, post.UserId)
+			}
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				app.logger.Errorw("error publishing a scheduled post", "post", id, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+func TestPostStatus(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		status    string
+		publishAt *time.Time
+		want      string
+		wantErr   bool
+	}{
+		{"should publish right away by default", "", nil, store.PostPublished, false},
+		{"should keep drafts", store.PostDraft, nil, store.PostDraft, false},
+		{"should publish published posts", store.PostPublished, nil, store.PostPublished, false},
+		{"should schedule posts with a publish time", "", &future, store.PostScheduled, false},
+		{"should schedule scheduled posts", store.PostScheduled, &future, store.PostScheduled, false},
+		{"should reject scheduled posts without a publish time", store.PostScheduled, nil, "", true},
+		{"should reject drafts with a publish time", store.PostDraft, &future, "", true},
+		{"should reject published posts with a publish time", store.PostPublished, &future, "", true},
+		{"should reject publish times in the past", "", &past, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postStatus(tt.status, tt.publishAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("postStatus error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("postStatus = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Posts of user 1 at version 2 and with the status given, which remember who wrote each revision
+type unpublishedPosts struct {
+	store.MockPostsStore
+	status    string
+	editorIds []int64
+}
+
+func (p *unpublishedPosts) GetById(ctx context.Context, postId int64) (*store.Post, error) {
+	return &store.Post{Id: postId, UserId: 1, Version: 2, Status: p.status, Visibility: store.PostPublic}, nil
+}
+
+func (p *unpublishedPosts) Schedule(ctx context.Context, post *store.Post, publishAt *time.Time, editorId int64) error {
+	if p.status == store.PostPublished {
+		return store.ErrNotFound
+	}
+	post.Status = store.PostDraft
+	if publishAt != nil {
+		post.Status = store.PostScheduled
+	}
+	post.Version++
+	p.editorIds = append(p.editorIds, editorId)
+	return nil
+}
+
+func (p *unpublishedPosts) Publish(ctx context.Context, post *store.Post, editorId int64) error {
+	if p.status == store.PostPublished {
+		return store.ErrNotFound
+	}
+	post.Status = store.PostPublished
+	post.Version++
+	p.editorIds = append(p.editorIds, editorId)
+	return nil
+}
+
+func TestSchedulePost(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		status     string
+		method     string
+		path       string
+		body       string
+		want       int
+		wantStatus string
+	}{
+		{"should schedule drafts", store.PostDraft, http.MethodPut, "/v1/posts/10/schedule", `{"publish_at": "` + future + `"}`, http.StatusOK, store.PostScheduled},
+		{"should reschedule scheduled posts", store.PostScheduled, http.MethodPut, "/v1/posts/10/schedule", `{"publish_at": "` + future + `"}`, http.StatusOK, store.PostScheduled},
+		{"should not schedule posts in the past", store.PostDraft, http.MethodPut, "/v1/posts/10/schedule", `{"publish_at": "` + past + `"}`, http.StatusBadRequest, ""},
+		{"should not schedule published posts", store.PostPublished, http.MethodPut, "/v1/posts/10/schedule", `{"publish_at": "` + future + `"}`, http.StatusConflict, ""},
+		{"should turn scheduled posts back into drafts", store.PostScheduled, http.MethodDelete, "/v1/posts/10/schedule", "", http.StatusOK, store.PostDraft},
+		{"should publish drafts", store.PostDraft, http.MethodPost, "/v1/posts/10/publish", "", http.StatusOK, store.PostPublished},
+		{"should publish scheduled posts", store.PostScheduled, http.MethodPost, "/v1/posts/10/publish", "", http.StatusOK, store.PostPublished},
+		{"should not publish published posts", store.PostPublished, http.MethodPost, "/v1/posts/10/publish", "", http.StatusConflict, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{})
+			posts := &unpublishedPosts{status: tt.status}
+			app.store.Posts = posts
+			mux := app.mount()
+
+			testToken, err := app.authenticator.GenerateToken(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+
+			if tt.want != http.StatusOK {
+				if len(posts.editorIds) > 0 {
+					t.Errorf("revisions written by %v, want none", posts.editorIds)
+				}
+				return
+			}
+
+			var response struct {
+				Data store.Post `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Data.Status != tt.wantStatus || response.Data.Version != 3 {
+				t.Errorf("got a %s post at version %d, want a %s post at version 3", response.Data.Status, response.Data.Version, tt.wantStatus)
+			}
+			// The new version is kept as a revision written by the user
+			if len(posts.editorIds) != 1 || posts.editorIds[0] != 1 {
+				t.Errorf("revisions written by %v, want [1]", posts.editorIds)
+			}
+			if etag := rr.Header().Get("ETag"); !strings.HasPrefix(etag, `"p10-v3`) {
+				t.Errorf("ETag = %s, want the tag of version 3", etag)
+			}
+		})
+	}
+}
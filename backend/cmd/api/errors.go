@@ -24,6 +24,41 @@ func (app *application) conflictError(w http.ResponseWriter, r *http.Request, er
 	writeJsonError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Forbidden Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusForbidden, err.Error())
+}
+
+func (app *application) preconditionFailedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Precondition Failed Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusPreconditionFailed, err.Error())
+}
+
+func (app *application) preconditionRequiredError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Precondition Required Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusPreconditionRequired, err.Error())
+}
+
+func (app *application) payloadTooLargeError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Payload Too Large Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) unsupportedMediaTypeError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Unsupported Media Type Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusUnsupportedMediaType, err.Error())
+}
+
+func (app *application) contentRejectedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Content Rejected Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusUnprocessableEntity, err.Error())
+}
+
+func (app *application) lockedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("Locked Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusLocked, err.Error())
+}
+
 func (app *application) unauthorizedError(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Errorw("Unauthorized Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 	writeJsonError(w, http.StatusUnauthorized, "unauthorized")
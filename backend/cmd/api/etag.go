@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+/* Entity tags, used for optimistic concurrency on writes and conditional reads */
+
+// Strong tag of the version of a post, writes are checked against it. Comments, bookmarks, shares
+// and votes of other users do not make an edit based on the post stale, so they are left out.
+func postETag(post *store.Post) string {
+	return fmt.Sprintf(`"p%d-v%d"`, post.Id, post.Version)
+}
+
+// Tag of the representation of a post for conditional reads, it also covers the comments, media,
+// bookmark state, shares and poll embedded in the response. It starts with the version tag, so
+// clients can send it back in If-Match.
+func postRepresentationETag(post *store.Post) string {
+	h := sha256.New()
+	for _, c := range post.Comments {
+		fmt.Fprintf(h, "c%d:%d;", c.Id, c.Version)
+	}
+	for _, m := range post.Media {
+		fmt.Fprintf(h, "m%d:%s:%s;", m.Id, m.Status, m.AltText)
+	}
+	if post.Bookmarked {
+		fmt.Fprint(h, "b;")
+	}
+	writeShares(h, post)
+	writePoll(h, post)
+	return fmt.Sprintf(`"p%d-v%d-%s"`, post.Id, post.Version, hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+func commentETag(comment *store.Comment) string {
+	return fmt.Sprintf(`"c%d-v%d"`, comment.Id, comment.Version)
+}
+
+// Checks the If-Match header of a write against the current tag of the resource. A missing header
+// is only accepted when the server does not require conditional writes. It writes the error response
+// and returns false when the write must not happen.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, currentETag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if app.config.requireIfMatch {
+			app.preconditionRequiredError(w, r, errors.New("the If-Match header is required, fetch the resource to get its ETag"))
+			return false
+		}
+		return true
+	}
+
+	if !etagMatches(header, versionTag(currentETag), false) {
+		w.Header().Set("ETag", currentETag)
+		app.preconditionFailedError(w, r, errors.New("the resource was modified, fetch it again before updating"))
+		return false
+	}
+	return true
+}
+
+// Reports whether a list of entity tags from an If-Match or If-None-Match header contains the tag.
+// If-Match needs the strong comparison, weak tags never match there.
+func etagMatches(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(candidate, "W/") && versionTag(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags of responses embedding signed media urls carry the signing window, so a cached copy is not
+// revalidated after its urls expired. The window changes every half url lifetime.
+func (app *application) withSigningWindow(etag string) string {
+	half := app.config.blob.urlExpiry / 2
+	if half < time.Second {
+		return etag
+	}
+	window := time.Now().Unix() / int64(half/time.Second)
+	return fmt.Sprintf(`%s.w%d"`, strings.TrimSuffix(etag, `"`), window)
+}
+
+// Reduces a tag to the version it names. The hash of a representation and the signing window do
+// not describe the version of the resource, so writes ignore them.
+func versionTag(etag string) string {
+	return representationSuffix.ReplaceAllString(etag, `"`)
+}
+
+var representationSuffix = regexp.MustCompile(`(-[0-9a-f]{16})?(\.w\d+)?"$`)
+
+// Tag of a post as it is returned, with the signing window when the response embeds media urls
+func (app *application) postResponseETag(post *store.Post) string {
+	etag := postRepresentationETag(post)
+	if hasMedia(post) {
+		etag = app.withSigningWindow(etag)
+	}
+	return etag
+}
+
+// Hashes the repost and quote state of a post, which other users change without touching the post
+func writeShares(w io.Writer, post *store.Post) {
+	fmt.Fprintf(w, "r%d:%d:%t:%s;", post.RepostCount, post.QuoteCount, post.Reposted, strings.Join(post.RepostedBy, ","))
+	if post.Quoted != nil {
+		fmt.Fprintf(w, "q%d:%t:%s:%s;", post.Quoted.Id, post.Quoted.Available, post.Quoted.Title, post.Quoted.Content)
+	}
+}
+
+// Hashes the poll of a post as the viewer sees it, votes change it and so does the poll closing
+func writePoll(w io.Writer, post *store.Post) {
+	if post.Poll == nil {
+		return
+	}
+	fmt.Fprintf(w, "v%d:%d:%t:%t:%t;", post.Poll.Id, post.Poll.Voters, post.Poll.Voted, post.Poll.Closed, post.Poll.ResultsHidden)
+	for _, o := range post.Poll.Options {
+		if o.Votes != nil {
+			fmt.Fprintf(w, "o%d:%d:%t;", o.Id, *o.Votes, o.Chosen)
+		}
+	}
+}
+
+func hasMedia(posts ...*store.Post) bool {
+	for _, p := range posts {
+		if len(p.Media) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Weak tag of a page of posts, it covers everything the page shows about every post
+func (app *application) feedETag(feed []store.PostWithMetaData) string {
+	h := sha256.New()
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		p := &feed[i]
+		posts[i] = &p.Post
+		fmt.Fprintf(h, "p%d:%d:%d:%s:%t;", p.Id, p.Version, p.CommentCount, postEditedAt(&p.Post), p.Bookmarked)
+		writeShares(h, &p.Post)
+		writePoll(h, &p.Post)
+		for _, c := range p.Comments {
+			fmt.Fprintf(h, "c%d:%d;", c.Id, c.Version)
+		}
+		for _, m := range p.Media {
+			fmt.Fprintf(h, "m%d:%s:%s;", m.Id, m.Status, m.AltText)
+		}
+	}
+
+	etag := fmt.Sprintf(`W/"l%d-%s"`, len(feed), hex.EncodeToString(h.Sum(nil))[:16])
+	if hasMedia(posts...) {
+		etag = app.withSigningWindow(etag)
+	}
+	return etag
+}
+
+// Weak tag of a profile, owners see their email address so their representation differs
+func profileETag(user *store.User, isOwner bool) string {
+	view := "public"
+	if isOwner {
+		view = "owner"
+	}
+	if c := user.FollowCounts; c != nil {
+		view = fmt.Sprintf("%s-f%d-%d", view, c.Followers, c.Following)
+	}
+	return fmt.Sprintf(`W/"u%d-v%d-%s"`, user.Id, user.Version, view)
+}
+
+func postEditedAt(post *store.Post) string {
+	if post.EditedAt == nil {
+		return ""
+	}
+	return *post.EditedAt
+}
+
+/* Conditional requests */
+
+// Sets the validators of a read and answers 304 when the copy of the client is still current.
+// The handler must not write anything else when it returns true.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence, If-Modified-Since is only looked at without it
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		if etag == "" || !etagMatches(header, etag, true) {
+			return false
+		}
+	} else if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err != nil || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Time of the last change to the post or to one of the comments shown with it
+func postLastModified(post *store.Post) time.Time {
+	latest := latestTimestamp(post.CreatedAt, post.UpdatedAt, postEditedAt(post))
+	for _, c := range post.Comments {
+		if t := parseTimestamp(c.UpdatedAt); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// Time of the newest change to an entry of a page of posts
+func feedLastModified(feed []store.PostWithMetaData) time.Time {
+	var latest time.Time
+	for i := range feed {
+		if t := postLastModified(&feed[i].Post); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// Returns the latest of the timestamps, the ones which do not parse are skipped
+func latestTimestamp(values ...string) time.Time {
+	var latest time.Time
+	for _, value := range values {
+		if t := parseTimestamp(value); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// Parses the timestamps the store returns as strings, it returns the zero time when that fails
+func parseTimestamp(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
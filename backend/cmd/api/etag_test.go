@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{"same tag", `"c1-v2"`, `"c1-v2"`, false, true},
+		{"other version", `"c1-v1"`, `"c1-v2"`, false, false},
+		{"any tag", `*`, `"c1-v2"`, false, true},
+		{"one of a list", `"c1-v1", "c1-v2"`, `"c1-v2"`, false, true},
+		{"weak tag in If-Match", `W/"c1-v2"`, `"c1-v2"`, false, false},
+		{"representation of the version", `"p1-v2-0123456789abcdef"`, `"p1-v2"`, false, true},
+		{"representation of another version", `"p1-v1-0123456789abcdef"`, `"p1-v2"`, false, false},
+		{"representation with a signing window", `"p1-v2-0123456789abcdef.w7"`, `"p1-v2"`, false, true},
+		{"weak tag in If-None-Match", `W/"c1-v2"`, `"c1-v2"`, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.header, tt.etag, tt.weak); got != tt.want {
+				t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostETag(t *testing.T) {
+	post := &store.Post{Id: 1, Version: 2}
+	before := postETag(post)
+	representation := postRepresentationETag(post)
+
+	t.Run("should change when a comment is edited", func(t *testing.T) {
+		post.Comments = []store.Comment{{Id: 3, Version: 0}}
+		withComment := postRepresentationETag(post)
+		post.Comments[0].Version++
+
+		if withComment == representation || postRepresentationETag(post) == withComment {
+			t.Error("post representation ETag did not change with its comments")
+		}
+	})
+
+	t.Run("should not change when someone votes in the poll", func(t *testing.T) {
+		votes := 0
+		post.Poll = &store.Poll{Id: 4, Options: []store.PollOption{{Id: 5, Votes: &votes}}}
+		withPoll := postRepresentationETag(post)
+		votes++
+		post.Poll.Voters++
+
+		if postETag(post) != before {
+			t.Error("post ETag changed with the poll, edits would fail although the post did not change")
+		}
+		if postRepresentationETag(post) == withPoll {
+			t.Error("post representation ETag did not change with the poll")
+		}
+	})
+
+	t.Run("should change when the post is edited", func(t *testing.T) {
+		post.Version++
+		if postETag(post) == before {
+			t.Error("post ETag did not change with its version")
+		}
+		post.Version--
+	})
+
+	t.Run("should let the representation tag stand for the version", func(t *testing.T) {
+		etag := postRepresentationETag(post)
+		for _, etag := range []string{etag, strings.TrimSuffix(etag, `"`) + `.w42"`} {
+			if !etagMatches(etag, postETag(post), false) {
+				t.Errorf("If-Match %s did not match the version %s", etag, postETag(post))
+			}
+		}
+	})
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"matching tag", "If-None-Match", `W/"u1-v2-public"`, http.StatusNotModified},
+		{"other tag", "If-None-Match", `"u1-v1-public"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"modified since", "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{"no validators", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+
+			if !notModified(w, r, `W/"u1-v2-public"`, modified) {
+				w.WriteHeader(http.StatusOK)
+			}
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("ETag header was not set")
+			}
+		})
+	}
+}
+
+func TestPostLastModified(t *testing.T) {
+	edited := "2024-05-03T09:00:00Z"
+	post := store.Post{
+		CreatedAt: "2024-05-01T12:00:00Z",
+		UpdatedAt: "2024-05-02T12:00:00Z",
+		EditedAt:  &edited,
+		Comments:  []store.Comment{{UpdatedAt: "2024-05-04T08:30:00Z"}},
+	}
+
+	if got, want := postLastModified(&post), time.Date(2024, 5, 4, 8, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("postLastModified = %v, want %v", got, want)
+	}
+
+	// Feed entries carry no updated_at, the newest creation or edit stands for the page
+	feed := []store.PostWithMetaData{
+		{Post: store.Post{CreatedAt: "2024-05-01T12:00:00Z", EditedAt: &edited}},
+		{Post: store.Post{CreatedAt: "2024-05-02T12:00:00Z"}},
+	}
+	if got, want := feedLastModified(feed), time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("feedLastModified = %v, want %v", got, want)
+	}
+	if !feedLastModified(nil).IsZero() {
+		t.Error("an empty page should have no Last-Modified")
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/blob"
+	"github.com/Sumitwarrior7/social/internal/export"
+	"github.com/Sumitwarrior7/social/internal/mailer"
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// How often exports left pending and expired archives are looked for
+const exportSweepInterval = time.Minute
+
+// RequestDataExport godoc
+//
+//	@Summary		Requests a copy of the user's data
+//	@Description	Starts building a ZIP archive with the profile, posts, comments, followers and media of the user. A download link is emailed once it is ready.
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	store.DataExport
+//	@Failure		409	{object}	error	"An export is already being built"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [post]
+func (app *application) requestDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	dataExport := &store.DataExport{UserId: user.Id}
+	if err := app.store.Exports.Create(r.Context(), dataExport); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("an export is already being built"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.enqueueDataExport(dataExport.Id)
+
+	if err := app.jsonResponse(w, http.StatusAccepted, dataExport); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Lists the exports of the user, ready ones come with a short lived download url
+func (app *application) getDataExportsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	exports, err := app.store.Exports.GetByUserId(ctx, getUserFromCtx(r).Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range exports {
+		app.signExportUrl(ctx, &exports[i])
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, exports); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportId, err := strconv.ParseInt(chi.URLParam(r, "exportID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	dataExport, err := app.store.Exports.GetById(ctx, exportId)
+	if err != nil || dataExport.UserId != getUserFromCtx(r).Id {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	app.signExportUrl(ctx, dataExport)
+
+	if err := app.jsonResponse(w, http.StatusOK, dataExport); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+/* Export worker */
+
+// Queues an export without blocking the request, the periodic sweep picks it up later if the queue is full
+func (app *application) enqueueDataExport(exportId int64) {
+	select {
+	case app.exportQueue <- exportId:
+	default:
+		app.logger.Warnw("export queue is full, export deferred", "export", exportId)
+	}
+}
+
+// Builds the queued exports one at a time and removes expired archives, it blocks until the context is cancelled
+func (app *application) runExportWorker(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case id := <-app.exportQueue:
+				app.buildDataExport(ctx, id)
+			}
+		}
+	}()
+
+	// Exports that were being built when the server stopped are built again
+	resetProcessing := true
+	ticker := time.NewTicker(exportSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := app.store.Exports.GetPendingIds(ctx, resetProcessing)
+		if err != nil {
+			app.logger.Errorw("error fetching pending exports", "error", err)
+		} else {
+			resetProcessing = false
+			for _, id := range ids {
+				app.enqueueDataExport(id)
+			}
+		}
+
+		keys, err := app.store.Exports.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			app.logger.Errorw("error deleting expired exports", "error", err)
+		}
+		for _, key := range keys {
+			app.deleteBlob(ctx, key)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) buildDataExport(ctx context.Context, exportId int64) {
+	dataExport, err := app.store.Exports.ClaimPending(ctx, exportId)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			app.logger.Errorw("error claiming export", "export", exportId, "error", err)
+		}
+		return
+	}
+
+	user, err := app.writeDataExport(ctx, dataExport)
+	if err != nil {
+		app.logger.Errorw("data export failed", "export", dataExport.Id, "error", err)
+		if err := app.store.Exports.Fail(ctx, dataExport.Id, "the archive could not be built"); err != nil {
+			app.logger.Errorw("error marking export as failed", "export", dataExport.Id, "error", err)
+		}
+		return
+	}
+
+	app.logger.Infow("data export ready", "export", dataExport.Id, "size", dataExport.SizeBytes)
+
+	// The archive stays available through the API when the email can not be sent
+	if err := app.sendDataExportEmail(ctx, user, dataExport); err != nil {
+		app.logger.Errorw("error sending data export email", "export", dataExport.Id, "error", err)
+	}
+}
+
+// Builds the archive in a temporary file and moves it to blob storage
+func (app *application) writeDataExport(ctx context.Context, dataExport *store.DataExport) (*store.User, error) {
+	data, err := app.store.Exports.GetUserData(ctx, dataExport.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := export.Write(ctx, tmp, data, app.openExportMedia, time.Now()); err != nil {
+		return nil, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// The random part keeps the key unguessable, even for someone who knows the export id
+	key := fmt.Sprintf("exports/%d/%d-%s.zip", dataExport.UserId, dataExport.Id, uuid.New().String())
+	if err := app.blobStore.Put(ctx, key, tmp, size, "application/zip"); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(app.config.exports.expiry)
+	dataExport.StorageKey = key
+	dataExport.SizeBytes = size
+	dataExport.ExpiresAt = &expiresAt
+	if err := app.store.Exports.Complete(ctx, dataExport); err != nil {
+		app.deleteBlob(ctx, key)
+		return nil, err
+	}
+
+	return data.Profile, nil
+}
+
+func (app *application) openExportMedia(ctx context.Context, media store.Media) (io.ReadCloser, error) {
+	obj, err := app.blobStore.Get(ctx, media.StorageKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return nil, export.ErrFileMissing
+		}
+		return nil, err
+	}
+	return obj.Body, nil
+}
+
+func (app *application) sendDataExportEmail(ctx context.Context, user *store.User, dataExport *store.DataExport) error {
+	// The link in the email has to outlive the short lived urls handed out by the API
+	downloadUrl, err := app.blobStore.SignedURL(ctx, dataExport.StorageKey, time.Until(*dataExport.ExpiresAt))
+	if err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username    string
+		DownloadUrl string
+		ExpiresAt   string
+	}{
+		Username:    user.Username,
+		DownloadUrl: downloadUrl,
+		ExpiresAt:   dataExport.ExpiresAt.UTC().Format(time.RFC1123),
+	}
+
+	_, err = app.mailer.Send(mailer.DataExportTemplate, user.Username, user.Email, vars, !isProdEnv)
+	return err
+}
+
+func (app *application) signExportUrl(ctx context.Context, dataExport *store.DataExport) {
+	if dataExport.Status == store.ExportStatusReady {
+		dataExport.DownloadUrl = app.signedUrl(ctx, dataExport.StorageKey)
+	}
+}
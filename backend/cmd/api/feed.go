@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/Sumitwarrior7/social/internal/store"
 )
@@ -10,7 +14,11 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the user feed, the newest posts first by default. With mode=ranked the posts are ordered by
+//	@Description	recency, comments, how much the user interacts with their authors and whether the feed served them before,
+//	@Description	and a few recommended posts of other users are mixed in. Its pages are computed as of the as_of time returned
+//	@Description	in the X-Feed-As-Of header of the first page, pass it back to page through the same ranking. The ranking
+//	@Description	is stored, so pages neither repeat nor skip posts; posts which can no longer be shown leave a page shorter.
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
@@ -21,6 +29,8 @@ import (
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
+//	@Param			mode	query		string	false	"chronological or ranked"
+//	@Param			as_of	query		string	false	"RFC 3339 time the ranked feed is computed at, now by default, at most a day ago"
 //	@Success		200		{object}	[]store.PostWithMetadata
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
@@ -33,6 +43,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		Limit:  20,
 		Offset: 0,
 		Sort:   "desc",
+		Mode:   "chronological",
 	}
 	fq, err := fq.Parse(r)
 	if err != nil {
@@ -49,7 +60,21 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	ctx := r.Context()
 	user := getUserFromCtx(r)
 
-	feeds, err := app.store.Posts.GetUserFeed(ctx, user.Id, fq)
+	var feeds []store.PostWithMetaData
+	if fq.Mode == "ranked" {
+		if fq.AsOf == "" {
+			fq.AsOf = time.Now().UTC().Format(time.RFC3339)
+		} else if err := checkFeedAsOf(fq.AsOf, time.Now()); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		w.Header().Set("X-Feed-As-Of", fq.AsOf)
+		feeds, err = app.store.Posts.GetRankedFeed(ctx, user.Id, fq, app.config.rankedFeed)
+	} else if timeline, ok := app.getTimelineFeed(ctx, user.Id, fq); ok {
+		feeds = timeline
+	} else {
+		feeds, err = app.store.Posts.GetUserFeed(ctx, user.Id, fq)
+	}
 	log.Println(feeds)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -58,7 +83,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	// Iterate through feeds and fetch comments for each feed
 	for i := range feeds {
-		comments, err := app.store.Comments.GetByPostId(ctx, feeds[i].Id)
+		comments, err := app.store.Comments.GetByPostId(ctx, feeds[i].Id, user.Id)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
@@ -68,9 +93,57 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		feeds[i].Comments = comments
 	}
 
+	if err := app.attachFeedMedia(ctx, feeds); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedDetails(ctx, user.Id, feeds); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if fq.Mode == "ranked" {
+		app.markFeedSeen(ctx, user.Id, feeds)
+	}
+
+	if notModified(w, r, app.feedETag(feeds), feedLastModified(feeds)) {
+		return
+	}
+
 	log.Println(feeds)
 
 	if err := app.jsonResponse(w, http.StatusOK, feeds); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+/* Helper Functions */
+
+// Remembers the posts of a ranked page so later snapshots rank them lower. The page is served
+// either way, a failure is only logged.
+func (app *application) markFeedSeen(ctx context.Context, userId int64, feeds []store.PostWithMetaData) {
+	postIds := make([]int64, len(feeds))
+	for i := range feeds {
+		postIds[i] = feeds[i].Id
+	}
+	if err := app.store.Posts.MarkSeen(ctx, userId, postIds); err != nil {
+		app.logger.Errorw("error recording feed impressions", "user", userId, "error", err)
+	}
+}
+
+// Checks the time the ranked feed is asked for. Rankings are only kept for a while, an older time
+// would rank the whole feed again as it was back then, and a time in the future would count posts
+// which are not even published yet.
+func checkFeedAsOf(asOf string, now time.Time) error {
+	t, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		return err
+	}
+	if t.After(now) {
+		return errors.New("as_of can not be in the future")
+	}
+	if t.Before(now.Add(-store.FeedSnapshotTTL)) {
+		return fmt.Errorf("as_of can be at most %s ago", store.FeedSnapshotTTL)
+	}
+	return nil
+}
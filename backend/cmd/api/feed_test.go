@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRankedFeedAsOf(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name string
+		asOf string
+		want int
+	}{
+		{"should rank the feed as of now by default", "", http.StatusOK},
+		{"should rank the feed as of a recent time", now.Add(-time.Hour).Format(time.RFC3339), http.StatusOK},
+		{"should reject a time in the future", now.Add(time.Hour).Format(time.RFC3339), http.StatusBadRequest},
+		{"should reject a time older than the rankings are kept", now.Add(-48 * time.Hour).Format(time.RFC3339), http.StatusBadRequest},
+		{"should reject a time which is not RFC 3339", "yesterday", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"mode": {"ranked"}}
+			if tt.asOf != "" {
+				query.Set("as_of", tt.asOf)
+			}
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/feed?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
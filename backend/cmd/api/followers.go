@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type profileKey string
+
+// The user a route is about, the authenticated user is stored under userCtx
+const profileCtx profileKey = "profile"
+
+// GetFollowers godoc
+//
+//	@Summary		Fetches the followers of a user
+//	@Description	Lists the users following a user, the most recent followers first unless sort=asc
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getProfileFromCtx(r).Id, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Fetches the users a user follows
+//	@Description	Lists the users followed by a user, the most recently followed first unless sort=asc
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getProfileFromCtx(r).Id, app.store.Followers.GetFollowing)
+}
+
+// GetMutuals godoc
+//
+//	@Summary		Fetches the mutual follows of a user
+//	@Description	Lists the users who follow a user and are followed back by them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mutuals [get]
+func (app *application) getMutualsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getProfileFromCtx(r).Id, app.store.Followers.GetMutuals)
+}
+
+// GetRelationship godoc
+//
+//	@Summary		Fetches how the current user relates to a user
+//	@Description	Tells whether the current user follows a user and whether that user follows them back
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.Relationship
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/relationship [get]
+func (app *application) getRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	profile := getProfileFromCtx(r)
+
+	relationship, err := app.store.Followers.GetRelationship(r.Context(), getUserFromCtx(r).Id, profile.Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, relationship); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowRequests godoc
+//
+//	@Summary		Fetches the pending follow requests of the current user
+//	@Description	Lists the users waiting for the current user to approve their follow, the most recent first unless sort=asc
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.FollowConnection
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listConnections(w, r, getUserFromCtx(r).Id, app.store.Followers.GetRequests)
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Lets the requester follow the current user
+//	@Tags			users
+//	@Param			userID	path	int	true	"Requester ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No pending request from the user"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/approve [post]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.ApproveRequest)
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Drops the follow request, the requester may ask again later
+//	@Tags			users
+//	@Param			userID	path	int	true	"Requester ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No pending request from the user"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/reject [post]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.RejectRequest)
+}
+
+func (app *application) profileContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		profile, err := app.GetUser(ctx, userId)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, profileCtx, profile)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/* Helper Functions */
+
+func getProfileFromCtx(r *http.Request) *store.User {
+	profile, _ := r.Context().Value(profileCtx).(*store.User)
+	return profile
+}
+
+// Writes a page of one of the follow lists of the user
+func (app *application) listConnections(
+	w http.ResponseWriter,
+	r *http.Request,
+	userId int64,
+	list func(context.Context, int64, store.PaginatedFeedQuery) ([]store.FollowConnection, error),
+) {
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	connections, err := list(r.Context(), userId, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, connections); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) answerFollowRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	answer func(context.Context, int64, int64) error,
+) {
+	requesterId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := answer(r.Context(), getUserFromCtx(r).Id, requesterId); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.invalidateTimeline(r.Context(), requesterId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reports whether the viewer may see the posts of the author. Private accounts only show them to
+// their followers, moderators see everything they may have to act on.
+func (app *application) canViewPostsOf(ctx context.Context, viewer *store.User, authorId int64) (bool, error) {
+	if viewer.Id == authorId {
+		return true, nil
+	}
+
+	author, err := app.GetUser(ctx, authorId)
+	if err != nil {
+		return false, err
+	}
+	if !author.IsPrivate {
+		return true, nil
+	}
+
+	relationship, err := app.store.Followers.GetRelationship(ctx, viewer.Id, authorId)
+	if err != nil {
+		return false, err
+	}
+	if relationship.Following {
+		return true, nil
+	}
+
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
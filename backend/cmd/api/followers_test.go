@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Users of which the ones listed have private accounts
+type privateUsers struct {
+	store.MockUserStore
+	private map[int64]bool
+}
+
+func (u *privateUsers) GetById(ctx context.Context, userId int64) (*store.User, error) {
+	return &store.User{Id: userId, IsPrivate: u.private[userId]}, nil
+}
+
+// Follows of the viewer, only the authors listed are followed
+type followedUsers struct {
+	store.MockFollowersStore
+	following map[int64]bool
+}
+
+func (f *followedUsers) GetRelationship(ctx context.Context, userId int64, otherId int64) (*store.Relationship, error) {
+	return &store.Relationship{UserId: otherId, Following: f.following[otherId]}, nil
+}
+
+// Follow requests of the users listed, waiting for user 1. Following a private account only requests it.
+type followRequests struct {
+	store.MockFollowersStore
+	private  map[int64]bool
+	pending  map[int64]bool
+	approved []int64
+}
+
+func (f *followRequests) Follow(ctx context.Context, followerId int64, userId int64) (bool, error) {
+	if followerId == userId {
+		return false, store.ErrSelfFollow
+	}
+	return f.private[userId], nil
+}
+
+func (f *followRequests) ApproveRequest(ctx context.Context, userId int64, requesterId int64) error {
+	if !f.pending[requesterId] {
+		return store.ErrNotFound
+	}
+	delete(f.pending, requesterId)
+	f.approved = append(f.approved, requesterId)
+	return nil
+}
+
+func (f *followRequests) RejectRequest(ctx context.Context, userId int64, requesterId int64) error {
+	if !f.pending[requesterId] {
+		return store.ErrNotFound
+	}
+	delete(f.pending, requesterId)
+	return nil
+}
+
+func (f *followRequests) ApproveAllRequests(ctx context.Context, userId int64) error {
+	for requesterId := range f.pending {
+		f.approved = append(f.approved, requesterId)
+	}
+	f.pending = map[int64]bool{}
+	return nil
+}
+
+func TestFollowPrivateAccount(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Followers = &followRequests{private: map[int64]bool{3: true}}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantCode   int
+		wantStatus string
+	}{
+		{"should follow public accounts", "/v1/users/2/follow", http.StatusOK, "following"},
+		{"should request to follow private accounts", "/v1/users/3/follow", http.StatusAccepted, "requested"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.wantCode, rr.Code)
+
+			var response struct {
+				Data FollowResponse `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Data.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", response.Data.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAnswerFollowRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		want     int
+		approved bool
+	}{
+		{"should approve pending requests", "/v1/users/me/follow-requests/3/approve", http.StatusNoContent, true},
+		{"should not approve users who did not ask", "/v1/users/me/follow-requests/4/approve", http.StatusNotFound, false},
+		{"should reject pending requests", "/v1/users/me/follow-requests/3/reject", http.StatusNoContent, false},
+		{"should not reject users who did not ask", "/v1/users/me/follow-requests/4/reject", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{})
+			requests := &followRequests{pending: map[int64]bool{3: true}}
+			app.store.Followers = requests
+			mux := app.mount()
+
+			testToken, err := app.authenticator.GenerateToken(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+
+			if approved := len(requests.approved) > 0; approved != tt.approved {
+				t.Errorf("approved = %v, want %v", approved, tt.approved)
+			}
+			if answered := !requests.pending[3]; answered != (tt.want == http.StatusNoContent) {
+				t.Errorf("request answered = %v, want %v", answered, !answered)
+			}
+		})
+	}
+}
+
+func TestMakeAccountPublic(t *testing.T) {
+	tests := []struct {
+		name     string
+		private  bool
+		body     string
+		approved bool
+	}{
+		{"should approve the pending requests when going public", true, `{"is_private": false, "version": 0}`, true},
+		{"should keep the requests of accounts staying private", true, `{"bio": "Hello", "version": 0}`, false},
+		{"should not approve anything for accounts which were public", false, `{"is_private": false, "version": 0}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{})
+			app.store.Users = &privateUsers{private: map[int64]bool{1: tt.private}}
+			requests := &followRequests{pending: map[int64]bool{3: tt.private}}
+			app.store.Followers = requests
+			mux := app.mount()
+
+			testToken, err := app.authenticator.GenerateToken(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			if approved := len(requests.approved) > 0; approved != tt.approved {
+				t.Errorf("requests approved = %v, want %v", approved, tt.approved)
+			}
+		})
+	}
+}
+
+func TestCanViewPostsOf(t *testing.T) {
+	app := newTestApplication(t, config{})
+	// 4 and 5 are private, the viewer only follows 5
+	app.store.Users = &privateUsers{private: map[int64]bool{4: true, 5: true}}
+	app.store.Followers = &followedUsers{following: map[int64]bool{5: true}}
+
+	viewer := &store.User{Id: 1, Role: store.Role{Name: "user", Level: 1}}
+	moderator := &store.User{Id: 9, Role: store.Role{Name: "moderator", Level: 2}}
+
+	tests := []struct {
+		name     string
+		viewer   *store.User
+		authorId int64
+		want     bool
+	}{
+		{"own posts", viewer, 1, true},
+		{"public account", viewer, 2, true},
+		{"private account the viewer does not follow", viewer, 4, false},
+		{"private account the viewer follows", viewer, 5, true},
+		{"private account for a moderator", moderator, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.canViewPostsOf(context.Background(), tt.viewer, tt.authorId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("canViewPostsOf = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"expvar"
+	"os"
 	"runtime"
 	"time"
 
 	"github.com/Sumitwarrior7/social/internal/auth"
+	"github.com/Sumitwarrior7/social/internal/blob"
 	"github.com/Sumitwarrior7/social/internal/db"
 	"github.com/Sumitwarrior7/social/internal/env"
 	"github.com/Sumitwarrior7/social/internal/mailer"
+	"github.com/Sumitwarrior7/social/internal/moderation"
 	"github.com/Sumitwarrior7/social/internal/ratelimiter"
 	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/Sumitwarrior7/social/internal/store/cache"
@@ -80,6 +84,86 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		media: mediaConfig{
+			maxImageSize:      int64(env.GetInt("MEDIA_MAX_IMAGE_SIZE", 10<<20)), // 10 MB
+			maxVideoSize:      int64(env.GetInt("MEDIA_MAX_VIDEO_SIZE", 50<<20)), // 50 MB
+			maxImagePixels:    env.GetInt("MEDIA_MAX_IMAGE_PIXELS", 50_000_000),
+			maxImageDimension: env.GetInt("MEDIA_MAX_IMAGE_DIMENSION", 12_000),
+			workers:           env.GetInt("MEDIA_WORKERS", 2),
+		},
+		uploads: uploadsConfig{
+			dir:             env.GetString("UPLOADS_PARTIAL_DIR", "./partial-uploads"),
+			expiry:          time.Hour * 24,
+			cleanupInterval: time.Hour,
+			userQuota:       int64(env.GetInt("UPLOADS_USER_QUOTA", 1<<30)), // 1 GB
+		},
+		exports: exportsConfig{
+			expiry: time.Hour * 24 * 7, // Presigned S3 urls can not live longer
+		},
+		requireIfMatch: env.GetBool("REQUIRE_IF_MATCH", false),
+		cacheControl: map[string]string{
+			// no-cache still lets clients keep a copy, they just revalidate it with the ETag every time
+			"posts": env.GetString("CACHE_CONTROL_POSTS", "private, no-cache"),
+			"feed":  env.GetString("CACHE_CONTROL_FEED", "private, no-cache"),
+			"users": env.GetString("CACHE_CONTROL_USERS", "private, no-cache"),
+			// Rankings only change when the job runs
+			"trending": env.GetString("CACHE_CONTROL_TRENDING", "private, max-age=60"),
+			// A stored version of a post never changes
+			"revisions": env.GetString("CACHE_CONTROL_REVISIONS", "private, max-age=86400, immutable"),
+		},
+		postMaxLength: map[string]int{
+			// Long-form posts are for trusted roles
+			"user":      env.GetInt("POST_MAX_LENGTH_USER", 5000),
+			"moderator": env.GetInt("POST_MAX_LENGTH_MODERATOR", 20000),
+			"admin":     env.GetInt("POST_MAX_LENGTH_ADMIN", 20000),
+		},
+		moderation: moderationConfig{
+			rulesRefresh:       time.Minute,
+			duplicateThreshold: float64(env.GetInt("MODERATION_DUPLICATE_PERCENT", 90)) / 100,
+			duplicateWindow:    time.Hour * 24,
+			duplicateLookback:  env.GetInt("MODERATION_DUPLICATE_LOOKBACK", 20),
+		},
+		trending: trendingConfig{
+			interval:  time.Minute * time.Duration(env.GetInt("TRENDING_INTERVAL_MINUTES", 5)),
+			limit:     env.GetInt("TRENDING_LIMIT", 50),
+			authorCap: env.GetInt("TRENDING_AUTHOR_CAP", 2),
+		},
+		rankedFeed: store.FeedWeights{
+			Recency:        env.GetFloat("FEED_RECENCY_WEIGHT", 1),
+			HalfLife:       time.Hour * time.Duration(env.GetInt("FEED_HALF_LIFE_HOURS", 12)),
+			Engagement:     env.GetFloat("FEED_ENGAGEMENT_WEIGHT", 0.5),
+			Relationship:   env.GetFloat("FEED_RELATIONSHIP_WEIGHT", 0.3),
+			Seen:           env.GetFloat("FEED_SEEN_PENALTY", 1),
+			RecommendEvery: env.GetInt("FEED_RECOMMEND_EVERY", 5),
+			RecommendSpan:  time.Hour * 48,
+		},
+		timeline: timelineConfig{
+			largeAccountFollowers: env.GetInt("TIMELINE_LARGE_ACCOUNT_FOLLOWERS", 10000),
+		},
+		publisher: publisherConfig{
+			interval: time.Second * time.Duration(env.GetInt("SCHEDULED_PUBLISH_INTERVAL_SECONDS", 30)),
+		},
+		trash: trashConfig{
+			retention:     time.Hour * 24 * time.Duration(env.GetInt("TRASH_RETENTION_DAYS", 30)),
+			purgeInterval: time.Hour,
+		},
+		blob: blobConfig{
+			backend:   env.GetString("BLOB_BACKEND", "local"),
+			urlExpiry: time.Minute * 15,
+			local: localBlobConfig{
+				dir:     env.GetString("BLOB_LOCAL_DIR", "./uploads"),
+				baseUrl: env.GetString("BLOB_LOCAL_BASE_URL", "http://localhost:8080/v1/blobs"),
+				secret:  env.GetString("BLOB_SIGNING_SECRET", "example"),
+			},
+			s3: s3BlobConfig{
+				endpoint:  env.GetString("S3_ENDPOINT", "http://localhost:9000"),
+				region:    env.GetString("S3_REGION", "us-east-1"),
+				bucket:    env.GetString("S3_BUCKET", "golang-media"),
+				accessKey: env.GetString("S3_ACCESS_KEY", ""),
+				secretKey: env.GetString("S3_SECRET_KEY", ""),
+				pathStyle: env.GetBool("S3_PATH_STYLE", true),
+			},
+		},
 	}
 
 	// Logger
@@ -124,6 +208,42 @@ func main() {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	// Blob storage
+	var blobStore blob.Store
+	switch cfg.blob.backend {
+	case "s3":
+		blobStore, err = blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.blob.s3.endpoint,
+			Region:    cfg.blob.s3.region,
+			Bucket:    cfg.blob.s3.bucket,
+			AccessKey: cfg.blob.s3.accessKey,
+			SecretKey: cfg.blob.s3.secretKey,
+			PathStyle: cfg.blob.s3.pathStyle,
+		})
+	default:
+		blobStore, err = blob.NewLocalStore(cfg.blob.local.dir, cfg.blob.local.baseUrl, cfg.blob.local.secret)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Content moderation, the rules come first so rejected content is not compared with anything
+	moderationRules := moderation.NewRulesCheck(store.Moderation, cfg.moderation.rulesRefresh)
+	moderationPipeline := moderation.NewPipeline(
+		moderationRules,
+		moderation.NewDuplicateCheck(
+			store.Moderation,
+			cfg.moderation.duplicateThreshold,
+			cfg.moderation.duplicateWindow,
+			cfg.moderation.duplicateLookback,
+		),
+	)
+
+	// Staging directory for resumable uploads
+	if err := os.MkdirAll(cfg.uploads.dir, 0o755); err != nil {
+		logger.Fatal(err)
+	}
+
 	app := &application{
 		config:        cfg,
 		store:         store,
@@ -132,6 +252,13 @@ func main() {
 		authenticator: JwtAuthenticator,
 		cacheStorage:  RedisStorage,
 		rateLimiter:   rateLimiter,
+		blobStore:     blobStore,
+		mediaQueue:    make(chan int64, 256),
+		exportQueue:   make(chan int64, 64),
+		timelineQueue: make(chan fanOutJob, 1024),
+
+		moderation:      moderationPipeline,
+		moderationRules: moderationRules,
 	}
 
 	// Metrics/stats to be shown
@@ -143,6 +270,16 @@ func main() {
 		return runtime.NumGoroutine()
 	}))
 
+	// Background workers
+	go app.runMediaProcessor(context.Background())
+	go app.runUploadCleanup(context.Background())
+	go app.runExportWorker(context.Background())
+	go app.runTrashPurge(context.Background())
+	go app.runTrendingJob(context.Background())
+	go app.runTimelineFanOut(context.Background())
+	go app.runScheduledPublisher(context.Background())
+	go app.renderMissingContent(context.Background())
+
 	mux := app.mount()
 	logger.Fatal(app.run(mux))
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Content saved before it was rendered is rendered in batches, so no batch holds the table for long
+const renderBatchSize = 500
+
+// Renders the Markdown of the posts and comments saved before content was rendered on save, new
+// content never needs it. It returns once everything is rendered.
+func (app *application) renderMissingContent(ctx context.Context) {
+	tables := []struct {
+		name   string
+		render func(context.Context, int64, int) (int64, error)
+	}{
+		{"posts", app.store.Posts.RenderMissing},
+		{"comments", app.store.Comments.RenderMissing},
+	}
+
+	for _, table := range tables {
+		var lastId int64
+		for {
+			next, err := table.render(ctx, lastId, renderBatchSize)
+			if err != nil {
+				app.logger.Errorw("error rendering content", "table", table.name, "error", err)
+				break
+			}
+			if next == 0 {
+				break
+			}
+			lastId = next
+		}
+	}
+}
+
+// Checks the content of a post against the longest one the role of its author may write, roles
+// without a limit of their own get the one of users. It writes the error response and returns
+// false when the content is too long.
+func (app *application) checkPostLength(w http.ResponseWriter, r *http.Request, author *store.User, content string) bool {
+	limit, ok := app.config.postMaxLength[author.Role.Name]
+	if !ok {
+		limit = app.config.postMaxLength["user"]
+	}
+
+	if utf8.RuneCountInString(content) > limit {
+		app.badRequestError(w, r, fmt.Errorf("the content can be at most %d characters long", limit))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Sumitwarrior7/social/internal/imageproc"
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type mediaKey string
+
+const mediaCtx mediaKey = "media"
+
+// Content types accepted for uploads, mapped to the extension used for the stored file
+var allowedMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"video/mp4":  ".mp4",
+}
+
+var (
+	errUnsupportedMedia = errors.New("unsupported media type")
+	errMediaTooLarge    = errors.New("file exceeds the limit")
+	errInvalidImage     = errors.New("invalid image")
+)
+
+type UpdateMediaPayload struct {
+	AltText string `json:"alt_text" validate:"max=1000"`
+}
+
+// UploadMedia godoc
+//
+//	@Summary		Uploads a media file
+//	@Description	Uploads an image or video as multipart form data, the returned id can be attached to a post
+//	@Tags			media
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file		formData	file	true	"Media file"
+//	@Param			alt_text	formData	string	false	"Alternative text"
+//	@Success		201			{object}	store.Media
+//	@Failure		400			{object}	error
+//	@Failure		413			{object}	error
+//	@Failure		415			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	// Largest allowed file plus some room for the multipart boundaries and the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.media.maxVideoSize+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.payloadTooLargeError(w, r, err)
+			return
+		}
+		app.badRequestError(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	altText := r.FormValue("alt_text")
+	if len(altText) > 1000 {
+		app.badRequestError(w, r, errors.New("alt_text must be at most 1000 characters"))
+		return
+	}
+
+	user := getUserFromCtx(r)
+	if err := app.checkStorageQuota(r.Context(), user, header.Size); err != nil {
+		app.mediaUploadError(w, r, err)
+		return
+	}
+
+	media, err := app.createMedia(r.Context(), user, file, header.Size, header.Filename, altText)
+	if err != nil {
+		app.mediaUploadError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, media); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	media := getMediaFromCtx(r)
+	app.signMediaUrls(r.Context(), media)
+
+	if err := app.jsonResponse(w, http.StatusOK, media); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Redirects to a short lived signed url, so the storage bucket never has to be public.
+// Images are only served through their processed variants, "full" is used when none is requested.
+func (app *application) getMediaContentHandler(w http.ResponseWriter, r *http.Request) {
+	media := getMediaFromCtx(r)
+
+	key := media.StorageKey
+	if isProcessedImage(media.ContentType) {
+		if media.Status != store.MediaStatusReady {
+			app.conflictError(w, r, fmt.Errorf("media is %s", media.Status))
+			return
+		}
+
+		name := r.URL.Query().Get("variant")
+		if name == "" {
+			name = "full"
+		}
+
+		variant := findVariant(media, name)
+		if variant == nil {
+			app.notFoundError(w, r, fmt.Errorf("variant %s not found", name))
+			return
+		}
+		key = variant.StorageKey
+	}
+
+	signedUrl, err := app.blobStore.SignedURL(r.Context(), key, app.config.blob.urlExpiry)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, signedUrl, http.StatusFound)
+}
+
+func (app *application) updateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	media := getMediaFromCtx(r)
+	if media.UserId != getUserFromCtx(r).Id {
+		app.forbidenWarning(w, r)
+		return
+	}
+
+	var payload UpdateMediaPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	media.AltText = payload.AltText
+	if err := app.store.Media.UpdateAltText(r.Context(), media); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.signMediaUrls(r.Context(), media)
+
+	if err := app.jsonResponse(w, http.StatusOK, media); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) deleteMediaHandler(w http.ResponseWriter, r *http.Request) {
+	media := getMediaFromCtx(r)
+	if media.UserId != getUserFromCtx(r).Id {
+		app.forbidenWarning(w, r)
+		return
+	}
+
+	if err := app.store.Media.Delete(r.Context(), media.Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.releaseMediaBlobs(r.Context(), media)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) mediaContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		media, err := app.store.Media.GetById(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		visible, err := app.canViewMedia(ctx, getUserFromCtx(r), media)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, mediaCtx, media)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Media is visible wherever the post it is attached to is, except across a block with its owner.
+// Media which is not attached yet is only visible to its owner.
+func (app *application) canViewMedia(ctx context.Context, viewer *store.User, media *store.Media) (bool, error) {
+	if media.UserId == viewer.Id {
+		return true, nil
+	}
+	if media.PostId == nil {
+		return false, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.Id, media.UserId)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	post, err := app.store.Posts.GetById(ctx, *media.PostId)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return app.canViewPost(ctx, viewer, post)
+}
+
+func getMediaFromCtx(r *http.Request) *store.Media {
+	media, _ := r.Context().Value(mediaCtx).(*store.Media)
+	return media
+}
+
+/* Helper Functions */
+// Loads the attachments of the provided posts with a single query
+func (app *application) attachPostMedia(ctx context.Context, posts ...*store.Post) error {
+	postIds := make([]int64, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.Id
+	}
+
+	attachments, err := app.store.Media.GetByPostIds(ctx, postIds)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Media = attachments[post.Id]
+		for i := range post.Media {
+			app.signMediaUrls(ctx, &post.Media[i])
+		}
+	}
+	return nil
+}
+
+func (app *application) attachFeedMedia(ctx context.Context, feed []store.PostWithMetaData) error {
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	return app.attachPostMedia(ctx, posts...)
+}
+
+// Stores an uploaded file and creates its media record. Identical images which were already
+// processed reuse the existing blobs, other images are queued for the processing pipeline.
+func (app *application) createMedia(ctx context.Context, user *store.User, file io.ReadSeeker, size int64, filename, altText string) (*store.Media, error) {
+	// The declared content type can not be trusted, so it is sniffed from the first bytes of the file
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := allowedMediaTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", errUnsupportedMedia, contentType)
+	}
+
+	maxSize := app.config.media.maxImageSize
+	if strings.HasPrefix(contentType, "video/") {
+		maxSize = app.config.media.maxVideoSize
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("%w of %d bytes", errMediaTooLarge, maxSize)
+	}
+
+	if isProcessedImage(contentType) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := imageproc.CheckDimensions(file, app.imageLimits()); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidImage, err)
+		}
+	}
+
+	// Hashing the content lets identical uploads share their blobs
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	contentHash := hex.EncodeToString(hash.Sum(nil))
+
+	media := &store.Media{
+		UserId:   user.Id,
+		AltText:  altText,
+		Filename: filepath.Base(filename),
+	}
+
+	source, err := app.store.Media.GetReadyByContentHash(ctx, contentHash)
+	switch {
+	case err == nil:
+		if err := app.store.Media.CreateFromDuplicate(ctx, media, source); err != nil {
+			return nil, err
+		}
+		app.signMediaUrls(ctx, media)
+		return media, nil
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	media.ContentType = contentType
+	media.SizeBytes = size
+	media.ContentHash = contentHash
+	media.StorageKey = "originals/" + contentHash + ext
+	media.Status = store.MediaStatusReady
+	if isProcessedImage(contentType) {
+		media.Status = store.MediaStatusPending
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := app.blobStore.Put(ctx, media.StorageKey, file, size, contentType); err != nil {
+		return nil, err
+	}
+
+	if err := app.store.Media.Create(ctx, media); err != nil {
+		app.releaseMediaBlobs(ctx, media)
+		return nil, err
+	}
+
+	if media.Status == store.MediaStatusPending {
+		app.enqueueMediaProcessing(media.Id)
+	}
+	app.signMediaUrls(ctx, media)
+	return media, nil
+}
+
+func (app *application) mediaUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errUnsupportedMedia):
+		app.unsupportedMediaTypeError(w, r, err)
+	case errors.Is(err, errMediaTooLarge), errors.Is(err, errQuotaExceeded):
+		app.payloadTooLargeError(w, r, err)
+	case errors.Is(err, errInvalidImage):
+		app.badRequestError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Fills the signed urls of the media, images expose their processed variants only
+func (app *application) signMediaUrls(ctx context.Context, media *store.Media) {
+	for i := range media.Variants {
+		media.Variants[i].Url = app.signedUrl(ctx, media.Variants[i].StorageKey)
+	}
+
+	switch {
+	case !isProcessedImage(media.ContentType):
+		media.Url = app.signedUrl(ctx, media.StorageKey)
+	case findVariant(media, "full") != nil:
+		media.Url = findVariant(media, "full").Url
+	default:
+		media.Url = ""
+	}
+}
+
+func (app *application) signedUrl(ctx context.Context, key string) string {
+	signedUrl, err := app.blobStore.SignedURL(ctx, key, app.config.blob.urlExpiry)
+	if err != nil {
+		app.logger.Errorw("error signing blob url", "key", key, "error", err)
+		return ""
+	}
+	return signedUrl
+}
+
+// Deletes the blobs of a removed media once no other record with the same content uses them
+func (app *application) releaseMediaBlobs(ctx context.Context, media *store.Media) {
+	if media.ContentHash != "" {
+		count, err := app.store.Media.CountByContentHash(ctx, media.ContentHash)
+		if err != nil {
+			app.logger.Errorw("error counting media references", "media", media.Id, "error", err)
+			return
+		}
+		if count > 0 {
+			return
+		}
+	}
+
+	app.deleteBlob(ctx, media.StorageKey)
+	for _, v := range media.Variants {
+		app.deleteBlob(ctx, v.StorageKey)
+	}
+}
+
+func (app *application) deleteBlob(ctx context.Context, key string) {
+	if err := app.blobStore.Delete(ctx, key); err != nil {
+		app.logger.Errorw("error deleting blob", "key", key, "error", err)
+	}
+}
+
+func findVariant(media *store.Media, name string) *store.MediaVariant {
+	for i := range media.Variants {
+		if media.Variants[i].Name == name {
+			return &media.Variants[i]
+		}
+	}
+	return nil
+}
+
+// Images the pipeline can decode, they are never served in their original form
+func isProcessedImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
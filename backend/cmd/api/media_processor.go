@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/imageproc"
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// How often media left in the pending state (e.g. because the queue was full) is picked up again
+const mediaSweepInterval = time.Minute
+
+func (app *application) imageLimits() imageproc.Limits {
+	return imageproc.Limits{
+		MaxPixels:    app.config.media.maxImagePixels,
+		MaxDimension: app.config.media.maxImageDimension,
+	}
+}
+
+// Queues a media for processing without blocking the request, the periodic sweep
+// picks it up later if the queue is full
+func (app *application) enqueueMediaProcessing(mediaId int64) {
+	select {
+	case app.mediaQueue <- mediaId:
+	default:
+		app.logger.Warnw("media queue is full, processing deferred", "media", mediaId)
+	}
+}
+
+// Starts the image processing workers, it blocks until the context is cancelled
+func (app *application) runMediaProcessor(ctx context.Context) {
+	for i := 0; i < app.config.media.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-app.mediaQueue:
+					app.processMedia(ctx, id)
+				}
+			}
+		}()
+	}
+
+	// Media that was being processed when the server stopped is processed again
+	resetProcessing := true
+	ticker := time.NewTicker(mediaSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := app.store.Media.GetPendingIds(ctx, resetProcessing)
+		if err != nil {
+			app.logger.Errorw("error fetching pending media", "error", err)
+		} else {
+			resetProcessing = false
+			for _, id := range ids {
+				app.enqueueMediaProcessing(id)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) processMedia(ctx context.Context, mediaId int64) {
+	media, err := app.store.Media.ClaimPending(ctx, mediaId)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			app.logger.Errorw("error claiming media", "media", mediaId, "error", err)
+		}
+		return
+	}
+
+	if err := app.generateVariants(ctx, media); err != nil {
+		app.logger.Warnw("media processing failed", "media", media.Id, "error", err)
+		if err := app.store.Media.FailProcessing(ctx, media.Id, err.Error()); err != nil {
+			app.logger.Errorw("error marking media as failed", "media", media.Id, "error", err)
+		}
+		return
+	}
+
+	app.logger.Infow("media processed", "media", media.Id, "variants", len(media.Variants))
+}
+
+func (app *application) generateVariants(ctx context.Context, media *store.Media) error {
+	obj, err := app.blobStore.Get(ctx, media.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj.Body, app.config.media.maxImageSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > app.config.media.maxImageSize {
+		return errMediaTooLarge
+	}
+
+	result, err := imageproc.Process(data, app.imageLimits(), imageproc.DefaultVariants)
+	if err != nil {
+		return err
+	}
+
+	media.Width = result.Width
+	media.Height = result.Height
+	media.Variants = nil
+	for _, out := range result.Variants {
+		ext := ".png"
+		if out.ContentType == "image/jpeg" {
+			ext = ".jpg"
+		}
+
+		// Variants are keyed by the content hash, so identical uploads share them
+		variant := store.MediaVariant{
+			MediaId:     media.Id,
+			Name:        out.Name,
+			StorageKey:  path.Join("variants", media.ContentHash, out.Name+ext),
+			ContentType: out.ContentType,
+			Width:       out.Width,
+			Height:      out.Height,
+			SizeBytes:   int64(len(out.Data)),
+		}
+
+		if err := app.blobStore.Put(ctx, variant.StorageKey, bytes.NewReader(out.Data), variant.SizeBytes, variant.ContentType); err != nil {
+			return err
+		}
+		media.Variants = append(media.Variants, variant)
+	}
+
+	return app.store.Media.CompleteProcessing(ctx, media)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Posts of which the deleted ones are remembered
+type deletedPosts struct {
+	store.MockPostsStore
+	deleted []int64
+}
+
+func (p *deletedPosts) Delete(ctx context.Context, postId int64) error {
+	p.deleted = append(p.deleted, postId)
+	return nil
+}
+
+// Media of which only the ones listed belong to the user and are not attached yet
+type attachableMedia struct {
+	store.MockMediaStore
+	attachable map[int64]bool
+}
+
+func (m *attachableMedia) AttachToPost(ctx context.Context, postId int64, userId int64, mediaIds []int64) error {
+	for _, id := range mediaIds {
+		if !m.attachable[id] {
+			return store.ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (m *attachableMedia) GetById(ctx context.Context, mediaId int64) (*store.Media, error) {
+	postId := int64(10)
+	return &store.Media{Id: mediaId, UserId: 2, PostId: &postId}, nil
+}
+
+func TestCreatePostWithMedia(t *testing.T) {
+	tests := []struct {
+		name     string
+		mediaIds string
+		want     int
+		// Whether the post is created and then rolled back
+		rolledBack bool
+	}{
+		{"should attach the media of the user", `[1, 2]`, http.StatusOK, false},
+		{"should create posts without media", `[]`, http.StatusOK, false},
+		{"should roll back the post when the media is not attachable", `[1, 3]`, http.StatusBadRequest, true},
+		{"should reject more than four attachments", `[1, 2, 4, 5, 6]`, http.StatusBadRequest, false},
+		{"should reject the same media twice", `[1, 1]`, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{})
+			posts := &deletedPosts{}
+			app.store.Posts = posts
+			app.store.Media = &attachableMedia{attachable: map[int64]bool{1: true, 2: true, 4: true, 5: true, 6: true}}
+			mux := app.mount()
+
+			testToken, err := app.authenticator.GenerateToken(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := `{"title": "Holiday", "content": "Pictures", "media_ids": ` + tt.mediaIds + `}`
+			req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+
+			if rolledBack := len(posts.deleted) > 0; rolledBack != tt.rolledBack {
+				t.Errorf("post rolled back = %v, want %v", rolledBack, tt.rolledBack)
+			}
+		})
+	}
+}
+
+func TestChangeMedia(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Media = &attachableMedia{}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The media belongs to a public post of user 2 and the token to user 1
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{"should not allow changing the alt text of others", http.MethodPatch, `{"alt_text": "A cat"}`},
+		{"should not allow deleting the media of others", http.MethodDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/v1/media/1", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusForbidden, rr.Code)
+		})
+	}
+}
+
+func TestCanViewMedia(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Blocks = &blockedUsers{blocked: map[int64]bool{3: true}}
+
+	viewer := &store.User{Id: 1}
+	postId := int64(10)
+
+	tests := []struct {
+		name  string
+		media *store.Media
+		want  bool
+	}{
+		{"own media which is not attached", &store.Media{Id: 5, UserId: 1}, true},
+		{"own attached media", &store.Media{Id: 5, UserId: 1, PostId: &postId}, true},
+		{"media of another user which is not attached", &store.Media{Id: 5, UserId: 2}, false},
+		{"attached media of a public post", &store.Media{Id: 5, UserId: 2, PostId: &postId}, true},
+		{"attached media of a blocked user", &store.Media{Id: 5, UserId: 3, PostId: &postId}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.canViewMedia(context.Background(), viewer, tt.media)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("canViewMedia = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
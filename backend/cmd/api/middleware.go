@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/golang-jwt/jwt/v5"
@@ -94,6 +95,13 @@ func (app *application) TokenAuthMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
+			if user.SuspendedUntil != nil {
+				if until := parseTimestamp(*user.SuspendedUntil); until.After(time.Now()) {
+					app.forbiddenError(w, r, fmt.Errorf("the account is suspended until %s", until.UTC().Format(time.RFC3339)))
+					return
+				}
+			}
+
 			ctx = context.WithValue(ctx, userCtx, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -119,6 +127,7 @@ func (app *application) CheckPostOwnership(requiredRole string, next http.Handle
 		allowed, err := app.checkRolePrecedence(ctx, user, requiredRole)
 		if err != nil {
 			app.internalServerError(w, r, err)
+			return
 		}
 
 		if !allowed {
@@ -145,6 +154,7 @@ func (app *application) CheckCommentOwnership(requiredRole string, next http.Han
 		allowed, err := app.checkRolePrecedence(ctx, user, requiredRole)
 		if err != nil {
 			app.internalServerError(w, r, err)
+			return
 		}
 
 		if !allowed {
@@ -156,6 +166,26 @@ func (app *application) CheckCommentOwnership(requiredRole string, next http.Han
 	})
 }
 
+// Lets only users with the role, or a higher one, through
+func (app *application) RequireRoleMiddleware(requiredRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromCtx(r), requiredRole)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbidenWarning(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -165,6 +195,55 @@ func (app *application) checkRolePrecedence(ctx context.Context, user *store.Use
 	return user.Role.Level >= role.Level, nil
 }
 
+// Sets the Cache-Control policy configured for the route on successful reads. Other responses
+// are never stored, so a cache can not keep serving an error after it was fixed.
+func (app *application) CacheControlMiddleware(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		policy := app.config.cacheControl[route]
+		if policy == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The responses depend on the token of the caller
+			w.Header().Add("Vary", "Authorization")
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, policy: policy}, r)
+		})
+	}
+}
+
+type cacheControlWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		switch {
+		case cw.Header().Get("Cache-Control") != "":
+		case status == http.StatusOK || status == http.StatusNotModified:
+			cw.Header().Set("Cache-Control", cw.policy)
+		default:
+			cw.Header().Set("Cache-Control", "no-store")
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
@@ -209,3 +288,11 @@ func (app *application) GetUser(ctx context.Context, userId int64) (*store.User,
 
 	return user, nil
 }
+
+// Removes the cached copy of a user so the next read goes to the database
+func (app *application) invalidateUserCache(ctx context.Context, userId int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+	app.cacheStorage.Users.Delete(ctx, userId)
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/moderation"
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type decisionKey string
+
+const decisionCtx decisionKey = "moderationDecision"
+
+type CreateModerationRulePayload struct {
+	Kind    string `json:"kind" validate:"required,oneof=term regex domain"`
+	Pattern string `json:"pattern" validate:"required,max=200"`
+	Action  string `json:"action" validate:"required,oneof=flag hold reject"`
+	Note    string `json:"note" validate:"max=500"`
+}
+
+// GetModerationRules godoc
+//
+//	@Summary		Fetches the moderation rules
+//	@Description	Lists the banned terms, regex rules and blocked link domains every post and comment is checked against
+//	@Tags			moderation
+//	@Produce		json
+//	@Success		200	{array}		store.ModerationRule
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/rules [get]
+func (app *application) getModerationRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := app.store.Moderation.GetRules(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, rules); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateModerationRule godoc
+//
+//	@Summary		Adds a moderation rule
+//	@Description	Adds a banned term, a regex rule or a blocked link domain. Content matching it is flagged, held for review or rejected.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateModerationRulePayload	true	"Rule payload"
+//	@Success		201		{object}	store.ModerationRule
+//	@Failure		400		{object}	error	"Invalid pattern"
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error	"Rule exists already"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/rules [post]
+func (app *application) createModerationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateModerationRulePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	rule := &store.ModerationRule{
+		Kind:      payload.Kind,
+		Pattern:   strings.TrimSpace(payload.Pattern),
+		Action:    payload.Action,
+		Note:      payload.Note,
+		CreatedBy: &user.Id,
+	}
+	if rule.Kind == store.ModerationRuleDomain {
+		rule.Pattern = moderation.NormalizeDomain(rule.Pattern)
+	}
+
+	// A rule which can not be compiled would be skipped silently by every check
+	if rule.Pattern == "" {
+		app.badRequestError(w, r, errors.New("invalid pattern"))
+		return
+	}
+	if _, err := moderation.CompileRule(*rule); err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid pattern: %w", err))
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Moderation.CreateRule(ctx, rule); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("the rule exists already"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.moderationRules.Invalidate()
+
+	if err := app.jsonResponse(w, http.StatusCreated, rule); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) deleteModerationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ruleId, err := strconv.ParseInt(chi.URLParam(r, "ruleID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Moderation.DeleteRule(r.Context(), ruleId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.moderationRules.Invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetModerationDecisions godoc
+//
+//	@Summary		Fetches the decisions of the moderation pipeline
+//	@Description	Lists the recorded decisions, the oldest first. By default only flagged and held content nobody reviewed yet is listed.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			pending			query		bool	false	"Only content waiting for a review, true by default"
+//	@Param			verdict			query		string	false	"allow, flag, hold or reject"
+//	@Param			content_type	query		string	false	"post or comment"
+//	@Param			author_id		query		int		false	"Author of the content"
+//	@Param			limit			query		int		false	"Limit"
+//	@Param			offset			query		int		false	"Offset"
+//	@Success		200				{array}		store.ModerationDecision
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/decisions [get]
+func (app *application) getModerationDecisionsHandler(w http.ResponseWriter, r *http.Request) {
+	dq := store.DecisionQuery{
+		Limit:   20,
+		Offset:  0,
+		Pending: true,
+	}
+	dq, err := dq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(dq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	decisions, err := app.store.Moderation.GetDecisions(r.Context(), dq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, decisions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getModerationDecisionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getDecisionFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveModerationDecision godoc
+//
+//	@Summary		Approves flagged or held content
+//	@Description	Marks the content as reviewed, held content is published
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			decisionID	path		int	true	"Decision ID"
+//	@Success		200			{object}	store.ModerationDecision
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Reviewed already"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/decisions/{decisionID}/approve [post]
+func (app *application) approveModerationDecisionHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewModerationDecision(w, r, store.ModerationReviewApprove)
+}
+
+// RejectModerationDecision godoc
+//
+//	@Summary		Rejects flagged or held content
+//	@Description	Moves the content to the trash of its author, like deleting it after a report
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			decisionID	path		int	true	"Decision ID"
+//	@Success		200			{object}	store.ModerationDecision
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Reviewed already"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/decisions/{decisionID}/reject [post]
+func (app *application) rejectModerationDecisionHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewModerationDecision(w, r, store.ModerationReviewReject)
+}
+
+func (app *application) decisionContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decisionId, err := strconv.ParseInt(chi.URLParam(r, "decisionID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		decision, err := app.store.Moderation.GetDecisionById(ctx, decisionId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, decisionCtx, decision)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/* Helper Functions */
+
+func getDecisionFromCtx(r *http.Request) *store.ModerationDecision {
+	decision, _ := r.Context().Value(decisionCtx).(*store.ModerationDecision)
+	return decision
+}
+
+// Runs the moderation pipeline on content about to be stored. Rejected content is recorded and
+// answered here, false is returned when the response was written already.
+func (app *application) checkContent(w http.ResponseWriter, r *http.Request, content *moderation.Content) (*moderation.Result, bool) {
+	ctx := r.Context()
+	result, err := app.moderation.Run(ctx, content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if result.Verdict == moderation.Reject {
+		app.recordModerationDecision(ctx, content, nil, result)
+		app.contentRejectedError(w, r, fmt.Errorf("the %s was rejected: %s", content.Kind, strings.Join(result.Reasons(), "; ")))
+		return nil, false
+	}
+	return result, true
+}
+
+// Keeps the verdict of the pipeline. The content is already stored, so a failure is only logged.
+func (app *application) recordModerationDecision(ctx context.Context, content *moderation.Content, contentId *int64, result *moderation.Result) {
+	findings, err := json.Marshal(result.Findings)
+	if err != nil {
+		app.logger.Errorw("error encoding moderation findings", "error", err)
+		return
+	}
+
+	decision := &store.ModerationDecision{
+		ContentType: content.Kind,
+		ContentId:   contentId,
+		AuthorId:    content.AuthorId,
+		Verdict:     result.Verdict.String(),
+		Findings:    findings,
+		Snapshot:    strings.TrimPrefix(content.Title+"\n"+content.Body, "\n"),
+	}
+	if err := app.store.Moderation.RecordDecision(ctx, decision); err != nil {
+		app.logger.Errorw("error recording a moderation decision", "kind", content.Kind, "author", content.AuthorId, "error", err)
+	}
+}
+
+// Returns the time content is held since when the verdict holds it, nil otherwise
+func holdTime(result *moderation.Result) *string {
+	if result.Verdict != moderation.Hold {
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	return &now
+}
+
+// Held content is only visible to its author and moderators
+func (app *application) canViewHeld(ctx context.Context, viewer *store.User, authorId int64) (bool, error) {
+	if viewer.Id == authorId {
+		return true, nil
+	}
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
+
+// Records the review of flagged or held content, rejected content is moved to the trash first so
+// a failure leaves the decision open for another try
+func (app *application) reviewModerationDecision(w http.ResponseWriter, r *http.Request, outcome string) {
+	ctx := r.Context()
+	decision := getDecisionFromCtx(r)
+	moderator := getUserFromCtx(r)
+
+	if decision.ContentId == nil || decision.ReviewedAt != nil ||
+		decision.Verdict != moderation.Flag.String() && decision.Verdict != moderation.Hold.String() {
+		app.conflictError(w, r, errors.New("the decision does not need a review"))
+		return
+	}
+
+	if outcome == store.ModerationReviewReject {
+		var err error
+		if decision.ContentType == moderation.KindPost {
+			err = app.store.Posts.SoftDelete(ctx, *decision.ContentId, moderator.Id)
+		} else {
+			err = app.store.Comments.SoftDelete(ctx, *decision.ContentId, moderator.Id)
+		}
+		// Content deleted by its author in the meantime is gone already
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	decision.ReviewedBy = &moderator.Id
+	decision.ReviewOutcome = &outcome
+	if err := app.store.Moderation.ReviewDecision(ctx, decision); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("the decision does not need a review"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Held content was neither announced to mentioned users nor pushed to timelines while nobody else could see it
+	if decision.Verdict == moderation.Hold.String() && outcome == store.ModerationReviewApprove {
+		app.publishApprovedContent(ctx, decision)
+	}
+
+	// Only held content was hidden from others, the authors of flagged content are not told
+	if decision.Verdict == moderation.Hold.String() || outcome == store.ModerationReviewReject {
+		if err := app.notifyReviewOutcome(ctx, decision); err != nil {
+			app.logger.Errorw("error notifying about a moderation review", "decision", decision.Id, "error", err)
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, decision); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) publishApprovedContent(ctx context.Context, decision *store.ModerationDecision) {
+	if decision.ContentType == moderation.KindPost {
+		post, err := app.store.Posts.GetById(ctx, *decision.ContentId)
+		if err != nil {
+			app.logger.Errorw("error loading an approved post", "post", *decision.ContentId, "error", err)
+			return
+		}
+		// Drafts and scheduled posts are announced once they are published
+		if post.Status == store.PostPublished {
+			app.syncMentions(ctx, post.UserId, post.Id, nil, post.Title+"\n"+post.Content)
+			app.enqueueFanOut(post)
+		}
+		return
+	}
+
+	comment, err := app.store.Comments.GetById(ctx, *decision.ContentId)
+	if err != nil {
+		app.logger.Errorw("error loading an approved comment", "comment", *decision.ContentId, "error", err)
+		return
+	}
+	app.syncMentions(ctx, comment.UserId, comment.PostId, &comment.Id, comment.Content)
+}
+
+func (app *application) notifyReviewOutcome(ctx context.Context, decision *store.ModerationDecision) error {
+	notification := &store.Notification{
+		UserId:  decision.AuthorId,
+		Type:    store.NotificationContentApproved,
+		Message: fmt.Sprintf("Your %s was reviewed by a moderator and is now visible to everyone.", decision.ContentType),
+	}
+	if *decision.ReviewOutcome == store.ModerationReviewReject {
+		notification.Type = store.NotificationContentRemoved
+		notification.Message = fmt.Sprintf("Your %s was reviewed by a moderator and removed for breaking the rules.", decision.ContentType)
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"decision_id":  decision.Id,
+		"content_type": decision.ContentType,
+		"content_id":   *decision.ContentId,
+	})
+	if err != nil {
+		return err
+	}
+	notification.Data = data
+
+	return app.store.Notifications.Create(ctx, notification)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetNotifications godoc
+//
+//	@Summary		Fetches the notifications of the current user
+//	@Description	Lists the notifications, the newest first. Notifications caused by blocked or muted users are left out.
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{array}		store.Notification
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	unreadOnly := false
+	if unread := r.URL.Query().Get("unread"); unread != "" {
+		if unreadOnly, err = strconv.ParseBool(unread); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	notifications, err := app.store.Notifications.GetByUserId(r.Context(), getUserFromCtx(r).Id, unreadOnly, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, notifications); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ReadNotification godoc
+//
+//	@Summary		Marks a notification as read
+//	@Tags			notifications
+//	@Param			notificationID	path	int	true	"Notification ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/{notificationID}/read [post]
+func (app *application) readNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	notificationId, err := strconv.ParseInt(chi.URLParam(r, "notificationID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Notifications.MarkRead(r.Context(), getUserFromCtx(r).Id, notificationId); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReadAllNotifications godoc
+//
+//	@Summary		Marks every notification as read
+//	@Tags			notifications
+//	@Success		204
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/read [post]
+func (app *application) readAllNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Notifications.MarkAllRead(r.Context(), getUserFromCtx(r).Id); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+type PollPayload struct {
+	Options        []string  `json:"options" validate:"required,min=2,max=6,unique,dive,required,max=80"`
+	MultipleChoice bool      `json:"multiple_choice"`
+	HideResults    bool      `json:"hide_results"` // Hides the results from voters until they vote or the poll closes
+	ClosesAt       time.Time `json:"closes_at" validate:"required"`
+}
+
+type VotePayload struct {
+	OptionIds []int64 `json:"option_ids" validate:"required,min=1,unique"`
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes in the poll of a post
+//	@Description	Records the vote of the current user, who votes once. Polls with multiple choice take several options.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int			true	"Post ID"
+//	@Param			payload	body		VotePayload	true	"Picked options"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"The poll is closed"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already voted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll/votes [post]
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VotePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	if post.Status != store.PostPublished {
+		app.forbiddenError(w, r, errors.New("the post is not published yet"))
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.attachPolls(ctx, user.Id, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if post.Poll == nil {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Polls.Vote(ctx, post.Poll.Id, user.Id, payload.OptionIds); err != nil {
+		switch {
+		case errors.Is(err, store.ErrPollSingleChoice), errors.Is(err, store.ErrPollOption):
+			app.badRequestError(w, r, err)
+		case errors.Is(err, store.ErrPollClosed):
+			app.forbiddenError(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("you already voted in this poll"))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.attachPolls(ctx, user.Id, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, post.Poll); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+/* Helper Functions */
+
+// Checks the poll of a new post, it has to stay open for a while after the post is published
+func validatePoll(poll *PollPayload, publishAt *time.Time) error {
+	opensAt := time.Now()
+	if publishAt != nil {
+		opensAt = *publishAt
+	}
+	if !poll.ClosesAt.After(opensAt) {
+		return errors.New("the poll must close after the post is published")
+	}
+
+	for _, option := range poll.Options {
+		if strings.TrimSpace(option) == "" {
+			return errors.New("poll options can not be blank")
+		}
+	}
+	return nil
+}
+
+// Creates the poll of a new post, the options keep the order they were sent in
+func (app *application) createPoll(ctx context.Context, post *store.Post, payload *PollPayload) error {
+	poll := &store.Poll{
+		PostId:         post.Id,
+		MultipleChoice: payload.MultipleChoice,
+		HideResults:    payload.HideResults,
+		ClosesAt:       payload.ClosesAt.UTC().Format(time.RFC3339),
+		Options:        make([]store.PollOption, len(payload.Options)),
+	}
+	for i, text := range payload.Options {
+		poll.Options[i].Text = strings.TrimSpace(text)
+	}
+
+	if err := app.store.Polls.Create(ctx, poll); err != nil {
+		return err
+	}
+	post.Poll = poll
+	return nil
+}
+
+// Loads the polls of the posts as the viewer sees them, with one query
+func (app *application) attachPolls(ctx context.Context, viewerId int64, posts ...*store.Post) error {
+	postIds := make([]int64, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.Id
+	}
+
+	polls, err := app.store.Polls.GetByPostIds(ctx, viewerId, postIds)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		post.Poll = polls[post.Id]
+	}
+	return nil
+}
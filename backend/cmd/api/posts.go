@@ -6,7 +6,11 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Sumitwarrior7/social/internal/extract"
+	"github.com/Sumitwarrior7/social/internal/moderation"
 	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -16,14 +20,24 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title    string   `json:"title" validate:"required,max=100"`
+	Content  string   `json:"content" validate:"required"` // Markdown, how long it can be depends on the role of the author
+	Tags     []string `json:"tags"`
+	MediaIds []int64  `json:"media_ids" validate:"max=4,unique"`
+	QuoteOf  *int64   `json:"quote_of"` // Makes the post a quote of another one
+	// Drafts and scheduled posts are only visible to their author until they are published, a
+	// publish_at without a status schedules the post
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+	// Who may see the post besides its author, public by default
+	Visibility string       `json:"visibility" validate:"omitempty,oneof=public followers close_friends private"`
+	Poll       *PollPayload `json:"poll"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title      *string `json:"title" validate:"omitempty,max=100"`
+	Content    *string `json:"content" validate:"omitempty"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers close_friends private"`
 }
 
 // CreatePost godoc
@@ -37,6 +51,7 @@ type UpdatePostPayload struct {
 //	@Success		201		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		422		{object}	error	"Rejected by the moderation rules"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -54,12 +69,63 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Hashtags in the text tag the post just like the tags sent with it
+	tags, err := normalizeTags(payload.Tags)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	tags = extract.MergeTags(tags, extract.Hashtags(payload.Title+"\n"+payload.Content))
+
+	status, err := postStatus(payload.Status, payload.PublishAt)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if payload.Poll != nil {
+		if err := validatePoll(payload.Poll, payload.PublishAt); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
 	user := getUserFromCtx(r)
+	if !app.checkPostLength(w, r, user, payload.Content) {
+		return
+	}
+	if payload.QuoteOf != nil && !app.checkQuotable(w, r, user, *payload.QuoteOf) {
+		return
+	}
+
+	content := &moderation.Content{
+		Kind:     moderation.KindPost,
+		AuthorId: user.Id,
+		Title:    payload.Title,
+		Body:     payload.Content,
+		Tags:     tags,
+	}
+	// The options of a poll are text everyone reads as well
+	if payload.Poll != nil {
+		content.Body += "\n" + strings.Join(payload.Poll.Options, "\n")
+	}
+	moderated, ok := app.checkContent(w, r, content)
+	if !ok {
+		return
+	}
+
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserId:  user.Id,
+		Title:      payload.Title,
+		Content:    payload.Content,
+		Tags:       tags,
+		UserId:     user.Id,
+		HeldAt:     holdTime(moderated),
+		QuoteOf:    payload.QuoteOf,
+		Status:     status,
+		Visibility: payload.Visibility,
+	}
+	if status == store.PostScheduled {
+		publishAt := payload.PublishAt.UTC().Format(time.RFC3339)
+		post.PublishAt = &publishAt
 	}
 
 	ctx := r.Context()
@@ -67,6 +133,54 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		app.internalServerError(w, r, err)
 		return
 	}
+	if payload.Poll != nil {
+		if err := app.createPoll(ctx, post, payload.Poll); err != nil {
+			// rollback post creation, a post must not lose its poll (SAGA pattern)
+			if err := app.store.Posts.Delete(ctx, post.Id); err != nil {
+				app.logger.Errorw("error deleting post", "error", err)
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if len(payload.MediaIds) > 0 {
+		if err := app.store.Media.AttachToPost(ctx, post.Id, user.Id, payload.MediaIds); err != nil {
+			// rollback post creation if the attachments are invalid (SAGA pattern)
+			if err := app.store.Posts.Delete(ctx, post.Id); err != nil {
+				app.logger.Errorw("error deleting post", "error", err)
+			}
+
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestError(w, r, errors.New("media not found or already attached to a post"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	// The post is complete, so from here on nothing is rolled back and others may hear of it
+	app.recordModerationDecision(ctx, content, &post.Id, moderated)
+	// Nobody is told about drafts and scheduled posts before they are published
+	if post.HeldAt == nil && post.Status == store.PostPublished {
+		app.syncMentions(ctx, user.Id, post.Id, nil, post.Title+"\n"+post.Content)
+	}
+	if post.Status == store.PostPublished {
+		app.enqueueFanOut(post)
+	}
+
+	if len(payload.MediaIds) > 0 {
+		if err := app.attachPostMedia(ctx, post); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := app.attachShares(ctx, user.Id, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -91,12 +205,14 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 	log.Println("Post id: ", post.Id)
 
-	comments, err := app.store.Comments.GetByPostId(ctx, post.Id)
-	if err != nil {
+	if err := app.loadPostDetails(ctx, post, getUserFromCtx(r).Id); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	post.Comments = comments
+
+	if notModified(w, r, app.postResponseETag(post), postLastModified(post)) {
+		return
+	}
 
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -107,7 +223,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
-//	@Description	Delete a post by ID
+//	@Description	Moves a post to the trash of its author, it can be restored until the trash is purged
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -119,9 +235,11 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 //	@Router			/posts/{id} [delete]
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
 
+	// The post goes to the trash of its author, so a wrong delete can still be undone
 	ctx := r.Context()
-	if err := app.store.Posts.Delete(ctx, post.Id); err != nil {
+	if err := app.store.Posts.SoftDelete(ctx, post.Id, user.Id); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, r, err)
@@ -142,10 +260,15 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 //	@Produce		json
 //	@Param			id		path		int					true	"Post ID"
 //	@Param			payload	body		UpdatePostPayload	true	"Post payload"
+//	@Param			If-Match	header	string	false	"ETag of the post the update is based on"
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		412		{object}	error
+//	@Failure		422		{object}	error	"Rejected by the moderation rules"
+//	@Failure		428		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
@@ -164,17 +287,74 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestError(w, r, err)
 		return
 	}
+
+	// The client has to prove it saw the current version, otherwise it would overwrite changes it never saw
+	if !app.checkIfMatch(w, r, postETag(post)) {
+		return
+	}
+	if err := app.loadPostDetails(ctx, post, getUserFromCtx(r).Id); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	oldText := post.Title + "\n" + post.Content
 	if payload.Content != nil {
+		// Moderators may edit the post, the limit is still the one of its author
+		author, err := app.GetUser(ctx, post.UserId)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !app.checkPostLength(w, r, author, *payload.Content) {
+			return
+		}
 		post.Content = *payload.Content
 	}
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	oldVisibility := post.Visibility
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+	post.Tags = editedPostTags(post.Tags, oldText, post.Title+"\n"+post.Content)
+
+	content := &moderation.Content{
+		Id:       post.Id,
+		Kind:     moderation.KindPost,
+		AuthorId: post.UserId,
+		Title:    post.Title,
+		Body:     post.Content,
+		Tags:     post.Tags,
+	}
+	moderated, ok := app.checkContent(w, r, content)
+	if !ok {
+		return
+	}
+	if post.HeldAt == nil {
+		post.HeldAt = holdTime(moderated)
+	}
 
-	if err := app.updatePost(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
+	if err := app.updatePost(ctx, post, getUserFromCtx(r).Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
+	app.recordModerationDecision(ctx, content, &post.Id, moderated)
+	if post.HeldAt == nil && post.Status == store.PostPublished {
+		app.syncMentions(ctx, post.UserId, post.Id, nil, post.Title+"\n"+post.Content)
+		// Timelines drop posts which are no longer visible on read, but the new audience of the
+		// post has to get it pushed
+		if post.Visibility != oldVisibility {
+			app.enqueueFanOut(post)
+		}
+	}
+
+	w.Header().Set("ETag", app.postResponseETag(post))
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -201,21 +381,99 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+
+		visible, err := app.canViewPost(ctx, getUserFromCtx(r), post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// Posts of private accounts do not exist for anyone outside of their followers, posts outside of
+// their audience and held posts not for anyone but their author and moderators, drafts and
+// scheduled posts not for anyone but their author
+func (app *application) canViewPost(ctx context.Context, viewer *store.User, post *store.Post) (bool, error) {
+	if post.Status != store.PostPublished {
+		return post.UserId == viewer.Id, nil
+	}
+
+	visible, err := app.canViewPostsOf(ctx, viewer, post.UserId)
+	if err == nil && visible {
+		visible, err = app.inAudience(ctx, viewer, post)
+	}
+	if err == nil && visible && post.HeldAt != nil {
+		visible, err = app.canViewHeld(ctx, viewer, post.UserId)
+	}
+	return visible, err
+}
+
+// Whether the visibility of the post lets the viewer see it, the privacy of the author is checked
+// separately
+func (app *application) inAudience(ctx context.Context, viewer *store.User, post *store.Post) (bool, error) {
+	if post.Visibility == store.PostPublic || post.UserId == viewer.Id {
+		return true, nil
+	}
+
+	visible := false
+	switch post.Visibility {
+	case store.PostFollowers:
+		relationship, err := app.store.Followers.GetRelationship(ctx, viewer.Id, post.UserId)
+		if err != nil {
+			return false, err
+		}
+		visible = relationship.Following
+	case store.PostCloseFriends:
+		isCloseFriend, err := app.store.CloseFriends.IsCloseFriend(ctx, post.UserId, viewer.Id)
+		if err != nil {
+			return false, err
+		}
+		visible = isCloseFriend
+	}
+	if visible {
+		return true, nil
+	}
+
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
 }
 
-func (app *application) updatePost(ctx context.Context, post *store.Post) error {
-	if err := app.store.Posts.Update(ctx, post); err != nil {
+// Loads the comments, media, bookmark state, shares and poll embedded in the post response, as the viewer sees them
+func (app *application) loadPostDetails(ctx context.Context, post *store.Post, viewerId int64) error {
+	comments, err := app.store.Comments.GetByPostId(ctx, post.Id, viewerId)
+	if err != nil {
+		return err
+	}
+	post.Comments = comments
+
+	if err := app.attachBookmarks(ctx, viewerId, post); err != nil {
+		return err
+	}
+	if err := app.attachShares(ctx, viewerId, post); err != nil {
+		return err
+	}
+	if err := app.attachPolls(ctx, viewerId, post); err != nil {
+		return err
+	}
+	return app.attachPostMedia(ctx, post)
+}
+
+func (app *application) updatePost(ctx context.Context, post *store.Post, editorId int64) error {
+	if err := app.store.Posts.Update(ctx, post, editorId); err != nil {
 		return err
 	}
-	app.cacheStorage.Users.Delete(ctx, post.UserId) // It is doubtful
+	app.invalidateUserCache(ctx, post.UserId) // It is doubtful
 	return nil
 }
 
@@ -241,13 +499,26 @@ func (app *application) getAllPostsHandler(w http.ResponseWriter, r *http.Reques
 
 	ctx := r.Context()
 	user := getUserFromCtx(r)
-	Posts, err := app.store.Posts.GetPostsByUserId(ctx, user.Id, fq)
+	Posts, err := app.store.Posts.GetPostsByUserId(ctx, user.Id, user.Id, fq)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	if err := app.attachFeedMedia(ctx, Posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedDetails(ctx, getUserFromCtx(r).Id, Posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, app.feedETag(Posts), feedLastModified(Posts)) {
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, Posts); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -282,12 +553,25 @@ func (app *application) getAllPostsByUserIdHandler(w http.ResponseWriter, r *htt
 	}
 
 	ctx := r.Context()
-	Posts, err := app.store.Posts.GetPostsByUserId(ctx, userId, fq)
+	Posts, err := app.store.Posts.GetPostsByUserId(ctx, userId, getUserFromCtx(r).Id, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	if err := app.attachFeedMedia(ctx, Posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedDetails(ctx, getUserFromCtx(r).Id, Posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, app.feedETag(Posts), feedLastModified(Posts)) {
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, Posts); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Close friends of the authors listed, the viewer is one of theirs
+type closeFriendOf struct {
+	store.MockCloseFriendsStore
+	authors map[int64]bool
+}
+
+func (c *closeFriendOf) IsCloseFriend(ctx context.Context, userId int64, friendId int64) (bool, error) {
+	return c.authors[userId], nil
+}
+
+func TestCanViewPost(t *testing.T) {
+	app := newTestApplication(t, config{})
+	// The viewer follows 2 and the private account 5, and is a close friend of 2. 4 is private too.
+	app.store.Users = &privateUsers{private: map[int64]bool{4: true, 5: true}}
+	app.store.Followers = &followedUsers{following: map[int64]bool{2: true, 5: true}}
+	app.store.CloseFriends = &closeFriendOf{authors: map[int64]bool{2: true}}
+
+	viewer := &store.User{Id: 1, Role: store.Role{Name: "user", Level: 1}}
+	moderator := &store.User{Id: 9, Role: store.Role{Name: "moderator", Level: 2}}
+	heldAt := "2024-05-01T12:00:00Z"
+	post := func(authorId int64, visibility string) *store.Post {
+		return &store.Post{Id: 10, UserId: authorId, Status: store.PostPublished, Visibility: visibility}
+	}
+	withStatus := func(p *store.Post, status string) *store.Post {
+		p.Status = status
+		return p
+	}
+	held := func(p *store.Post) *store.Post {
+		p.HeldAt = &heldAt
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		viewer *store.User
+		post   *store.Post
+		want   bool
+	}{
+		{"public post", viewer, post(3, store.PostPublic), true},
+		{"own draft", viewer, withStatus(post(1, store.PostPublic), store.PostDraft), true},
+		{"draft of another user", viewer, withStatus(post(2, store.PostPublic), store.PostDraft), false},
+		{"scheduled post of another user", viewer, withStatus(post(2, store.PostPublic), store.PostScheduled), false},
+		{"draft for a moderator", moderator, withStatus(post(2, store.PostPublic), store.PostDraft), false},
+		{"followers post of a followed user", viewer, post(2, store.PostFollowers), true},
+		{"followers post of another user", viewer, post(3, store.PostFollowers), false},
+		{"close friends post of a user who added the viewer", viewer, post(2, store.PostCloseFriends), true},
+		{"close friends post of another user", viewer, post(3, store.PostCloseFriends), false},
+		{"private post of another user", viewer, post(2, store.PostPrivate), false},
+		{"own private post", viewer, post(1, store.PostPrivate), true},
+		{"public post of a private account", viewer, post(4, store.PostPublic), false},
+		{"public post of a followed private account", viewer, post(5, store.PostPublic), true},
+		{"followers post of a followed private account", viewer, post(5, store.PostFollowers), true},
+		{"held post of another user", viewer, held(post(2, store.PostPublic)), false},
+		{"own held post", viewer, held(post(1, store.PostPublic)), true},
+		{"held post for a moderator", moderator, held(post(2, store.PostPublic)), true},
+		{"private post for a moderator", moderator, post(3, store.PostPrivate), true},
+		{"post of a private account for a moderator", moderator, post(4, store.PostFollowers), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.canViewPost(context.Background(), tt.viewer, tt.post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("canViewPost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInAudience(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Followers = &followedUsers{following: map[int64]bool{2: true}}
+	app.store.CloseFriends = &closeFriendOf{authors: map[int64]bool{3: true}}
+
+	viewer := &store.User{Id: 1, Role: store.Role{Name: "user", Level: 1}}
+
+	// The viewer follows 2 and is a close friend of 3, but does not follow 3
+	tests := []struct {
+		visibility string
+		authorId   int64
+		want       bool
+	}{
+		{store.PostPublic, 4, true},
+		{store.PostFollowers, 2, true},
+		{store.PostFollowers, 3, false},
+		{store.PostCloseFriends, 3, true},
+		{store.PostCloseFriends, 2, false},
+		{store.PostPrivate, 2, false},
+		{store.PostPrivate, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.visibility, func(t *testing.T) {
+			post := &store.Post{UserId: tt.authorId, Visibility: tt.visibility}
+			got, err := app.inAudience(context.Background(), viewer, post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("inAudience of a %s post by %d = %v, want %v", tt.visibility, tt.authorId, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type reportKey string
+
+const reportCtx reportKey = "report"
+
+// Lowest role allowed to close a report with the action. They follow the roles which may already
+// update (moderator) and delete (admin) the content of other users.
+var reportActionRoles = map[string]string{
+	store.ReportActionDismiss:       "moderator",
+	store.ReportActionWarn:          "moderator",
+	store.ReportActionDeleteContent: "admin",
+	store.ReportActionSuspend:       "admin",
+}
+
+type CreateReportPayload struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetId   int64  `json:"target_id" validate:"required,gt=0"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate_speech violence nudity misinformation other"`
+	Details    string `json:"details" validate:"max=1000"`
+}
+
+type ResolveReportPayload struct {
+	Action      string `json:"action" validate:"required,oneof=dismiss delete_content warn suspend"`
+	Note        string `json:"note" validate:"max=1000"` // Shown to the author for warnings and suspensions
+	SuspendDays int    `json:"suspend_days" validate:"required_if=Action suspend,omitempty,gte=1,lte=365"`
+}
+
+// CreateReport godoc
+//
+//	@Summary		Reports a post, a comment or a user
+//	@Description	Files a report for the moderators. A user can only have one unresolved report about the same content.
+//	@Tags			reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateReportPayload	true	"Report payload"
+//	@Success		201		{object}	store.Report
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Reported content not found"
+//	@Failure		409		{object}	error	"Already reported"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/reports [post]
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateReportPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+	authorId, err := app.reportTargetAuthor(ctx, user, payload.TargetType, payload.TargetId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if authorId == user.Id {
+		app.badRequestError(w, r, errors.New("you can not report yourself"))
+		return
+	}
+
+	report := &store.Report{
+		ReporterId:   user.Id,
+		TargetType:   payload.TargetType,
+		TargetId:     payload.TargetId,
+		TargetUserId: authorId,
+		Reason:       payload.Reason,
+		Details:      payload.Details,
+	}
+	if err := app.store.Reports.Create(ctx, report); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictError(w, r, errors.New("you already reported this, a moderator will look at it"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetReportQueue godoc
+//
+//	@Summary		Fetches the moderation queue
+//	@Description	Lists reports, the oldest first. Without a status only unresolved reports are listed.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			status		query		string	false	"open, claimed, resolved or dismissed"
+//	@Param			target_type	query		string	false	"post, comment or user"
+//	@Param			reason		query		string	false	"Reason"
+//	@Param			claimed_by	query		int		false	"Only reports claimed by this moderator"
+//	@Param			unclaimed	query		bool	false	"Only reports nobody claimed"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{array}		store.Report
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports [get]
+func (app *application) getReportQueueHandler(w http.ResponseWriter, r *http.Request) {
+	rq := store.ReportQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+	rq, err := rq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(rq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	reports, err := app.store.Reports.GetQueue(r.Context(), rq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, reports); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getReportHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getReportFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ClaimReport godoc
+//
+//	@Summary		Claims a report
+//	@Description	Assigns an unresolved report to the current moderator, so two moderators do not work on it at once. Admins can take over claims of others.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			reportID	path		int	true	"Report ID"
+//	@Success		200			{object}	store.Report
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Resolved or claimed by another moderator"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{reportID}/claim [post]
+func (app *application) claimReportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	report := getReportFromCtx(r)
+	moderator := getUserFromCtx(r)
+
+	force, err := app.checkRolePrecedence(ctx, moderator, "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Reports.Claim(ctx, report.Id, moderator.Id, force); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		case store.ErrConflict:
+			app.conflictError(w, r, errors.New("the report is resolved or claimed by another moderator"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	report, err = app.store.Reports.GetById(ctx, report.Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ResolveReport godoc
+//
+//	@Summary		Resolves a report
+//	@Description	Takes the action and closes the report together with the other unresolved reports about the same content. Deleting content and suspending users needs the admin role. Reporters are notified of the outcome.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			reportID	path		int						true	"Report ID"
+//	@Param			payload		body		ResolveReportPayload	true	"Resolution"
+//	@Success		200			{object}	store.Report
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Resolved or claimed by another moderator"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{reportID}/resolve [post]
+func (app *application) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResolveReportPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	report := getReportFromCtx(r)
+	moderator := getUserFromCtx(r)
+
+	allowed, err := app.checkRolePrecedence(ctx, moderator, reportActionRoles[payload.Action])
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		app.forbidenWarning(w, r)
+		return
+	}
+
+	if payload.Action == store.ReportActionDeleteContent && report.TargetType == store.ReportTargetUser {
+		app.badRequestError(w, r, errors.New("users can not be deleted, suspend them instead"))
+		return
+	}
+	if report.Status != store.ReportStatusOpen && report.Status != store.ReportStatusClaimed ||
+		report.ClaimedBy != nil && *report.ClaimedBy != moderator.Id {
+		app.conflictError(w, r, errors.New("the report is resolved or claimed by another moderator"))
+		return
+	}
+
+	// The action comes first, if it fails the report stays open and can be resolved again
+	if err := app.takeReportAction(ctx, report, moderator, payload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	report.Resolution = &payload.Action
+	report.ResolutionNote = payload.Note
+	report.ResolvedBy = &moderator.Id
+	closed, err := app.store.Reports.Resolve(ctx, report)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictError(w, r, errors.New("the report is resolved or claimed by another moderator"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// The outcome stands even if telling people about it fails
+	if err := app.notifyReportOutcome(ctx, report, closed, payload); err != nil {
+		app.logger.Errorw("error notifying about a report outcome", "report", report.Id, "error", err)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) reportContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reportId, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		report, err := app.store.Reports.GetById(ctx, reportId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, reportCtx, report)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/* Helper Functions */
+
+func getReportFromCtx(r *http.Request) *store.Report {
+	report, _ := r.Context().Value(reportCtx).(*store.Report)
+	return report
+}
+
+// Returns the author of the reported content. Content the reporter can not see does not exist for
+// them, whether its author is private, the post is a draft or its visibility leaves them out.
+func (app *application) reportTargetAuthor(ctx context.Context, reporter *store.User, targetType string, targetId int64) (int64, error) {
+	switch targetType {
+	case store.ReportTargetPost:
+		post, err := app.store.Posts.GetById(ctx, targetId)
+		if err != nil {
+			return 0, err
+		}
+		visible, err := app.canViewPost(ctx, reporter, post)
+		if err != nil {
+			return 0, err
+		}
+		if !visible {
+			return 0, store.ErrNotFound
+		}
+		return post.UserId, nil
+
+	case store.ReportTargetComment:
+		comment, err := app.store.Comments.GetById(ctx, targetId)
+		if err != nil {
+			return 0, err
+		}
+		post, err := app.store.Posts.GetById(ctx, comment.PostId)
+		if err != nil {
+			return 0, err
+		}
+		visible, err := app.canViewPost(ctx, reporter, post)
+		if err != nil {
+			return 0, err
+		}
+		if !visible {
+			return 0, store.ErrNotFound
+		}
+		return comment.UserId, nil
+
+	default:
+		user, err := app.GetUser(ctx, targetId)
+		if err != nil {
+			return 0, err
+		}
+		return user.Id, nil
+	}
+}
+
+func (app *application) takeReportAction(ctx context.Context, report *store.Report, moderator *store.User, payload ResolveReportPayload) error {
+	switch payload.Action {
+	case store.ReportActionDeleteContent:
+		// Content deleted by its author or another moderator in the meantime is gone already
+		var err error
+		if report.TargetType == store.ReportTargetPost {
+			err = app.store.Posts.SoftDelete(ctx, report.TargetId, moderator.Id)
+		} else {
+			err = app.store.Comments.SoftDelete(ctx, report.TargetId, moderator.Id)
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+
+	case store.ReportActionSuspend:
+		until := time.Now().AddDate(0, 0, payload.SuspendDays)
+		if err := app.store.Users.Suspend(ctx, report.TargetUserId, until); err != nil {
+			return err
+		}
+		app.invalidateUserCache(ctx, report.TargetUserId)
+	}
+	return nil
+}
+
+// Tells every reporter how their report ended, and the author what happened to them
+func (app *application) notifyReportOutcome(ctx context.Context, report *store.Report, closed []store.Report, payload ResolveReportPayload) error {
+	outcome := "A moderator reviewed it and took action."
+	if payload.Action == store.ReportActionDismiss {
+		outcome = "A moderator reviewed it and found no violation of the rules."
+	}
+
+	var notifications []*store.Notification
+	notified := map[int64]bool{}
+	for _, c := range closed {
+		if notified[c.ReporterId] {
+			continue
+		}
+		notified[c.ReporterId] = true
+
+		notifications = append(notifications, &store.Notification{
+			UserId:  c.ReporterId,
+			Type:    store.NotificationReportResolved,
+			Message: fmt.Sprintf("Thanks for reporting this %s. %s", c.TargetType, outcome),
+			Data:    reportNotificationData(c.Id, report, payload.Action),
+		})
+	}
+
+	var notificationType, message string
+	switch payload.Action {
+	case store.ReportActionDeleteContent:
+		notificationType = store.NotificationContentRemoved
+		message = fmt.Sprintf("Your %s was removed for breaking the rules (%s).", report.TargetType, report.Reason)
+	case store.ReportActionWarn:
+		notificationType = store.NotificationWarning
+		message = fmt.Sprintf("You received a warning for breaking the rules (%s).", report.Reason)
+	case store.ReportActionSuspend:
+		notificationType = store.NotificationSuspension
+		message = fmt.Sprintf("Your account was suspended for %d days for breaking the rules (%s).", payload.SuspendDays, report.Reason)
+	}
+	if notificationType != "" {
+		if payload.Note != "" {
+			message += " " + payload.Note
+		}
+		notifications = append(notifications, &store.Notification{
+			UserId:  report.TargetUserId,
+			Type:    notificationType,
+			Message: message,
+			Data:    reportNotificationData(report.Id, report, payload.Action),
+		})
+	}
+
+	return app.store.Notifications.Create(ctx, notifications...)
+}
+
+func reportNotificationData(reportId int64, report *store.Report, action string) json.RawMessage {
+	data, _ := json.Marshal(map[string]any{
+		"report_id":   reportId,
+		"target_type": report.TargetType,
+		"target_id":   report.TargetId,
+		"action":      action,
+	})
+	return data
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// RepostPost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares the post into the feeds of the current user's followers. Posts of private accounts can not be reposted, except by their author.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Repost
+//	@Failure		403		{object}	error	"The post can not be reposted"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Reposted already"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [put]
+func (app *application) repostPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	ctx := r.Context()
+	if ok := app.checkShareable(w, r, user, post); !ok {
+		return
+	}
+
+	repost := &store.Repost{UserId: user.Id, PostId: post.Id}
+	if err := app.store.Reposts.Create(ctx, repost); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("the post is reposted already"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.enqueueRepostFanOut(repost)
+
+	if err := app.jsonResponse(w, http.StatusCreated, repost); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UndoRepost godoc
+//
+//	@Summary		Undoes a repost
+//	@Tags			posts
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204
+//	@Failure		404	{object}	error	"The post is not reposted"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) undoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Reposts.Delete(r.Context(), getUserFromCtx(r).Id, getPostFromCtx(r).Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* Helper Functions */
+
+// Checks that the user may repost or quote the post, which must be visible already. Unpublished
+// posts are not shared, held posts not until they are approved, posts which are not public and
+// posts of private accounts only by their author, and blocked users can not share each other's
+// posts. It writes the error response and returns false when the post must not be shared.
+func (app *application) checkShareable(w http.ResponseWriter, r *http.Request, user *store.User, post *store.Post) bool {
+	if post.Status != store.PostPublished {
+		app.forbiddenError(w, r, errors.New("the post is not published yet"))
+		return false
+	}
+	if post.HeldAt != nil {
+		app.forbiddenError(w, r, errors.New("the post is waiting for a moderator"))
+		return false
+	}
+
+	ctx := r.Context()
+	blocked, err := app.store.Blocks.IsBlocked(ctx, user.Id, post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+	if blocked {
+		app.forbiddenError(w, r, store.ErrBlocked)
+		return false
+	}
+
+	if post.UserId == user.Id {
+		return true
+	}
+	if post.Visibility != store.PostPublic {
+		app.forbiddenError(w, r, errors.New("only public posts can be shared"))
+		return false
+	}
+	author, err := app.GetUser(ctx, post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+	if author.IsPrivate {
+		app.forbiddenError(w, r, errors.New("posts of private accounts can not be shared"))
+		return false
+	}
+	return true
+}
+
+// Checks that the user may quote the post with the id, a post they can not see does not exist for
+// them. It writes the error response and returns false when the post must not be quoted.
+func (app *application) checkQuotable(w http.ResponseWriter, r *http.Request, user *store.User, postId int64) bool {
+	ctx := r.Context()
+	quoted, err := app.store.Posts.GetById(ctx, postId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestError(w, r, errors.New("the quoted post does not exist"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+
+	visible, err := app.canViewPost(ctx, user, quoted)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+	if !visible {
+		app.badRequestError(w, r, errors.New("the quoted post does not exist"))
+		return false
+	}
+	return app.checkShareable(w, r, user, quoted)
+}
+
+// Loads the repost and quote counts of the posts and what the viewer may see of the posts they
+// quote, with two queries
+func (app *application) attachShares(ctx context.Context, viewerId int64, posts ...*store.Post) error {
+	postIds := make([]int64, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.Id
+	}
+
+	stats, err := app.store.Reposts.GetStats(ctx, viewerId, postIds)
+	if err != nil {
+		return err
+	}
+	quoted, err := app.store.Reposts.GetQuoted(ctx, viewerId, postIds)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		st := stats[post.Id]
+		post.RepostCount = st.Reposts
+		post.QuoteCount = st.Quotes
+		post.Reposted = st.Reposted
+		post.RepostedBy = st.RepostedBy
+
+		if q, ok := quoted[post.Id]; ok {
+			post.QuoteOf = &q.Id
+			post.Quoted = &q
+		}
+	}
+	return nil
+}
+
+// Loads what a page of posts shows about each post for the viewer, besides the media
+func (app *application) attachFeedDetails(ctx context.Context, viewerId int64, feed []store.PostWithMetaData) error {
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	if err := app.attachBookmarks(ctx, viewerId, posts...); err != nil {
+		return err
+	}
+	if err := app.attachShares(ctx, viewerId, posts...); err != nil {
+		return err
+	}
+	return app.attachPolls(ctx, viewerId, posts...)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+func TestCheckShareable(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Users = &privateUsers{private: map[int64]bool{4: true}}
+	app.store.Blocks = &blockedUsers{blocked: map[int64]bool{3: true}}
+
+	user := &store.User{Id: 1}
+	heldAt := "2024-05-01T12:00:00Z"
+	post := func(authorId int64, visibility string) *store.Post {
+		return &store.Post{Id: 10, UserId: authorId, Status: store.PostPublished, Visibility: visibility}
+	}
+
+	tests := []struct {
+		name string
+		post *store.Post
+		want bool
+	}{
+		{"should share public posts", post(2, store.PostPublic), true},
+		{"should share own posts whatever their visibility", post(1, store.PostCloseFriends), true},
+		{"should not share drafts", &store.Post{UserId: 1, Status: store.PostDraft, Visibility: store.PostPublic}, false},
+		{"should not share scheduled posts", &store.Post{UserId: 2, Status: store.PostScheduled, Visibility: store.PostPublic}, false},
+		{"should not share held posts", &store.Post{UserId: 2, Status: store.PostPublished, Visibility: store.PostPublic, HeldAt: &heldAt}, false},
+		{"should not share posts of blocked users", post(3, store.PostPublic), false},
+		{"should not share followers posts of others", post(2, store.PostFollowers), false},
+		{"should not share close friends posts of others", post(2, store.PostCloseFriends), false},
+		{"should not share private posts of others", post(2, store.PostPrivate), false},
+		{"should not share posts of private accounts", post(4, store.PostPublic), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/posts/10/repost", nil)
+
+			if got := app.checkShareable(w, r, user, tt.post); got != tt.want {
+				t.Errorf("checkShareable = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				checkResponseCode(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
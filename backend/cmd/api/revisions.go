@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/diff"
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// Lines of unchanged text shown around every change of a unified diff
+const diffContextLines = 3
+
+type PostDiff struct {
+	PostId  int64       `json:"post_id"`
+	From    int64       `json:"from"`
+	To      int64       `json:"to"`
+	Title   []diff.Line `json:"title"`
+	Content []diff.Line `json:"content"`
+	Unified string      `json:"unified"` // Title and content changes in the unified diff format
+}
+
+// GetPostRevisions godoc
+//
+//	@Summary		Fetches the edit history of a post
+//	@Description	Lists every version of a post, the oldest first. The last entry is the current version.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{array}		store.PostRevision
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	// Every edit adds a revision and bumps the version, so the list can be validated without reading it
+	if notModified(w, r, fmt.Sprintf(`W/"r%d-v%d"`, post.Id, post.Version), time.Time{}) {
+		return
+	}
+
+	revisions, err := app.store.PostRevisions.GetByPostId(r.Context(), post.Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getPostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	version, err := strconv.ParseInt(chi.URLParam(r, "version"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// Stored versions never change, a client holding one does not need it again
+	if notModified(w, r, fmt.Sprintf(`"r%d-%d"`, post.Id, version), time.Time{}) {
+		return
+	}
+
+	revision, err := app.store.PostRevisions.GetByVersion(r.Context(), post.Id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revision); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DiffPostRevisions godoc
+//
+//	@Summary		Compares two versions of a post
+//	@Description	Returns a line diff of the title and the content between two versions. "to" defaults to the current version.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id		path		int	true	"Post ID"
+//	@Param			from	query		int	true	"Older version"
+//	@Param			to		query		int	false	"Newer version"
+//	@Success		200		{object}	PostDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	qs := r.URL.Query()
+
+	from, err := strconv.ParseInt(qs.Get("from"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("from must be a version number"))
+		return
+	}
+	to := post.Version
+	if qs.Get("to") != "" {
+		if to, err = strconv.ParseInt(qs.Get("to"), 10, 64); err != nil {
+			app.badRequestError(w, r, errors.New("to must be a version number"))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	var revisions [2]*store.PostRevision
+	for i, version := range []int64{from, to} {
+		revisions[i], err = app.store.PostRevisions.GetByVersion(ctx, post.Id, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, fmt.Errorf("version %d: %w", version, err))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	response := PostDiff{
+		PostId:  post.Id,
+		From:    from,
+		To:      to,
+		Title:   diff.Lines(revisions[0].Title, revisions[1].Title),
+		Content: diff.Lines(revisions[0].Content, revisions[1].Content),
+	}
+	fromName := fmt.Sprintf("post %d version %d", post.Id, from)
+	toName := fmt.Sprintf("post %d version %d", post.Id, to)
+	response.Unified = diff.Unified(fromName+" title", toName+" title", response.Title, diffContextLines) +
+		diff.Unified(fromName+" content", toName+" content", response.Content, diffContextLines)
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Sumitwarrior7/social/internal/extract"
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts with a tag
+//	@Description	Lists the posts tagged with the tag or mentioning it as a #hashtag, with the same filters as the feed
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag, with or without the #"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{array}		store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag} [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := extract.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestError(w, r, fmt.Errorf("invalid tag %q", chi.URLParam(r, "tag")))
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	posts, err := app.store.Tags.GetPosts(ctx, tag, getUserFromCtx(r).Id, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachFeedMedia(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedDetails(ctx, getUserFromCtx(r).Id, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, app.feedETag(posts), feedLastModified(posts)) {
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserMentions godoc
+//
+//	@Summary		Fetches the mentions of a user
+//	@Description	Lists the posts and comments mentioning the user which the current user can see, the newest first unless sort=asc
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.Mention
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/mentions [get]
+func (app *application) getUserMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	mentions, err := app.store.Mentions.GetByUserId(r.Context(), getProfileFromCtx(r).Id, getUserFromCtx(r).Id, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, mentions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+/* Helper Functions */
+
+// Normalises the tags sent with a post, an invalid tag is an error
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, ok := extract.NormalizeTag(tag)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q, tags are letters, digits and underscores", tag)
+		}
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
+
+// Returns the tags of an edited post. Tags which came from hashtags of the old text are replaced by
+// the hashtags of the new one, the tags sent separately are kept.
+func editedPostTags(tags []string, oldText string, newText string) []string {
+	derived := map[string]bool{}
+	for _, tag := range extract.Hashtags(oldText) {
+		derived[tag] = true
+	}
+
+	explicit := []string{}
+	for _, tag := range tags {
+		if !derived[tag] {
+			explicit = append(explicit, tag)
+		}
+	}
+	return extract.MergeTags(explicit, extract.Hashtags(newText))
+}
+
+// Stores the mentions of a post or comment and notifies the users mentioned for the first time.
+// The content is stored already, so failures are only logged. Held content is synced once a
+// moderator approves it, nobody is told about content they can not see yet.
+func (app *application) syncMentions(ctx context.Context, authorId int64, postId int64, commentId *int64, text string) {
+	mentioned, err := app.store.Mentions.Sync(ctx, authorId, postId, commentId, extract.Mentions(text))
+	if err != nil {
+		app.logger.Errorw("error storing mentions", "post", postId, "error", err)
+		return
+	}
+	if len(mentioned) == 0 {
+		return
+	}
+
+	author, err := app.GetUser(ctx, authorId)
+	if err != nil {
+		app.logger.Errorw("error notifying mentioned users", "post", postId, "error", err)
+		return
+	}
+
+	kind := "post"
+	data := map[string]any{"post_id": postId}
+	if commentId != nil {
+		kind = "comment"
+		data["comment_id"] = *commentId
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		app.logger.Errorw("error encoding a mention notification", "error", err)
+		return
+	}
+
+	notifications := make([]*store.Notification, 0, len(mentioned))
+	for _, userId := range mentioned {
+		notifications = append(notifications, &store.Notification{
+			UserId:  userId,
+			ActorId: &author.Id,
+			Type:    store.NotificationMention,
+			Message: fmt.Sprintf("@%s mentioned you in a %s.", author.Username, kind),
+			Data:    encoded,
+		})
+	}
+	if err := app.store.Notifications.Create(ctx, notifications...); err != nil {
+		app.logger.Errorw("error notifying mentioned users", "post", postId, "error", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/Sumitwarrior7/social/internal/auth"
+	"github.com/Sumitwarrior7/social/internal/moderation"
 	"github.com/Sumitwarrior7/social/internal/ratelimiter"
 	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/Sumitwarrior7/social/internal/store/cache"
@@ -39,6 +40,11 @@ func newTestApplication(t *testing.T, cfg config) *application {
 
 	testAuth := &auth.TestAuthenticator{}
 
+	// Posts as long as in production unless the test sets its own limits
+	if cfg.postMaxLength == nil {
+		cfg.postMaxLength = map[string]int{"user": 5000}
+	}
+
 	// Rate limiter
 	rateLimiter := ratelimiter.NewFixedWindowRateLimiter(
 		cfg.rateLimiter.RequestsPerTimeFrame,
@@ -52,6 +58,7 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		authenticator: testAuth,
 		config:        cfg,
 		rateLimiter:   rateLimiter,
+		moderation:    moderation.NewPipeline(),
 	}
 }
 
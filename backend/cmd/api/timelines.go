@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/Sumitwarrior7/social/internal/store/cache"
+)
+
+// A post waiting to be pushed to timelines
+type fanOutJob struct {
+	entry        store.TimelineEntry
+	authorOnly   bool // Held and private posts only reach the timeline of their author
+	closeFriends bool // Posts for close friends only reach their timelines and the author's
+}
+
+// Queues the post for the timelines of its author and, unless it is held, of its audience. The
+// timelines which miss it because the queue is full get it when they expire and are rebuilt.
+func (app *application) enqueueFanOut(post *store.Post) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	// Timelines are ordered like the feed, by when the post was published
+	var publishedAt time.Time
+	if post.PublishedAt != nil {
+		publishedAt = parseTimestamp(*post.PublishedAt)
+	}
+	if publishedAt.IsZero() {
+		publishedAt = time.Now()
+	}
+	job := fanOutJob{
+		entry:        store.TimelineEntry{PostId: post.Id, AuthorId: post.UserId, CreatedAt: publishedAt},
+		authorOnly:   post.HeldAt != nil || post.Visibility == store.PostPrivate,
+		closeFriends: post.Visibility == store.PostCloseFriends,
+	}
+
+	app.queueFanOut(job)
+}
+
+// Queues a repost for the timelines of the reposter and their followers, where it moves the post up
+func (app *application) enqueueRepostFanOut(repost *store.Repost) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	createdAt := parseTimestamp(repost.CreatedAt)
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	app.queueFanOut(fanOutJob{
+		entry: store.TimelineEntry{PostId: repost.PostId, AuthorId: repost.UserId, CreatedAt: createdAt},
+	})
+}
+
+func (app *application) queueFanOut(job fanOutJob) {
+	select {
+	case app.timelineQueue <- job:
+	default:
+		app.logger.Warnw("timeline queue is full, fan-out skipped", "post", job.entry.PostId)
+	}
+}
+
+// Pushes the queued posts to timelines one at a time, it blocks until the context is cancelled
+func (app *application) runTimelineFanOut(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-app.timelineQueue:
+			if err := app.fanOut(ctx, job); err != nil {
+				app.logger.Errorw("error pushing a post to timelines", "post", job.entry.PostId, "error", err)
+			}
+		}
+	}
+}
+
+// Posts of accounts with a lot of followers are not pushed to each of them, their followers merge
+// them in when they read their timeline instead
+func (app *application) fanOut(ctx context.Context, job fanOutJob) error {
+	authorId := job.entry.AuthorId
+	if job.authorOnly {
+		return app.cacheStorage.Timelines.Push(ctx, job.entry, []int64{authorId})
+	}
+	// Only close friends get the post, the ones who do not follow the author drop it on read
+	if job.closeFriends {
+		friendIds, err := app.store.CloseFriends.GetIds(ctx, authorId)
+		if err != nil {
+			return err
+		}
+		return app.cacheStorage.Timelines.Push(ctx, job.entry, append(friendIds, authorId))
+	}
+
+	counts, err := app.store.Followers.GetCounts(ctx, authorId)
+	if err != nil {
+		return err
+	}
+	if counts.Followers >= int64(app.config.timeline.largeAccountFollowers) {
+		if err := app.cacheStorage.Timelines.AddLargeAccount(ctx, authorId); err != nil {
+			return err
+		}
+		return app.cacheStorage.Timelines.Push(ctx, job.entry, []int64{authorId})
+	}
+
+	followerIds, err := app.store.Followers.GetFollowerIds(ctx, authorId)
+	if err != nil {
+		return err
+	}
+	return app.cacheStorage.Timelines.Push(ctx, job.entry, append(followerIds, authorId))
+}
+
+// Serves a page of the chronological feed from the timeline of the user. ok is false when the
+// page can not come from the timeline, because Redis is disabled or failed, the feed is searched
+// or read from the oldest post, or the page lies beyond the length of timelines.
+func (app *application) getTimelineFeed(ctx context.Context, userId int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetaData, bool) {
+	if !app.config.redisCfg.enabled || fq.Sort != "desc" || fq.Search != "" {
+		return nil, false
+	}
+	// Twice the page is read at first so posts the feed filters out do not leave it short. While
+	// they still do, more of the timeline is read until it runs out.
+	count := fq.Offset + fq.Limit*2
+	if count > cache.TimelineMaxLength {
+		return nil, false
+	}
+
+	largeAccounts, err := app.cacheStorage.Timelines.GetLargeAccounts(ctx)
+	if err != nil {
+		app.logger.Errorw("error reading large accounts", "error", err)
+		return nil, false
+	}
+
+	for {
+		postIds, exhausted, err := app.readTimeline(ctx, userId, largeAccounts, count)
+		if err != nil {
+			app.logger.Errorw("error reading a timeline", "user", userId, "error", err)
+			return nil, false
+		}
+
+		feed, err := app.store.Posts.GetFeedByIds(ctx, userId, postIds, fq)
+		if err != nil {
+			app.logger.Errorw("error loading timeline posts", "user", userId, "error", err)
+			return nil, false
+		}
+		if len(feed) >= fq.Limit || exhausted {
+			return feed, true
+		}
+		// The rest of the page is older than any timeline holds, only the database has it
+		if count == cache.TimelineMaxLength {
+			return nil, false
+		}
+		count = min(count*2, cache.TimelineMaxLength)
+	}
+}
+
+/* Helper Functions */
+
+// Returns the ids of the newest count posts of the timeline of the user, with the posts of the
+// large accounts they follow merged in. The timeline is rebuilt when it is gone. exhausted is true
+// when there are fewer posts than count, so reading more finds nothing new.
+func (app *application) readTimeline(ctx context.Context, userId int64, largeAccounts []int64, count int) ([]int64, bool, error) {
+	entries, found, err := app.cacheStorage.Timelines.Get(ctx, userId, count)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		entries, err = app.rebuildTimeline(ctx, userId)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	exhausted := len(entries) < count
+
+	if len(largeAccounts) > 0 {
+		pulled, err := app.store.Posts.GetTimelineEntriesOf(ctx, userId, largeAccounts, count)
+		if err != nil {
+			return nil, false, err
+		}
+		exhausted = exhausted && len(pulled) < count
+		entries = append(entries, pulled...)
+	}
+
+	// The store orders the posts again, so the merged entries only need to be unique
+	seen := make(map[int64]bool, len(entries))
+	postIds := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		if !seen[e.PostId] {
+			seen[e.PostId] = true
+			postIds = append(postIds, e.PostId)
+		}
+	}
+	return postIds, exhausted, nil
+}
+
+// Builds the timeline of the user again from the database and returns its entries
+func (app *application) rebuildTimeline(ctx context.Context, userId int64) ([]store.TimelineEntry, error) {
+	entries, err := app.store.Posts.GetTimelineEntries(ctx, userId, cache.TimelineMaxLength)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.cacheStorage.Timelines.Rebuild(ctx, userId, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Drops the timeline of the user after they started or stopped following, muting or blocking
+// someone, the next read rebuilds it from the accounts they follow now
+func (app *application) invalidateTimeline(ctx context.Context, userId int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+	app.cacheStorage.Timelines.Delete(ctx, userId)
+}
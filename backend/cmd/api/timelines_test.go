@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/Sumitwarrior7/social/internal/store/cache"
+)
+
+// Followers of the author as the fan-out reads them
+type fanOutFollowers struct {
+	store.MockFollowersStore
+	followerIds []int64
+}
+
+func (f *fanOutFollowers) GetCounts(ctx context.Context, userId int64) (*store.FollowCounts, error) {
+	return &store.FollowCounts{Followers: int64(len(f.followerIds))}, nil
+}
+
+func (f *fanOutFollowers) GetFollowerIds(ctx context.Context, userId int64) ([]int64, error) {
+	return f.followerIds, nil
+}
+
+// Close friends of the author as the fan-out reads them
+type fanOutCloseFriends struct {
+	store.MockCloseFriendsStore
+	friendIds []int64
+}
+
+func (f *fanOutCloseFriends) GetIds(ctx context.Context, userId int64) ([]int64, error) {
+	return f.friendIds, nil
+}
+
+func TestFanOut(t *testing.T) {
+	entry := store.TimelineEntry{PostId: 7, AuthorId: 1}
+
+	tests := []struct {
+		name      string
+		job       fanOutJob
+		followers []int64
+		// Who gets the post pushed, the author always does
+		want         []int64
+		largeAccount bool
+	}{
+		{
+			name:      "should push to the followers",
+			job:       fanOutJob{entry: entry},
+			followers: []int64{2, 3},
+			want:      []int64{2, 3, 1},
+		},
+		{
+			name:      "should push held and private posts to the author only",
+			job:       fanOutJob{entry: entry, authorOnly: true},
+			followers: []int64{2, 3},
+			want:      []int64{1},
+		},
+		{
+			name:      "should push close friends posts to the close friends only",
+			job:       fanOutJob{entry: entry, closeFriends: true},
+			followers: []int64{2, 3},
+			want:      []int64{4, 1},
+		},
+		{
+			name:         "should leave the followers of large accounts to merge the post on read",
+			job:          fanOutJob{entry: entry},
+			followers:    []int64{2, 3, 5},
+			want:         []int64{1},
+			largeAccount: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{timeline: timelineConfig{largeAccountFollowers: 3}})
+			app.store.Followers = &fanOutFollowers{followerIds: tt.followers}
+			app.store.CloseFriends = &fanOutCloseFriends{friendIds: []int64{4}}
+
+			timelines := app.cacheStorage.Timelines.(*cache.MockTimelineStore)
+			timelines.On("Push", entry, tt.want).Return(nil).Once()
+			if tt.largeAccount {
+				timelines.On("AddLargeAccount", entry.AuthorId).Return(nil).Once()
+			}
+
+			if err := app.fanOut(context.Background(), tt.job); err != nil {
+				t.Fatal(err)
+			}
+			timelines.AssertExpectations(t)
+			if !tt.largeAccount {
+				timelines.AssertNotCalled(t, "AddLargeAccount", entry.AuthorId)
+			}
+		})
+	}
+}
+
+// Posts of the timeline as the feed shows them, only the ones listed pass its filters
+type visiblePosts struct {
+	store.MockPostsStore
+	visible map[int64]bool
+}
+
+func (p *visiblePosts) GetFeedByIds(ctx context.Context, userId int64, postIds []int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetaData, error) {
+	var feed []store.PostWithMetaData
+	for _, id := range postIds {
+		if p.visible[id] {
+			feed = append(feed, store.PostWithMetaData{Post: store.Post{Id: id}})
+		}
+	}
+	if fq.Offset >= len(feed) {
+		return nil, nil
+	}
+	feed = feed[fq.Offset:]
+	return feed[:min(fq.Limit, len(feed))], nil
+}
+
+// Entries of a timeline holding the posts from the newest id down to 1
+func timelineEntries(newest int) []store.TimelineEntry {
+	entries := make([]store.TimelineEntry, newest)
+	for i := range entries {
+		entries[i] = store.TimelineEntry{PostId: int64(newest - i)}
+	}
+	return entries
+}
+
+func TestGetTimelineFeed(t *testing.T) {
+	fq := store.PaginatedFeedQuery{Limit: 2, Sort: "desc"}
+
+	tests := []struct {
+		name    string
+		fq      store.PaginatedFeedQuery
+		visible map[int64]bool
+		// The entries read for each count, the timeline holds what the largest count finds
+		reads  map[int][]store.TimelineEntry
+		want   []int64
+		wantOk bool
+	}{
+		{
+			name:    "should serve the page from the first read",
+			fq:      fq,
+			visible: map[int64]bool{20: true, 19: true, 18: true},
+			reads:   map[int][]store.TimelineEntry{4: timelineEntries(20)[:4]},
+			want:    []int64{20, 19},
+			wantOk:  true,
+		},
+		{
+			name: "should read on while muted posts fill more than a page",
+			fq:   fq,
+			// Posts 20 to 13 are by muted users
+			visible: map[int64]bool{12: true, 11: true, 10: true},
+			reads: map[int][]store.TimelineEntry{
+				4:  timelineEntries(20)[:4],
+				8:  timelineEntries(20)[:8],
+				16: timelineEntries(20)[:16],
+			},
+			want:   []int64{12, 11},
+			wantOk: true,
+		},
+		{
+			name:    "should serve a short page when the timeline runs out",
+			fq:      fq,
+			visible: map[int64]bool{1: true},
+			reads: map[int][]store.TimelineEntry{
+				4: timelineEntries(5)[:4],
+				8: timelineEntries(5),
+			},
+			want:   []int64{1},
+			wantOk: true,
+		},
+		{
+			name: "should fall back to the database past the length of timelines",
+			fq:   store.PaginatedFeedQuery{Limit: 2, Offset: cache.TimelineMaxLength - 4, Sort: "desc"},
+			reads: map[int][]store.TimelineEntry{
+				cache.TimelineMaxLength: timelineEntries(cache.TimelineMaxLength),
+			},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config{}
+			cfg.redisCfg.enabled = true
+			app := newTestApplication(t, cfg)
+			app.store.Posts = &visiblePosts{visible: tt.visible}
+
+			timelines := app.cacheStorage.Timelines.(*cache.MockTimelineStore)
+			timelines.On("GetLargeAccounts").Return(nil, nil)
+			for count, entries := range tt.reads {
+				timelines.On("Get", int64(1), count).Return(entries, true, nil).Once()
+			}
+
+			feed, ok := app.getTimelineFeed(context.Background(), 1, tt.fq)
+			if ok != tt.wantOk {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOk)
+			}
+			var got []int64
+			for _, p := range feed {
+				got = append(got, p.Id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got posts %v, want %v", got, tt.want)
+			}
+			timelines.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// Posts are purged in batches, so a large backlog does not hold one huge transaction
+const trashPurgeBatchSize = 100
+
+type TrashResponse struct {
+	Posts         []store.Post    `json:"posts"`
+	Comments      []store.Comment `json:"comments"`
+	RetentionDays int             `json:"retention_days"` // Items are deleted for good this many days after they were trashed
+}
+
+// GetTrash godoc
+//
+//	@Summary		Fetches the trash of the user
+//	@Description	Lists the deleted posts and comments of the user which can still be restored
+//	@Tags			trash
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	TrashResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash [get]
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		// Default Paginated Values
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	posts, err := app.store.Posts.GetTrash(ctx, user.Id, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	comments, err := app.store.Comments.GetTrash(ctx, user.Id, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := TrashResponse{
+		Posts:         posts,
+		Comments:      comments,
+		RetentionDays: int(app.config.trash.retention.Hours() / 24),
+	}
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Authors can restore the posts they deleted themselves, posts removed by someone else need an admin
+//	@Tags			trash
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	store.Post
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash/posts/{id}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	post, err := app.store.Posts.GetDeletedById(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canRestore(ctx, getUserFromCtx(r), post.UserId, post.DeletedBy)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		app.forbidenWarning(w, r)
+		return
+	}
+
+	if err := app.store.Posts.Restore(ctx, post.Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	post.DeletedAt = nil
+	post.DeletedBy = nil
+
+	if err := app.attachPostMedia(ctx, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestoreComment godoc
+//
+//	@Summary		Restores a deleted comment
+//	@Description	Authors can restore the comments they deleted themselves, comments removed by someone else need an admin
+//	@Tags			trash
+//	@Produce		json
+//	@Param			id	path		int	true	"Comment ID"
+//	@Success		200	{object}	store.Comment
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash/comments/{id}/restore [post]
+func (app *application) restoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "commentId"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	comment, err := app.store.Comments.GetDeletedById(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canRestore(ctx, getUserFromCtx(r), comment.UserId, comment.DeletedBy)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		app.forbidenWarning(w, r)
+		return
+	}
+
+	if err := app.store.Comments.Restore(ctx, comment.Id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	comment.DeletedAt = nil
+	comment.DeletedBy = nil
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Authors may undo their own deletes. Content removed by somebody else (a moderator) can only be
+// brought back by an admin, the same role that is allowed to delete other users' content.
+func (app *application) canRestore(ctx context.Context, user *store.User, ownerId int64, deletedBy *int64) (bool, error) {
+	if ownerId == user.Id && deletedBy != nil && *deletedBy == user.Id {
+		return true, nil
+	}
+	return app.checkRolePrecedence(ctx, user, "admin")
+}
+
+// Permanently removes trashed content older than the retention, it blocks until the context is cancelled
+func (app *application) runTrashPurge(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := time.Now().Add(-app.config.trash.retention)
+
+		count, err := app.store.Comments.PurgeDeleted(ctx, before)
+		if err != nil {
+			app.logger.Errorw("error purging deleted comments", "error", err)
+		} else if count > 0 {
+			app.logger.Infow("deleted comments purged", "count", count)
+		}
+
+		if err := app.purgePosts(ctx, before); err != nil {
+			app.logger.Errorw("error purging deleted posts", "error", err)
+		}
+	}
+}
+
+func (app *application) purgePosts(ctx context.Context, before time.Time) error {
+	for {
+		ids, err := app.store.Posts.GetPurgeableIds(ctx, before, trashPurgeBatchSize)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		// The media records go away with their posts, so they are read first to release the blobs afterwards
+		media, err := app.store.Media.GetByPostIds(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		purged, err := app.store.Posts.Purge(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, id := range purged {
+			for i := range media[id] {
+				app.releaseMediaBlobs(ctx, &media[id][i])
+			}
+		}
+		app.logger.Infow("deleted posts purged", "count", len(purged))
+
+		if len(ids) < trashPurgeBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// A post of user 1 in the trash, deleted by the user given
+type trashedPost struct {
+	store.MockPostsStore
+	deletedBy int64
+}
+
+func (p *trashedPost) GetDeletedById(ctx context.Context, postId int64) (*store.Post, error) {
+	if postId != 10 {
+		return nil, store.ErrNotFound
+	}
+	return &store.Post{Id: postId, UserId: 1, DeletedBy: &p.deletedBy}, nil
+}
+
+// Trashed posts waiting for the purge, the batches purged are remembered
+type purgeablePosts struct {
+	store.MockPostsStore
+	left    int
+	batches []int
+}
+
+func (p *purgeablePosts) GetPurgeableIds(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	ids := []int64{}
+	for i := 0; i < p.left && i < limit; i++ {
+		ids = append(ids, int64(i+1))
+	}
+	return ids, nil
+}
+
+func (p *purgeablePosts) Purge(ctx context.Context, postIds []int64) ([]int64, error) {
+	p.left -= len(postIds)
+	p.batches = append(p.batches, len(postIds))
+	return postIds, nil
+}
+
+func TestCanRestore(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	user := &store.User{Id: 1, Role: store.Role{Name: "user", Level: 1}}
+	moderator := &store.User{Id: 2, Role: store.Role{Name: "moderator", Level: 2}}
+	admin := &store.User{Id: 3, Role: store.Role{Name: "admin", Level: 3}}
+	by := func(id int64) *int64 {
+		return &id
+	}
+
+	tests := []struct {
+		name      string
+		user      *store.User
+		ownerId   int64
+		deletedBy *int64
+		want      bool
+	}{
+		{"author who deleted the content", user, 1, by(1), true},
+		{"author of content removed by a moderator", user, 1, by(2), false},
+		{"author of content without a deleter", user, 1, nil, false},
+		{"user who deleted the content of others", user, 4, by(1), false},
+		{"moderator who removed the content", moderator, 1, by(2), false},
+		{"admin", admin, 1, by(2), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.canRestore(context.Background(), tt.user, tt.ownerId, tt.deletedBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("canRestore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		deletedBy int64
+		want      int
+	}{
+		{"should restore posts the author deleted", "/v1/trash/posts/10/restore", 1, http.StatusOK},
+		{"should not restore posts a moderator removed", "/v1/trash/posts/10/restore", 2, http.StatusForbidden},
+		{"should not find posts outside the trash", "/v1/trash/posts/11/restore", 1, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{})
+			app.store.Posts = &trashedPost{deletedBy: tt.deletedBy}
+			mux := app.mount()
+
+			testToken, err := app.authenticator.GenerateToken(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestPurgePosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		trashed int
+		want    []int
+	}{
+		{"should not purge an empty trash", 0, nil},
+		{"should purge a small trash at once", 30, []int{30}},
+		{"should purge a large trash in batches", 250, []int{100, 100, 50}},
+		{"should purge a trash of whole batches", 200, []int{100, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, config{})
+			posts := &purgeablePosts{left: tt.trashed}
+			app.store.Posts = posts
+
+			if err := app.purgePosts(context.Background(), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if posts.left != 0 {
+				t.Errorf("%d posts left in the trash", posts.left)
+			}
+			if len(posts.batches) != len(tt.want) {
+				t.Fatalf("purged in batches %v, want %v", posts.batches, tt.want)
+			}
+			for i := range tt.want {
+				if posts.batches[i] != tt.want[i] {
+					t.Errorf("purged in batches %v, want %v", posts.batches, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Windows trending is computed over, the half-life keeps a post from dominating its whole window
+var trendingWindows = map[string]store.TrendingWindow{
+	"1h":  {Name: "1h", Span: time.Hour, HalfLife: time.Minute * 15},
+	"24h": {Name: "24h", Span: time.Hour * 24, HalfLife: time.Hour * 6},
+	"7d":  {Name: "7d", Span: time.Hour * 24 * 7, HalfLife: time.Hour * 36},
+}
+
+type TrendingResponse struct {
+	Window string                   `json:"window"`
+	Tags   []store.TrendingTag      `json:"tags"`
+	Posts  []store.PostWithMetaData `json:"posts"`
+}
+
+// GetTrending godoc
+//
+//	@Summary		Fetches the trending tags and posts
+//	@Description	Lists the tags and posts with the most fresh engagement in the window. The rankings are recomputed every few minutes.
+//	@Tags			trending
+//	@Produce		json
+//	@Param			window	query		string	false	"1h, 24h or 7d, 24h by default"
+//	@Param			limit	query		int		false	"At most how many tags and posts, 20 by default"
+//	@Success		200		{object}	TrendingResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trending [get]
+func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	window := "24h"
+	if v := qs.Get("window"); v != "" {
+		window = v
+	}
+	if _, ok := trendingWindows[window]; !ok {
+		app.badRequestError(w, r, fmt.Errorf("invalid window %q, it must be 1h, 24h or 7d", window))
+		return
+	}
+
+	limit := 20
+	if l := qs.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > app.config.trending.limit {
+			app.badRequestError(w, r, fmt.Errorf("limit must be between 1 and %d", app.config.trending.limit))
+			return
+		}
+		limit = n
+	}
+
+	ctx := r.Context()
+	tags, err := app.store.Trending.GetTags(ctx, window, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	posts, err := app.store.Trending.GetPosts(ctx, window, getUserFromCtx(r).Id, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedMedia(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedDetails(ctx, getUserFromCtx(r).Id, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, TrendingResponse{Window: window, Tags: tags, Posts: posts}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+/* Helper Functions */
+
+// Recomputes the rankings of every window right away and then on each interval, it blocks until
+// the context is cancelled
+func (app *application) runTrendingJob(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trending.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, window := range trendingWindows {
+			if err := app.store.Trending.Refresh(ctx, window, app.config.trending.limit, app.config.trending.authorCap); err != nil {
+				app.logger.Errorw("error computing trending", "window", window.Name, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
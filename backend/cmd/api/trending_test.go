@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetTrending(t *testing.T) {
+	app := newTestApplication(t, config{trending: trendingConfig{limit: 50}})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"should reject unknown windows", "?window=2d"},
+		{"should reject a limit below one", "?limit=0"},
+		{"should reject a limit above the kept rankings", "?limit=51"},
+		{"should reject a limit which is not a number", "?limit=ten"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/trending"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+/* Resumable uploads following the tus protocol (https://tus.io/protocols/resumable-upload) */
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	tusChunkType  = "application/offset+octet-stream"
+)
+
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+type uploadKey string
+
+const uploadCtx uploadKey = "upload"
+
+// How long a request writing to an upload holds it, longer than any request may take so the lease only
+// runs out when the instance holding it died
+const uploadLockTTL = 2 * time.Minute
+
+// Takes the lease on writing to the upload and answers 423 when another request holds it
+func (app *application) lockUpload(w http.ResponseWriter, r *http.Request, uploadId string) bool {
+	if err := app.store.Uploads.Lock(r.Context(), uploadId, uploadLockTTL); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.lockedError(w, r, errors.New("the upload is being written by another request"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+	return true
+}
+
+// Gives the lease back even when the request was cancelled, otherwise the upload stays locked until
+// the lease runs out
+func (app *application) unlockUpload(ctx context.Context, uploadId string) {
+	if err := app.store.Uploads.Unlock(context.WithoutCancel(ctx), uploadId); err != nil {
+		app.logger.Errorw("error unlocking upload", "upload", uploadId, "error", err)
+	}
+}
+
+// Answers tus capability discovery requests
+func (app *application) uploadOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(app.config.media.maxVideoSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUpload godoc
+//
+//	@Summary		Creates a resumable upload
+//	@Description	Creates a tus upload, the file is sent afterwards in one or more PATCH requests
+//	@Tags			media
+//	@Param			Upload-Length	header	int		true	"Total size of the file"
+//	@Param			Upload-Metadata	header	string	false	"tus metadata, supports filename and alt_text"
+//	@Success		201
+//	@Failure		400	{object}	error
+//	@Failure		413	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/uploads [post]
+func (app *application) createUploadHandler(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		app.badRequestError(w, r, errors.New("a positive Upload-Length header is required"))
+		return
+	}
+	if length > app.config.media.maxVideoSize {
+		app.payloadTooLargeError(w, r, fmt.Errorf("%w of %d bytes", errMediaTooLarge, app.config.media.maxVideoSize))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if len(metadata["alt_text"]) > 1000 {
+		app.badRequestError(w, r, errors.New("alt_text must be at most 1000 characters"))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+	if err := app.checkStorageQuota(ctx, user, length); err != nil {
+		app.mediaUploadError(w, r, err)
+		return
+	}
+
+	upload := &store.Upload{
+		Id:        uuid.New().String(),
+		UserId:    user.Id,
+		Length:    length,
+		Filename:  filepath.Base(metadata["filename"]),
+		AltText:   metadata["alt_text"],
+		Metadata:  r.Header.Get("Upload-Metadata"),
+		ExpiresAt: time.Now().Add(app.config.uploads.expiry),
+	}
+
+	// The partial file is created up front so every PATCH can simply append to it
+	file, err := os.Create(app.partialUploadPath(upload.Id))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	file.Close()
+
+	if err := app.store.Uploads.Create(ctx, upload); err != nil {
+		app.removePartialUpload(upload.Id)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Location", "/v1/uploads/"+upload.Id)
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Reports how many bytes of the upload the server already has
+func (app *application) headUploadHandler(w http.ResponseWriter, r *http.Request) {
+	upload := getUploadFromCtx(r)
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		w.Header().Set("Upload-Metadata", upload.Metadata)
+	}
+	if upload.CompletedAt == nil {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Returns the upload state as json, media_id is set once the upload completed
+func (app *application) getUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getUploadFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Appends a chunk at the offset the client claims, the upload becomes a media record with its last chunk
+func (app *application) patchUploadHandler(w http.ResponseWriter, r *http.Request) {
+	upload := getUploadFromCtx(r)
+	ctx := r.Context()
+
+	if r.Header.Get("Content-Type") != tusChunkType {
+		app.unsupportedMediaTypeError(w, r, fmt.Errorf("content type must be %s", tusChunkType))
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		app.badRequestError(w, r, errors.New("a valid Upload-Offset header is required"))
+		return
+	}
+
+	if !app.lockUpload(w, r, upload.Id) {
+		return
+	}
+	defer app.unlockUpload(ctx, upload.Id)
+
+	// The upload may have changed while waiting for the lock, so it is read again
+	upload, err = app.store.Uploads.GetById(ctx, upload.Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if upload.CompletedAt != nil {
+		app.conflictError(w, r, errors.New("upload is already complete"))
+		return
+	}
+	if offset != upload.Offset {
+		app.conflictError(w, r, fmt.Errorf("upload offset is %d", upload.Offset))
+		return
+	}
+
+	file, err := os.OpenFile(app.partialUploadPath(upload.Id), os.O_RDWR, 0o644)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	// Bytes after the stored offset belong to a chunk that was never acknowledged
+	if err := file.Truncate(upload.Offset); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if _, err := file.Seek(upload.Offset, io.SeekStart); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, upload.Length-upload.Offset)
+	written, copyErr := io.Copy(file, body)
+
+	// Whatever arrived is kept, even when the connection dropped in the middle of the chunk,
+	// so the offset is saved with a context that outlives the request
+	previousOffset := upload.Offset
+	upload.Offset += written
+	upload.ExpiresAt = time.Now().Add(app.config.uploads.expiry)
+	if err := app.store.Uploads.UpdateOffset(context.WithoutCancel(ctx), upload, previousOffset); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if copyErr != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(copyErr, &maxBytesErr) {
+			app.payloadTooLargeError(w, r, errors.New("chunk exceeds the declared Upload-Length"))
+			return
+		}
+		app.badRequestError(w, r, copyErr)
+		return
+	}
+
+	if upload.Offset == upload.Length {
+		if err := app.completeUpload(ctx, upload, file); err != nil {
+			// The content was rejected, so there is nothing left to resume
+			app.terminateUpload(ctx, upload.Id)
+			app.mediaUploadError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.CompletedAt == nil {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Terminates an upload and frees the space it reserved
+func (app *application) deleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	upload := getUploadFromCtx(r)
+	if upload.CompletedAt != nil {
+		app.conflictError(w, r, errors.New("completed uploads are removed through their media"))
+		return
+	}
+
+	if !app.lockUpload(w, r, upload.Id) {
+		return
+	}
+	defer app.unlockUpload(r.Context(), upload.Id)
+
+	app.terminateUpload(r.Context(), upload.Id)
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Loads the upload, which is only visible to its owner
+func (app *application) uploadContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Anything but a UUID can not name an upload, and Postgres would fail on it
+		uploadId, err := uuid.Parse(chi.URLParam(r, "uploadID"))
+		if err != nil {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx := r.Context()
+		upload, err := app.store.Uploads.GetById(ctx, uploadId.String())
+		if err != nil || upload.UserId != getUserFromCtx(r).Id {
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				app.internalServerError(w, r, err)
+				return
+			}
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		if upload.CompletedAt == nil && time.Now().After(upload.ExpiresAt) {
+			writeJsonError(w, http.StatusGone, "upload expired")
+			return
+		}
+
+		ctx = context.WithValue(ctx, uploadCtx, upload)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Rejects tus requests made with a protocol version the server does not speak, plain GET requests are not part of tus
+func (app *application) tusVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			writeJsonError(w, http.StatusPreconditionFailed, "unsupported tus version")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getUploadFromCtx(r *http.Request) *store.Upload {
+	upload, _ := r.Context().Value(uploadCtx).(*store.Upload)
+	return upload
+}
+
+/* Helper Functions */
+func (app *application) completeUpload(ctx context.Context, upload *store.Upload, file *os.File) error {
+	user, err := app.store.Users.GetById(ctx, upload.UserId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	media, err := app.createMedia(ctx, user, file, upload.Length, upload.Filename, upload.AltText)
+	if err != nil {
+		return err
+	}
+
+	upload.MediaId = &media.Id
+	if err := app.store.Uploads.Complete(ctx, upload); err != nil {
+		return err
+	}
+
+	app.removePartialUpload(upload.Id)
+	return nil
+}
+
+func (app *application) terminateUpload(ctx context.Context, uploadId string) {
+	if err := app.store.Uploads.Delete(ctx, uploadId); err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.logger.Errorw("error deleting upload", "upload", uploadId, "error", err)
+	}
+	app.removePartialUpload(uploadId)
+}
+
+// Rejects uploads that would push the user over the storage quota
+func (app *application) checkStorageQuota(ctx context.Context, user *store.User, size int64) error {
+	used, err := app.store.Uploads.GetUsedStorage(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if used+size > app.config.uploads.userQuota {
+		return fmt.Errorf("%w, %d of %d bytes used", errQuotaExceeded, used, app.config.uploads.userQuota)
+	}
+	return nil
+}
+
+// Removes abandoned partial uploads, it blocks until the context is cancelled
+func (app *application) runUploadCleanup(ctx context.Context) {
+	ticker := time.NewTicker(app.config.uploads.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ids, err := app.store.Uploads.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			app.logger.Errorw("error deleting expired uploads", "error", err)
+			continue
+		}
+
+		for _, id := range ids {
+			app.removePartialUpload(id)
+		}
+		if len(ids) > 0 {
+			app.logger.Infow("expired uploads removed", "count", len(ids))
+		}
+	}
+}
+
+func (app *application) partialUploadPath(uploadId string) string {
+	return filepath.Join(app.config.uploads.dir, uploadId)
+}
+
+func (app *application) removePartialUpload(uploadId string) {
+	if err := os.Remove(app.partialUploadPath(uploadId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		app.logger.Errorw("error removing partial upload", "upload", uploadId, "error", err)
+	}
+}
+
+// Parses the Upload-Metadata header: comma separated "key base64(value)" pairs
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("malformed Upload-Metadata header")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("malformed Upload-Metadata value for %s", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -16,6 +16,20 @@ type FollowUser struct {
 	UserId int64 `json:"user_id"`
 }
 
+type FollowResponse struct {
+	Status string `json:"status"` // "following", or "requested" when the account is private
+}
+
+type UpdateUserProfilePayload struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	Website     *string `json:"website" validate:"omitempty,http_url,max=255"`
+	AvatarUrl   *string `json:"avatar_url" validate:"omitempty,http_url,max=255"`
+	IsPrivate   *bool   `json:"is_private"`
+	Version     *int64  `json:"version" validate:"required,gte=0"` // The version the client last read, a stale one fails with 409
+}
+
 // GetUser godoc
 //
 // @Summary     Fetches a user profile
@@ -51,6 +65,104 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Email addresses are only visible to the owner of the profile. The profile is copied either way,
+	// the cached user must not carry the counts.
+	currentUser := getUserFromCtx(r)
+	isOwner := currentUser != nil && currentUser.Id == user.Id
+	profile := *user
+	if !isOwner {
+		profile.Email = ""
+	}
+	user = &profile
+
+	if user.FollowCounts, err = app.store.Followers.GetCounts(ctx, user.Id); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, profileETag(user, isOwner), parseTimestamp(user.UpdatedAt)) {
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateUserProfile godoc
+//
+//	@Summary		Updates the current user's profile
+//	@Description	Updates display name, bio, location, website and avatar of the current user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateUserProfilePayload	true	"Profile payload"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateUserProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// The user in the context may come from the cache, so the latest version is read from the database
+	ctx := r.Context()
+	user, err := app.store.Users.GetById(ctx, getUserFromCtx(r).Id)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user.Version = *payload.Version
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.AvatarUrl != nil {
+		user.AvatarUrl = *payload.AvatarUrl
+	}
+	wasPrivate := user.IsPrivate
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		switch err {
+		case store.ErrEditConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.invalidateUserCache(ctx, user.Id)
+
+	// Nobody has to be approved by a public account, so everyone who asked follows it now
+	if wasPrivate && !user.IsPrivate {
+		if err := app.store.Followers.ApproveAllRequests(ctx, user.Id); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -59,14 +171,17 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 // @Summary     Follows a user
-// @Description Follows a user by ID
+// @Description Follows a user by ID. Following a private account sends a follow request instead.
 // @Tags        users
 // @Accept      json
 // @Produce     json
 // @Param       userID  path   int  true  "User ID"
-// @Success     204     {string}  string  "User followed"
-// @Failure     400     {object}  error   "User payload missing"
+// @Success     200     {object}  FollowResponse  "User followed"
+// @Success     202     {object}  FollowResponse  "Follow request sent"
+// @Failure     400     {object}  error   "User payload missing or the user itself"
+// @Failure     403     {object}  error   "One of the users blocked the other"
 // @Failure     404     {object}  error   "User not found"
+// @Failure     409     {object}  error   "User already followed"
 // @Security    ApiKeyAuth
 // @Router      /users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -78,18 +193,39 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
-	if err := app.store.Followers.Follow(ctx, followerUser.Id, followedId); err != nil {
+	requested, err := app.store.Followers.Follow(ctx, followerUser.Id, followedId)
+	if err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.conflictError(w, r, err)
 			return
+		case store.ErrSelfFollow:
+			app.badRequestError(w, r, err)
+			return
+		case store.ErrBlocked:
+			app.forbiddenError(w, r, err)
+			return
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
 		}
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, nil); err != nil {
+	if !requested {
+		app.invalidateTimeline(ctx, followerUser.Id)
+	}
+
+	if requested {
+		if err := app.jsonResponse(w, http.StatusAccepted, FollowResponse{Status: "requested"}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, FollowResponse{Status: "following"}); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -120,6 +256,7 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.invalidateTimeline(ctx, followerUser.Id)
 	if err := app.jsonResponse(w, http.StatusOK, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
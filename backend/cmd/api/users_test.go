@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/Sumitwarrior7/social/internal/store"
 	"github.com/Sumitwarrior7/social/internal/store/cache"
 	"github.com/stretchr/testify/mock"
 )
@@ -102,3 +107,154 @@ func TestGetUser(t *testing.T) {
 	// 	mockCacheStore.Calls = nil // Reset mock expectations
 	// })
 }
+
+func TestFollowUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow following yourself", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/1/follow", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should follow other users", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/2/follow", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+}
+
+// Users with an email address, whose profiles are all at the version given
+type profileUsers struct {
+	store.MockUserStore
+	version int64
+}
+
+func (u *profileUsers) GetById(ctx context.Context, userId int64) (*store.User, error) {
+	return &store.User{Id: userId, Email: fmt.Sprintf("user%d@example.com", userId), Version: u.version}, nil
+}
+
+func (u *profileUsers) UpdateProfile(ctx context.Context, user *store.User) error {
+	if user.Version != u.version {
+		return store.ErrEditConflict
+	}
+	user.Version++
+	return nil
+}
+
+func TestGetUserEmail(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Users = &profileUsers{}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"should show the email on the own profile", "/v1/users/1", "user1@example.com"},
+		{"should hide the email on the profile of others", "/v1/users/2", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			var response struct {
+				Data store.User `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Data.Email != tt.want {
+				t.Errorf("email = %q, want %q", response.Data.Email, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserProfile(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.store.Users = &profileUsers{version: 3}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should update the profile", `{"display_name": "Ada", "bio": "Hello", "version": 3}`, http.StatusOK},
+		{"should require the version", `{"location": "London"}`, http.StatusBadRequest},
+		{"should reject a stale version", `{"bio": "Hello", "version": 2}`, http.StatusConflict},
+		{"should reject a website which is not a url", `{"website": "example", "version": 3}`, http.StatusBadRequest},
+		{"should reject a bio over 500 characters", fmt.Sprintf(`{"bio": %q, "version": 3}`, strings.Repeat("a", 501)), http.StatusBadRequest},
+		{"should reject unknown fields", `{"email": "new@example.com", "version": 3}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.want, rr.Code)
+		})
+	}
+
+	t.Run("should bump the version of the profile", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(`{"display_name": "Ada", "version": 3}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data store.User `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data.DisplayName != "Ada" || response.Data.Version != 4 {
+			t.Errorf("got display name %q at version %d, want \"Ada\" at version 4", response.Data.DisplayName, response.Data.Version)
+		}
+	})
+}
@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN display_name varchar(100) NOT NULL DEFAULT '',
+ADD COLUMN bio text NOT NULL DEFAULT '',
+ADD COLUMN location varchar(100) NOT NULL DEFAULT '',
+ADD COLUMN website varchar(255) NOT NULL DEFAULT '',
+ADD COLUMN avatar_url varchar(255) NOT NULL DEFAULT '',
+ADD COLUMN version INT NOT NULL DEFAULT 0,
+ADD COLUMN updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    post_id bigint,
+    position int NOT NULL DEFAULT 0,
+    alt_text varchar(1000) NOT NULL DEFAULT '',
+    filename varchar(255) NOT NULL DEFAULT '',
+    content_type varchar(100) NOT NULL,
+    size_bytes bigint NOT NULL,
+    storage_key text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_post_id ON media (post_id);
+
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
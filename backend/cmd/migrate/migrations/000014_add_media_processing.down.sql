@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS media_variants;
+DROP INDEX IF EXISTS idx_media_status;
+DROP INDEX IF EXISTS idx_media_content_hash;
+ALTER TABLE media
+DROP COLUMN IF EXISTS status,
+DROP COLUMN IF EXISTS processing_error,
+DROP COLUMN IF EXISTS content_hash,
+DROP COLUMN IF EXISTS width,
+DROP COLUMN IF EXISTS height;
@@ -0,0 +1,29 @@
+ALTER TABLE media
+ADD COLUMN status varchar(20) NOT NULL DEFAULT 'ready',
+ADD COLUMN processing_error text NOT NULL DEFAULT '',
+ADD COLUMN content_hash varchar(64) NOT NULL DEFAULT '',
+ADD COLUMN width int NOT NULL DEFAULT 0,
+ADD COLUMN height int NOT NULL DEFAULT 0;
+
+-- Uploads created from now on start as pending until the image pipeline has processed them
+ALTER TABLE media ALTER COLUMN status SET DEFAULT 'pending';
+
+-- Images are only served through their variants, so the ones uploaded before go through the pipeline too
+UPDATE media SET status = 'pending' WHERE content_type LIKE 'image/%';
+
+CREATE INDEX IF NOT EXISTS idx_media_content_hash ON media (content_hash);
+
+CREATE INDEX IF NOT EXISTS idx_media_status ON media (status) WHERE status IN ('pending', 'processing');
+
+CREATE TABLE IF NOT EXISTS media_variants (
+    media_id bigint NOT NULL,
+    name varchar(50) NOT NULL,
+    storage_key text NOT NULL,
+    content_type varchar(100) NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    size_bytes bigint NOT NULL,
+
+    PRIMARY KEY (media_id, name),
+    CONSTRAINT fk_media FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE CASCADE
+);
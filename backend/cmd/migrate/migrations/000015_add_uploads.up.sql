@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS uploads (
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    length bigint NOT NULL,
+    upload_offset bigint NOT NULL DEFAULT 0,
+    filename varchar(255) NOT NULL DEFAULT '',
+    alt_text varchar(1000) NOT NULL DEFAULT '',
+    metadata text NOT NULL DEFAULT '',
+    media_id bigint,
+    expires_at timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    completed_at timestamp(0) with time zone,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_media FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_user_id ON uploads (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads (expires_at) WHERE completed_at IS NULL;
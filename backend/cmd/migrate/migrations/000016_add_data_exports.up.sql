@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    storage_key text NOT NULL DEFAULT '',
+    size_bytes bigint NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    expires_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    completed_at timestamp(0) with time zone,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports (status) WHERE status IN ('pending', 'processing');
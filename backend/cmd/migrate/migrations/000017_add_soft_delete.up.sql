@@ -0,0 +1,12 @@
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone,
+ADD COLUMN IF NOT EXISTS deleted_by bigint REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone,
+ADD COLUMN IF NOT EXISTS deleted_by bigint REFERENCES users (id) ON DELETE SET NULL;
+
+-- Used by the trash listing and the purge job, live rows are not indexed
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,20 @@
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS edited_at timestamp(0) with time zone;
+
+-- Every version of a post, the current one included, so any two versions can be compared
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id bigint NOT NULL,
+    version int NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    edited_by bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, version),
+    CONSTRAINT fk_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    CONSTRAINT fk_editor FOREIGN KEY (edited_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- Older versions of existing posts were never kept, their current state becomes the first revision
+INSERT INTO post_revisions (post_id, version, title, content, edited_by, created_at)
+SELECT id, COALESCE(version, 0), title, content, user_id, updated_at FROM posts
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+ALTER TABLE comments
+DROP COLUMN IF EXISTS updated_at,
+DROP COLUMN IF EXISTS version;
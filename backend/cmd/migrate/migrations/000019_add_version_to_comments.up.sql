@@ -0,0 +1,5 @@
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+UPDATE comments SET updated_at = created_at;
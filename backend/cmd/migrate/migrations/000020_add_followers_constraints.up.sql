@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id, created_at);
+
+-- NOT VALID keeps existing self follows from failing the migration, new rows are still checked
+ALTER TABLE followers ADD CONSTRAINT followers_no_self_follow CHECK (user_id <> follower_id) NOT VALID;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN is_private boolean NOT NULL DEFAULT false;
+
+-- Follows of private accounts wait here until the owner approves them
+CREATE TABLE IF NOT EXISTS follow_requests (
+    user_id bigint NOT NULL,
+    requester_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, requester_id),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_requester FOREIGN KEY (requester_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT follow_requests_no_self_request CHECK (user_id <> requester_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_requester_id ON follow_requests (requester_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS muted_words;
+
+DROP TABLE IF EXISTS user_mutes;
+
+DROP TABLE IF EXISTS user_blocks;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    user_id bigint NOT NULL,
+    blocked_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, blocked_id),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_blocked FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT user_blocks_no_self_block CHECK (user_id <> blocked_id)
+);
+
+-- Blocks work in both directions, so they are also looked up by the blocked user
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS user_mutes (
+    user_id bigint NOT NULL,
+    muted_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, muted_id),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_muted FOREIGN KEY (muted_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT user_mutes_no_self_mute CHECK (user_id <> muted_id)
+);
+
+CREATE TABLE IF NOT EXISTS muted_words (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    word varchar(100) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_muted_words_user_id_word ON muted_words (user_id, lower(word));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS notifications;
+
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_until;
+
+DROP TABLE IF EXISTS reports;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id bigserial PRIMARY KEY,
+    reporter_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_type varchar(20) NOT NULL CHECK (target_type IN ('post', 'comment', 'user')),
+    target_id bigint NOT NULL,
+    target_user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE, -- Author of the reported content
+    reason varchar(30) NOT NULL,
+    details text NOT NULL DEFAULT '',
+    status varchar(20) NOT NULL DEFAULT 'open',
+    claimed_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    claimed_at timestamp(0) with time zone,
+    resolution varchar(30),
+    resolution_note text NOT NULL DEFAULT '',
+    resolved_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    resolved_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- A user can only have one unresolved report about the same content
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_target ON reports (reporter_id, target_type, target_id)
+WHERE status IN ('open', 'claimed');
+
+CREATE INDEX IF NOT EXISTS idx_reports_queue ON reports (status, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports (target_type, target_id);
+
+ALTER TABLE users ADD COLUMN suspended_until timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    actor_id bigint REFERENCES users (id) ON DELETE CASCADE, -- NULL for notifications sent by the platform
+    type varchar(30) NOT NULL,
+    message text NOT NULL,
+    data jsonb NOT NULL DEFAULT '{}',
+    read_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, created_at);
@@ -0,0 +1,7 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS held_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS held_at;
+
+DROP TABLE IF EXISTS moderation_decisions;
+
+DROP TABLE IF EXISTS moderation_rules;
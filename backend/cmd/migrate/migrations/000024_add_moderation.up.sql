@@ -0,0 +1,38 @@
+-- Rules managed by admins, checked on every post and comment before it is stored
+CREATE TABLE IF NOT EXISTS moderation_rules (
+    id bigserial PRIMARY KEY,
+    kind varchar(10) NOT NULL CHECK (kind IN ('term', 'regex', 'domain')),
+    pattern text NOT NULL,
+    action varchar(10) NOT NULL CHECK (action IN ('flag', 'hold', 'reject')),
+    note text NOT NULL DEFAULT '',
+    created_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_moderation_rules_pattern ON moderation_rules (kind, lower(pattern));
+
+-- Every verdict of the pipeline, rejected content is only kept here as a snapshot
+CREATE TABLE IF NOT EXISTS moderation_decisions (
+    id bigserial PRIMARY KEY,
+    content_type varchar(20) NOT NULL CHECK (content_type IN ('post', 'comment')),
+    content_id bigint, -- NULL when the content was rejected and never stored
+    author_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    verdict varchar(10) NOT NULL CHECK (verdict IN ('allow', 'flag', 'hold', 'reject')),
+    findings jsonb NOT NULL DEFAULT '[]',
+    snapshot text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    reviewed_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    reviewed_at timestamp(0) with time zone,
+    review_outcome varchar(10) CHECK (review_outcome IN ('approve', 'reject'))
+);
+
+-- Flagged and held content waiting for a moderator
+CREATE INDEX IF NOT EXISTS idx_moderation_decisions_pending ON moderation_decisions (created_at)
+WHERE verdict IN ('flag', 'hold') AND reviewed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_moderation_decisions_content ON moderation_decisions (content_type, content_id);
+
+-- Held content is only visible to its author and moderators until it is approved
+ALTER TABLE posts ADD COLUMN held_at timestamp(0) with time zone;
+
+ALTER TABLE comments ADD COLUMN held_at timestamp(0) with time zone;
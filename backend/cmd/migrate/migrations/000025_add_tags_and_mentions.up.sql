@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    name varchar(50) NOT NULL UNIQUE, -- Lower cased, without the #
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    tag_id bigint NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (tag_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_post_id ON post_tags (post_id);
+
+-- Tags of existing posts, the ones which are not valid tags are left out
+INSERT INTO tags (name)
+SELECT DISTINCT lower(btrim(t.name, '# '))
+FROM posts p
+CROSS JOIN unnest(p.tags) AS t(name)
+WHERE lower(btrim(t.name, '# ')) ~ '^[[:alnum:]_]{1,50}$' AND t.name ~ '[[:alpha:]]'
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO post_tags (post_id, tag_id)
+SELECT DISTINCT p.id, tg.id
+FROM posts p
+CROSS JOIN unnest(p.tags) AS t(name)
+JOIN tags tg ON tg.name = lower(btrim(t.name, '# '))
+ON CONFLICT DO NOTHING;
+
+-- A mention in a post has no comment_id, one in a comment has both
+CREATE TABLE IF NOT EXISTS mentions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE, -- The mentioned user
+    author_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments (id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_content ON mentions (post_id, COALESCE(comment_id, 0), user_id);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id, created_at);
@@ -0,0 +1,29 @@
+-- Rankings written by the trending job, every run replaces the rows of the windows it computed
+CREATE TABLE IF NOT EXISTS trending_posts (
+    time_window varchar(10) NOT NULL,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    rank int NOT NULL,
+    score double precision NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (time_window, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trending_posts_rank ON trending_posts (time_window, rank);
+
+CREATE TABLE IF NOT EXISTS trending_tags (
+    time_window varchar(10) NOT NULL,
+    tag_id bigint NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    rank int NOT NULL,
+    score double precision NOT NULL,
+    posts int NOT NULL,
+    authors int NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (time_window, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trending_tags_rank ON trending_tags (time_window, rank);
+
+-- Posts and comments counted by the job are looked up by their age
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments (created_at);
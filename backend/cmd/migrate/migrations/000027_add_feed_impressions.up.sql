@@ -0,0 +1,7 @@
+-- Posts the ranked feed served to a user, the first time counts
+CREATE TABLE IF NOT EXISTS feed_impressions (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
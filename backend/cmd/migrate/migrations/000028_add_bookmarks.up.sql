@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS bookmark_folders (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name varchar(50) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmark_folders_user_name ON bookmark_folders (user_id, lower(name));
+
+-- Bookmarks of a deleted folder stay, outside of any folder
+CREATE TABLE IF NOT EXISTS bookmarks (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    folder_id bigint REFERENCES bookmark_folders (id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_created_at ON bookmarks (user_id, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_quote_of;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS quote_of;
+
+DROP TABLE IF EXISTS reposts;
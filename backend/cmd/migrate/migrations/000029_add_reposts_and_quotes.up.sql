@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS reposts (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reposts_post_id ON reposts (post_id);
+
+-- A quote keeps its text when the quoted post is purged, it only loses the reference
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS quote_of bigint REFERENCES posts (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_quote_of ON posts (quote_of) WHERE quote_of IS NOT NULL;
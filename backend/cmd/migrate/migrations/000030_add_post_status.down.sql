@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_posts_unpublished;
+
+DROP INDEX IF EXISTS idx_posts_publish_at;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_scheduled_publish_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
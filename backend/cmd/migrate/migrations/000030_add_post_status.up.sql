@@ -0,0 +1,12 @@
+-- Drafts and scheduled posts are only visible to their author, the publisher job publishes
+-- scheduled posts once publish_at passed
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status varchar(10) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published'));
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+ALTER TABLE posts ADD CONSTRAINT posts_scheduled_publish_at CHECK ((status = 'scheduled') = (publish_at IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at) WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS idx_posts_unpublished ON posts (user_id, status) WHERE status <> 'published';
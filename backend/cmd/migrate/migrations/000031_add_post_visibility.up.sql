@@ -0,0 +1,15 @@
+-- Who may see a post besides its author, on top of the privacy of the account
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS visibility varchar(20) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'close_friends', 'private'));
+
+-- The audience of posts with the close_friends visibility, picked by their author
+CREATE TABLE IF NOT EXISTS close_friends (
+    user_id bigint NOT NULL,
+    friend_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, friend_id),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_friend FOREIGN KEY (friend_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT close_friends_no_self CHECK (user_id <> friend_id)
+);
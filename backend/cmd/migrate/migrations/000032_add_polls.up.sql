@@ -0,0 +1,41 @@
+-- A post carries at most one poll, it can not be changed once the post is created
+CREATE TABLE IF NOT EXISTS polls (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL UNIQUE REFERENCES posts (id) ON DELETE CASCADE,
+    multiple_choice boolean NOT NULL DEFAULT false,
+    hide_results boolean NOT NULL DEFAULT false, -- Results stay hidden from voters until they vote or the poll closes
+    closes_at timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id bigserial PRIMARY KEY,
+    poll_id bigint NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    position smallint NOT NULL,
+    text varchar(80) NOT NULL,
+
+    UNIQUE (poll_id, position),
+    -- Lets votes reference an option together with its poll
+    UNIQUE (poll_id, id)
+);
+
+-- One row per user who voted, so nobody votes twice however many options they pick
+CREATE TABLE IF NOT EXISTS poll_voters (
+    poll_id bigint NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (poll_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id bigint NOT NULL,
+    option_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+
+    PRIMARY KEY (poll_id, user_id, option_id),
+    CONSTRAINT fk_voter FOREIGN KEY (poll_id, user_id) REFERENCES poll_voters (poll_id, user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_option FOREIGN KEY (poll_id, option_id) REFERENCES poll_options (poll_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_option_id ON poll_votes (option_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_unrendered;
+
+DROP INDEX IF EXISTS idx_posts_unrendered;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS content_html;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS content_html;
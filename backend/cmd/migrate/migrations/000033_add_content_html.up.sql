@@ -0,0 +1,9 @@
+-- HTML rendered from the Markdown of the content, stored next to the source. Rows written before
+-- it existed are rendered by the api when it starts.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_posts_unrendered ON posts (id) WHERE content_html = '';
+
+CREATE INDEX IF NOT EXISTS idx_comments_unrendered ON comments (id) WHERE content_html = '';
@@ -0,0 +1,10 @@
+-- Rankings of the ranked feed as of a time, its pages are read from them so they stay consistent
+CREATE TABLE IF NOT EXISTS feed_snapshots (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    as_of timestamp(0) with time zone NOT NULL,
+    search text NOT NULL DEFAULT '',
+    post_ids bigint[] NOT NULL,
+    recommended boolean[] NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, as_of, search)
+);
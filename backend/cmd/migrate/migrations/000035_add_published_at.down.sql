@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_published_at;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_published_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS published_at;
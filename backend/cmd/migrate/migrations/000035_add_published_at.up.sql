@@ -0,0 +1,8 @@
+-- Feeds order posts by when they were published, a draft published later keeps its creation time
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_at timestamp(0) with time zone;
+
+UPDATE posts SET published_at = created_at WHERE status = 'published' AND published_at IS NULL;
+
+ALTER TABLE posts ADD CONSTRAINT posts_published_at CHECK ((status = 'published') = (published_at IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_posts_published_at ON posts (published_at);
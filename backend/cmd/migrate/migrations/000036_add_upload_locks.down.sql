@@ -0,0 +1 @@
+ALTER TABLE uploads DROP COLUMN IF EXISTS locked_until;
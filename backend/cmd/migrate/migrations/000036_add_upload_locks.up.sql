@@ -0,0 +1 @@
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS locked_until timestamp(0) with time zone;
@@ -0,0 +1,134 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps blobs on the local filesystem, its signed URLs are served by the API
+type LocalStore struct {
+	root    string
+	baseUrl string
+	signer  signer
+}
+
+func NewLocalStore(root, baseUrl, secret string) (*LocalStore, error) {
+	if secret == "" {
+		return nil, errors.New("signing secret is required")
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		root:    root,
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		signer:  signer{secret: []byte(secret), now: time.Now},
+	}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Writing to a temporary file first means readers never see a partially written blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (*Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Object{
+		Body:        file,
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	return s.baseUrl + "/" + escapeKey(key) + "?" + s.signer.query(key, expiry).Encode(), nil
+}
+
+func (s *LocalStore) VerifySignedURL(key string, query url.Values) error {
+	return s.signer.verify(key, query)
+}
+
+// Maps a key to a path inside the root directory, keys escaping the root are rejected
+func (s *LocalStore) path(key string) (string, error) {
+	key = filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(key) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.root, key), nil
+}
+
+func escapeKey(key string) string {
+	segments := strings.Split(path.Clean(key), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines compares two texts line by line and returns the edit script turning a into b.
+// It uses the longest common subsequence, which is fine for post sized texts.
+func Lines(a, b string) []Line {
+	x, y := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]Line, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, x[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{Insert, y[j]})
+	}
+	return lines
+}
+
+// Changed reports whether the edit script contains any insertion or deletion
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified renders an edit script in the unified diff format with the given number of context lines.
+// It returns an empty string when the texts are equal.
+func Unified(fromName, toName string, lines []Line, context int) string {
+	if !Changed(lines) {
+		return ""
+	}
+
+	// Line numbers in both texts before every entry of the script
+	oldBefore := make([]int, len(lines)+1)
+	newBefore := make([]int, len(lines)+1)
+	for k, l := range lines {
+		oldBefore[k+1], newBefore[k+1] = oldBefore[k], newBefore[k]
+		if l.Op != Insert {
+			oldBefore[k+1]++
+		}
+		if l.Op != Delete {
+			newBefore[k+1]++
+		}
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", fromName, toName)
+
+	n := len(lines)
+	i := 0
+	for {
+		for i < n && lines[i].Op == Equal {
+			i++
+		}
+		if i == n {
+			break
+		}
+
+		// Changes separated by less than two contexts worth of equal lines share a hunk
+		start := max(i-context, 0)
+		end := i
+		for end < n {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			k := end
+			for k < n && lines[k].Op == Equal {
+				k++
+			}
+			if k == n || k-end > 2*context {
+				end = min(end+context, n)
+				break
+			}
+			end = k
+		}
+
+		oldCount := oldBefore[end] - oldBefore[start]
+		newCount := newBefore[end] - newBefore[start]
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldBefore[start], oldCount), hunkRange(newBefore[start], newCount))
+		for _, l := range lines[start:end] {
+			switch l.Op {
+			case Insert:
+				b.WriteString("+")
+			case Delete:
+				b.WriteString("-")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(l.Text)
+			b.WriteString("\n")
+		}
+		i = end
+	}
+	return b.String()
+}
+
+/* Helper Functions */
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// An empty range points at the line before it, as in GNU diff
+func hunkRange(before, count int) string {
+	start := before + 1
+	if count == 0 {
+		start = before
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
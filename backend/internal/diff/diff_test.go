@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{"equal", "a\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"empty to text", "", "a", []Line{{Insert, "a"}}},
+		{"text to empty", "a", "", []Line{{Delete, "a"}}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}}},
+		{"trailing newline is ignored", "a\n", "a", []Line{{Equal, "a"}}},
+		{"crlf line endings", "a\r\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	t.Run("should return nothing for equal texts", func(t *testing.T) {
+		if got := Unified("v1", "v2", Lines("a\nb", "a\nb"), 3); got != "" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("should render hunks with context", func(t *testing.T) {
+		a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10"
+		b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\nten"
+
+		want := "--- v1\n+++ v2\n" +
+			"@@ -2,3 +2,3 @@\n 2\n-3\n+three\n 4\n" +
+			"@@ -9,2 +9,2 @@\n 9\n-10\n+ten\n"
+		if got := Unified("v1", "v2", Lines(a, b), 1); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("should merge close changes into one hunk", func(t *testing.T) {
+		a := "1\n2\n3\n4\n5"
+		b := "x\n2\n3\n4\ny"
+
+		want := "--- v1\n+++ v2\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n 4\n-5\n+y\n"
+		if got := Unified("v1", "v2", Lines(a, b), 3); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("should point empty ranges at the previous line", func(t *testing.T) {
+		want := "--- v1\n+++ v2\n@@ -0,0 +1 @@\n+a\n"
+		if got := Unified("v1", "v2", Lines("", "a"), 3); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+}
@@ -45,3 +45,18 @@ func GetBool(key string, fallback bool) bool {
 
 	return ValAsBool
 }
+
+func GetFloat(key string, fallback float64) float64 {
+	godotenv.Load()
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	ValAsFloat, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return ValAsFloat
+}
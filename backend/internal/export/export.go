@@ -0,0 +1,248 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Returned by a FileOpener when the stored file is gone, the archive is still built without it
+var ErrFileMissing = errors.New("file missing from storage")
+
+//go:embed "templates"
+var templatesFS embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(templatesFS, "templates/index.html.tmpl"))
+
+// Opens the stored content of a media file
+type FileOpener func(ctx context.Context, media store.Media) (io.ReadCloser, error)
+
+type Profile struct {
+	Id          int64  `json:"id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Location    string `json:"location"`
+	Website     string `json:"website"`
+	AvatarUrl   string `json:"avatar_url"`
+	Role        string `json:"role"`
+	IsActive    bool   `json:"is_active"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type Post struct {
+	Id        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	Version   int64    `json:"version"`
+	MediaIds  []int64  `json:"media_ids"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+type Comment struct {
+	Id        int64  `json:"id"`
+	PostId    int64  `json:"post_id"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+type Media struct {
+	Id          int64  `json:"id"`
+	PostId      *int64 `json:"post_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	AltText     string `json:"alt_text"`
+	CreatedAt   string `json:"created_at"`
+	File        string `json:"file"` // Path of the file inside the archive, empty when it could not be exported
+}
+
+// Writes the archive of a user: one JSON file per kind of data, the media files and an index.html
+// which presents the same data to humans.
+func Write(ctx context.Context, w io.Writer, data *store.UserData, open FileOpener, generatedAt time.Time) error {
+	zw := zip.NewWriter(w)
+
+	profile := newProfile(data.Profile)
+	posts := newPosts(data.Posts, data.Media)
+	comments := newComments(data.Comments)
+
+	media := make([]Media, 0, len(data.Media))
+	for _, m := range data.Media {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		exported := newMedia(m)
+		name := mediaFileName(m)
+		err := writeFile(ctx, zw, name, m, open)
+		switch {
+		case err == nil:
+			exported.File = name
+		case !errors.Is(err, ErrFileMissing):
+			return fmt.Errorf("exporting media %d: %w", m.Id, err)
+		}
+		media = append(media, exported)
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", profile},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"followers.json", data.Followers},
+		{"following.json", data.Following},
+		{"media.json", media},
+	}
+	for _, f := range files {
+		if err := writeJSON(zw, f.name, f.data); err != nil {
+			return err
+		}
+	}
+
+	index, err := zw.Create("index.html")
+	if err != nil {
+		return err
+	}
+	err = indexTemplate.Execute(index, map[string]any{
+		"GeneratedAt": generatedAt.UTC().Format(time.RFC1123),
+		"Profile":     profile,
+		"Posts":       posts,
+		"Comments":    comments,
+		"Followers":   data.Followers,
+		"Following":   data.Following,
+		"Media":       media,
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+/* Helper Functions */
+func writeJSON(zw *zip.Writer, name string, data any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+func writeFile(ctx context.Context, zw *zip.Writer, name string, media store.Media, open FileOpener) error {
+	r, err := open(ctx, media)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Media is already compressed, so it is only stored
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// The id keeps names unique, the original name is cleaned so it can not escape the media directory
+func mediaFileName(media store.Media) string {
+	name := path.Base(strings.ReplaceAll(media.Filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = "file"
+	}
+	return fmt.Sprintf("media/%d-%s", media.Id, name)
+}
+
+func newProfile(user *store.User) Profile {
+	return Profile{
+		Id:          user.Id,
+		Username:    user.Username,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Location:    user.Location,
+		Website:     user.Website,
+		AvatarUrl:   user.AvatarUrl,
+		Role:        user.Role.Name,
+		IsActive:    user.IsActive,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+func newPosts(posts []store.Post, media []store.Media) []Post {
+	mediaIds := make(map[int64][]int64)
+	for _, m := range media {
+		if m.PostId != nil {
+			mediaIds[*m.PostId] = append(mediaIds[*m.PostId], m.Id)
+		}
+	}
+
+	exported := make([]Post, 0, len(posts))
+	for _, p := range posts {
+		tags := p.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		ids := mediaIds[p.Id]
+		if ids == nil {
+			ids = []int64{}
+		}
+
+		exported = append(exported, Post{
+			Id:        p.Id,
+			Title:     p.Title,
+			Content:   p.Content,
+			Tags:      tags,
+			Version:   p.Version,
+			MediaIds:  ids,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+	return exported
+}
+
+func newComments(comments []store.Comment) []Comment {
+	exported := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		exported = append(exported, Comment{
+			Id:        c.Id,
+			PostId:    c.PostId,
+			Content:   c.Content,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+	return exported
+}
+
+func newMedia(media store.Media) Media {
+	return Media{
+		Id:          media.Id,
+		PostId:      media.PostId,
+		Filename:    media.Filename,
+		ContentType: media.ContentType,
+		SizeBytes:   media.SizeBytes,
+		AltText:     media.AltText,
+		CreatedAt:   media.CreatedAt,
+	}
+}
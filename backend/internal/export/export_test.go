@@ -0,0 +1,116 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+func testData() *store.UserData {
+	postId := int64(10)
+	return &store.UserData{
+		Profile: &store.User{Id: 1, Username: "alice", Email: "alice@example.com", Role: store.Role{Name: "user"}},
+		Posts: []store.Post{
+			{Id: 10, Title: "Hello", Content: "<script>alert(1)</script>", Tags: []string{"go"}, Version: 3},
+		},
+		Comments:  []store.Comment{{Id: 5, PostId: 10, Content: "first"}},
+		Followers: []store.FollowConnection{{UserId: 2, Username: "bob"}},
+		Following: []store.FollowConnection{},
+		Media: []store.Media{
+			{Id: 7, PostId: &postId, Filename: "../../etc/cat.png", ContentType: "image/png"},
+			{Id: 8, Filename: "gone.jpg", ContentType: "image/jpeg"},
+		},
+	}
+}
+
+func testOpener(ctx context.Context, media store.Media) (io.ReadCloser, error) {
+	if media.Id == 8 {
+		return nil, ErrFileMissing
+	}
+	return io.NopCloser(strings.NewReader("image bytes")), nil
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestWrite(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := Write(context.Background(), buf, testData(), testOpener, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	files := readArchive(t, buf.Bytes())
+
+	for _, name := range []string{"profile.json", "posts.json", "comments.json", "followers.json", "following.json", "media.json", "index.html"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("archive is missing %s", name)
+		}
+	}
+
+	t.Run("should keep media files inside the media directory", func(t *testing.T) {
+		if files["media/7-cat.png"] != "image bytes" {
+			t.Errorf("media file was not written, archive has %v", keys(files))
+		}
+	})
+
+	t.Run("should link posts to their media and keep versions", func(t *testing.T) {
+		var posts []Post
+		if err := json.Unmarshal([]byte(files["posts.json"]), &posts); err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 1 || posts[0].Version != 3 || len(posts[0].MediaIds) != 1 || posts[0].MediaIds[0] != 7 {
+			t.Errorf("unexpected posts %+v", posts)
+		}
+	})
+
+	t.Run("should list missing files without a path", func(t *testing.T) {
+		var media []Media
+		if err := json.Unmarshal([]byte(files["media.json"]), &media); err != nil {
+			t.Fatal(err)
+		}
+		if len(media) != 2 || media[0].File != "media/7-cat.png" || media[1].File != "" {
+			t.Errorf("unexpected media %+v", media)
+		}
+	})
+
+	t.Run("should escape user content in the index", func(t *testing.T) {
+		if strings.Contains(files["index.html"], "<script>") {
+			t.Error("index.html contains unescaped user content")
+		}
+	})
+}
+
+func keys(m map[string]string) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
@@ -0,0 +1,86 @@
+// Package extract finds the #hashtags and @mentions in the text of posts and comments.
+package extract
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Longest tag which is stored, longer hashtags in the text are cut off
+const MaxTagLength = 50
+
+var (
+	// A tag must follow the start of the text or a character which can not be part of a word, so
+	// "issue#42" and "a##b" are not tags. Tags made of digits only, like "#1", are left out later.
+	hashtagRe = regexp.MustCompile(`(?:^|[^\pL\pN_&#])#([\pL\pN_]{1,50})`)
+	// The same goes for mentions, which keeps email addresses such as "me@example.com" out
+	mentionRe = regexp.MustCompile(`(?:^|[^\pL\pN_@.])@([A-Za-z0-9_]{1,100})`)
+)
+
+// Returns the normalised hashtags of the text in the order they first appear
+func Hashtags(text string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, m := range hashtagRe.FindAllStringSubmatch(text, -1) {
+		tag, ok := NormalizeTag(m[1])
+		if !ok || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Returns the usernames mentioned in the text, lower cased, in the order they first appear
+func Mentions(text string) []string {
+	usernames := []string{}
+	seen := map[string]bool{}
+	for _, m := range mentionRe.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(m[1])
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// Turns a tag as users write it, with or without the #, into the form it is stored in. Tags are
+// lower cased letters, digits and underscores with at least one letter.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || len([]rune(tag)) > MaxTagLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r) || r == '_':
+		default:
+			return "", false
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+	return tag, true
+}
+
+// Merges the explicit tags of a post with the hashtags of its text, without duplicates
+func MergeTags(tags []string, hashtags []string) []string {
+	merged := []string{}
+	seen := map[string]bool{}
+	for _, tag := range append(append([]string{}, tags...), hashtags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
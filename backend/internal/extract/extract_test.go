@@ -0,0 +1,57 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := map[string][]string{
+		"#Go and #go again":           {"go"},
+		"Learning #golang, #Go_Lang!": {"golang", "go_lang"},
+		"issue#42 and a##b":           {},
+		"#1 is not a tag, #año is":    {"año"},
+		"(#first) #second.":           {"first", "second"},
+	}
+
+	for text, want := range tests {
+		if got := Hashtags(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("Hashtags(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := map[string][]string{
+		"hi @Alice and @bob_1, @alice": {"alice", "bob_1"},
+		"mail me@example.com":          {},
+		"(@carol)":                     {"carol"},
+	}
+
+	for text, want := range tests {
+		if got := Mentions(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("Mentions(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"#Go", "go", true},
+		{" rust ", "rust", true},
+		{"go lang", "", false},
+		{"2024", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeTag(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeTag(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooLarge          = errors.New("image dimensions exceed the allowed limit")
+)
+
+type Limits struct {
+	MaxPixels    int // Guards against decompression bombs, checked before any pixel data is decoded
+	MaxDimension int
+}
+
+type Variant struct {
+	Name    string
+	MaxSize int  // Longest edge of the generated image
+	Square  bool // Center crops the image to a square before resizing
+}
+
+var DefaultVariants = []Variant{
+	{Name: "thumbnail", MaxSize: 256, Square: true},
+	{Name: "feed", MaxSize: 1080},
+	{Name: "full", MaxSize: 2048},
+}
+
+type Output struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+type Result struct {
+	Width    int // Dimensions of the original image after orientation is applied
+	Height   int
+	Variants []Output
+}
+
+// Process decodes a JPEG, PNG or GIF image and re-encodes it into the requested variants.
+// The encoders of the standard library never write metadata, so EXIF and GPS data of the
+// original are dropped, the EXIF orientation is applied to the pixels before that happens.
+func Process(data []byte, limits Limits, variants []Variant) (*Result, error) {
+	format, err := CheckDimensions(bytes.NewReader(data), limits)
+	if err != nil {
+		return nil, err
+	}
+
+	var src image.Image
+	switch format {
+	case "jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		// Only the first frame is kept for the variants
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", format, err)
+	}
+
+	img := toNRGBA(src)
+	if format == "jpeg" {
+		img = applyOrientation(img, exifOrientation(data))
+	}
+
+	result := &Result{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	for _, v := range variants {
+		resized := img
+		if v.Square {
+			resized = cropSquare(resized)
+		}
+		resized = fit(resized, v.MaxSize)
+
+		out, contentType, err := encode(resized, format)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Variants = append(result.Variants, Output{
+			Name:        v.Name,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			ContentType: contentType,
+			Data:        out,
+		})
+	}
+
+	return result, nil
+}
+
+// CheckDimensions reads only the image header and rejects images whose decoded size would exceed the limits
+func CheckDimensions(r io.Reader, limits Limits) (string, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 ||
+		cfg.Width > limits.MaxDimension || cfg.Height > limits.MaxDimension ||
+		cfg.Width*cfg.Height > limits.MaxPixels {
+		return "", ErrTooLarge
+	}
+	return format, nil
+}
+
+// Photos are encoded as JPEG, PNG and GIF sources as PNG so transparency survives
+func encode(img *image.NRGBA, format string) ([]byte, string, error) {
+	buf := new(bytes.Buffer)
+	if format == "jpeg" {
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+func cropSquare(img *image.NRGBA) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	size := min(w, h)
+	x0, y0 := (w-size)/2, (h-size)/2
+	return toNRGBA(img.SubImage(image.Rect(x0, y0, x0+size, y0+size)))
+}
+
+// Scales the image down so its longest edge is at most maxSize, images are never upscaled
+func fit(img *image.NRGBA, maxSize int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+
+	dw, dh := maxSize, h*maxSize/w
+	if h > w {
+		dw, dh = w*maxSize/h, maxSize
+	}
+	return resize(img, max(dw, 1), max(dh, 1))
+}
+
+// Area averaging downscale, every destination pixel is the mean of the source pixels it covers
+func resize(src *image.NRGBA, dw, dh int) *image.NRGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					pa := uint64(src.Pix[i+3])
+					// Colors are weighted by alpha so transparent pixels do not darken the edges
+					r += uint64(src.Pix[i]) * pa
+					g += uint64(src.Pix[i+1]) * pa
+					b += uint64(src.Pix[i+2]) * pa
+					a += pa
+					n++
+					i += 4
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[o] = uint8(r / a)
+				dst.Pix[o+1] = uint8(g / a)
+				dst.Pix[o+2] = uint8(b / a)
+			}
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
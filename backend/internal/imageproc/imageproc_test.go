@@ -0,0 +1,116 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testLimits = Limits{MaxPixels: 10_000_000, MaxDimension: 8000}
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+// Builds a JPEG with an APP1 segment carrying the orientation tag and a fake GPS marker
+func jpegWithExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	tiff = append(tiff, []byte("GPSLatitude")...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, app1...)
+	return append(out, encoded[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("should generate variants within their size limits", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, testImage(3000, 1500)); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := Process(buf.Bytes(), testLimits, DefaultVariants)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string][2]int{
+			"thumbnail": {256, 256},
+			"feed":      {1080, 540},
+			"full":      {2048, 1024},
+		}
+		for _, v := range result.Variants {
+			if got := [2]int{v.Width, v.Height}; got != expected[v.Name] {
+				t.Errorf("variant %s: expected %v; got %v", v.Name, expected[v.Name], got)
+			}
+			if v.ContentType != "image/png" {
+				t.Errorf("variant %s: expected image/png; got %s", v.Name, v.ContentType)
+			}
+		}
+	})
+
+	t.Run("should strip exif and apply the orientation", func(t *testing.T) {
+		data := jpegWithExif(t, testImage(40, 20), 6)
+		if !bytes.Contains(data, []byte("GPSLatitude")) {
+			t.Fatal("test image is missing its metadata")
+		}
+
+		result, err := Process(data, testLimits, []Variant{{Name: "full", MaxSize: 2048}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := result.Variants[0]
+		if bytes.Contains(out.Data, []byte("Exif")) || bytes.Contains(out.Data, []byte("GPSLatitude")) {
+			t.Error("expected the metadata to be stripped")
+		}
+		if out.Width != 20 || out.Height != 40 {
+			t.Errorf("expected a rotated 20x40 image; got %dx%d", out.Width, out.Height)
+		}
+	})
+
+	t.Run("should reject decompression bombs before decoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, testImage(200, 200)); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := Process(buf.Bytes(), Limits{MaxPixels: 100 * 100, MaxDimension: 8000}, DefaultVariants)
+		if err != ErrTooLarge {
+			t.Errorf("expected ErrTooLarge; got %v", err)
+		}
+	})
+
+	t.Run("should reject data that is not an image", func(t *testing.T) {
+		if _, err := Process([]byte("not an image"), testLimits, DefaultVariants); err != ErrUnsupportedFormat {
+			t.Errorf("expected ErrUnsupportedFormat; got %v", err)
+		}
+	})
+}
@@ -6,6 +6,7 @@ const (
 	fromName            = "Golang Media"
 	MaxRetries          = 3
 	UserWelcomeTemplate = "user_invitations.tmpl"
+	DataExportTemplate  = "data_export_ready.tmpl"
 )
 
 // const userWelcomeTemplate string = "user_invitations"
@@ -0,0 +1,289 @@
+// Package markdown renders the Markdown subset of posts and comments to HTML which is safe to
+// embed in a page: paragraphs, line breaks, fenced code blocks, lists, links, emphasis and inline
+// code. Everything else stays text.
+//
+// The source is never copied into the output unescaped, only the tags the renderer writes itself
+// are markup, so the HTML needs no sanitising afterwards whatever the source contains.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	unorderedItemRe = regexp.MustCompile(`^ {0,3}[-*+][ \t]+(.*)$`)
+	orderedItemRe   = regexp.MustCompile(`^ {0,3}[0-9]{1,9}[.)][ \t]+(.*)$`)
+	// Only plain names are kept as the language of a code block, they end up in a class attribute
+	languageRe = regexp.MustCompile(`^[A-Za-z0-9_+-]{1,20}$`)
+)
+
+// Schemes links may use, anything else such as javascript: is rendered as text
+var linkSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// Renders the source to HTML. Links get rel="nofollow ugc", as they come from users.
+func Render(source string) string {
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+	r := &renderer{}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			r.flush()
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			r.writeCode(strings.TrimSpace(strings.TrimPrefix(trimmed, "```")), code)
+			continue
+		}
+
+		if trimmed == "" {
+			r.flush()
+			continue
+		}
+		if m := unorderedItemRe.FindStringSubmatch(line); m != nil {
+			r.addItem("ul", m[1])
+			continue
+		}
+		if m := orderedItemRe.FindStringSubmatch(line); m != nil {
+			r.addItem("ol", m[1])
+			continue
+		}
+
+		// A line which is not an item ends the list, lists are not nested
+		r.flushList()
+		r.paragraph = append(r.paragraph, trimmed)
+	}
+	r.flush()
+
+	return strings.TrimSuffix(r.out.String(), "\n")
+}
+
+// Collects the open paragraph or list until a block ends it
+type renderer struct {
+	out       strings.Builder
+	paragraph []string
+	list      string // "ul" or "ol" while a list is open
+	items     []string
+}
+
+func (r *renderer) addItem(list string, item string) {
+	r.flushParagraph()
+	if r.list != list {
+		r.flushList()
+	}
+	r.list = list
+	r.items = append(r.items, strings.TrimSpace(item))
+}
+
+func (r *renderer) flush() {
+	r.flushParagraph()
+	r.flushList()
+}
+
+// The lines of a paragraph are kept apart with line breaks, posts are not wrapped by hand
+func (r *renderer) flushParagraph() {
+	if len(r.paragraph) == 0 {
+		return
+	}
+	r.out.WriteString("<p>")
+	for i, line := range r.paragraph {
+		if i > 0 {
+			r.out.WriteString("<br>\n")
+		}
+		writeInline(&r.out, line, true)
+	}
+	r.out.WriteString("</p>\n")
+	r.paragraph = nil
+}
+
+func (r *renderer) flushList() {
+	if len(r.items) == 0 {
+		return
+	}
+	r.out.WriteString("<" + r.list + ">\n")
+	for _, item := range r.items {
+		r.out.WriteString("<li>")
+		writeInline(&r.out, item, true)
+		r.out.WriteString("</li>\n")
+	}
+	r.out.WriteString("</" + r.list + ">\n")
+	r.list = ""
+	r.items = nil
+}
+
+// A code block without its closing fence runs to the end of the source
+func (r *renderer) writeCode(language string, lines []string) {
+	r.out.WriteString("<pre><code")
+	if languageRe.MatchString(language) {
+		r.out.WriteString(` class="language-` + language + `"`)
+	}
+	r.out.WriteString(">")
+	r.out.WriteString(html.EscapeString(strings.Join(lines, "\n")))
+	r.out.WriteString("</code></pre>\n")
+}
+
+// Writes the text with its inline code, links and emphasis. Link labels can not hold other links.
+func writeInline(out *strings.Builder, s string, links bool) {
+	start := 0
+	flush := func(end int) {
+		out.WriteString(html.EscapeString(s[start:end]))
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		n := 0
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_[]()#+-.!", s[i+1]) >= 0:
+			flush(i)
+			out.WriteString(html.EscapeString(s[i+1 : i+2]))
+			n = 2
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				flush(i)
+				out.WriteString("<code>" + html.EscapeString(s[i+1:i+1+end]) + "</code>")
+				n = end + 2
+			}
+		case c == '[' && links:
+			if label, href, length, ok := parseLink(s[i:]); ok {
+				flush(i)
+				writeLink(out, label, href)
+				n = length
+			}
+		case c == '*' || c == '_':
+			if inner, length, strong := parseEmphasis(s, i); length > 0 {
+				flush(i)
+				tag := "em"
+				if strong {
+					tag = "strong"
+				}
+				out.WriteString("<" + tag + ">")
+				writeInline(out, inner, links)
+				out.WriteString("</" + tag + ">")
+				n = length
+			}
+		}
+
+		if n == 0 {
+			i++
+			continue
+		}
+		i += n
+		start = i
+	}
+	flush(len(s))
+}
+
+// Parses a [label](url) at the start of s and returns its length. Parentheses in the url have to be
+// balanced, as in https://en.wikipedia.org/wiki/Go_(programming_language).
+func parseLink(s string) (label string, href string, length int, ok bool) {
+	closing := strings.IndexByte(s, ']')
+	if closing < 0 || closing+1 >= len(s) || s[closing+1] != '(' {
+		return "", "", 0, false
+	}
+	end := destinationEnd(s[closing+2:])
+	if end < 0 {
+		return "", "", 0, false
+	}
+
+	href = strings.TrimSpace(s[closing+2 : closing+2+end])
+	if href == "" || strings.ContainsAny(href, " \t") {
+		return "", "", 0, false
+	}
+	return s[1:closing], href, closing + 2 + end + 1, true
+}
+
+// Returns the index of the parenthesis which closes the link destination at the start of s, or -1
+func destinationEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// Links to urls with other schemes, or without any, are written as their label
+func writeLink(out *strings.Builder, label string, href string) {
+	if label == "" {
+		label = href
+	}
+
+	u, err := url.Parse(href)
+	if err != nil || !linkSchemes[strings.ToLower(u.Scheme)] {
+		writeInline(out, label, false)
+		return
+	}
+
+	out.WriteString(`<a href="` + html.EscapeString(u.String()) + `" rel="nofollow ugc">`)
+	writeInline(out, label, false)
+	out.WriteString("</a>")
+}
+
+// Parses the emphasis opened at s[i], a double delimiter makes it strong. The text inside can not
+// start or end with a space, and underscores only count at the edges of words, so snake_case
+// names are left alone.
+func parseEmphasis(s string, i int) (inner string, length int, strong bool) {
+	delim := s[i : i+1]
+	if strings.HasPrefix(s[i:], delim+delim) {
+		delim += delim
+		strong = true
+	}
+	if delim[0] == '_' && i > 0 && isWordChar(s[i-1]) {
+		return "", 0, false
+	}
+
+	from := i + len(delim)
+	end := closingDelimiter(s[from:], delim)
+	if end <= 0 {
+		return "", 0, false
+	}
+	inner = s[from : from+end]
+	if strings.TrimSpace(inner) != inner {
+		return "", 0, false
+	}
+
+	after := from + end + len(delim)
+	if delim[0] == '_' && after < len(s) && isWordChar(s[after]) {
+		return "", 0, false
+	}
+	return inner, after - i, strong
+}
+
+// Returns the index of the delimiter which closes emphasis in s, or -1. Delimiters inside inline
+// code do not count.
+func closingDelimiter(s string, delim string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '`' {
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				i += end + 1
+				continue
+			}
+		}
+		if strings.HasPrefix(s[i:], delim) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= 0x80
+}
@@ -0,0 +1,58 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one<br>\ntwo</p>\n<p>three</p>"},
+		{"emphasis", "**bold**, *em* and _em_", "<p><strong>bold</strong>, <em>em</em> and <em>em</em></p>"},
+		{"snake case", "snake_case_name and 2*3*4", "<p>snake_case_name and 2<em>3</em>4</p>"},
+		{"inline code", "run `a <b>`", "<p>run <code>a &lt;b&gt;</code></p>"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow ugc">docs</a></p>`},
+		{"link with parentheses", "[Go](https://en.wikipedia.org/wiki/Go_(programming_language)) rocks", `<p><a href="https://en.wikipedia.org/wiki/Go_(programming_language)" rel="nofollow ugc">Go</a> rocks</p>`},
+		{"link with unbalanced parentheses", "[Go](https://example.com/(a)", "<p>[Go](https://example.com/(a)</p>"},
+		{"mailto link", "[mail](mailto:a@example.com)", `<p><a href="mailto:a@example.com" rel="nofollow ugc">mail</a></p>`},
+		{"link without label", "[](https://example.com)", `<p><a href="https://example.com" rel="nofollow ugc">https://example.com</a></p>`},
+		{"emphasis in link label", "[**bold** docs](https://example.com)", `<p><a href="https://example.com" rel="nofollow ugc"><strong>bold</strong> docs</a></p>`},
+		{"link in link label", "[[a](https://a.example)](https://b.example)", `<p><a href="https://a.example" rel="nofollow ugc">[a</a>](https://b.example)</p>`},
+		{"unsafe link", "[click](javascript:alert(1))", "<p>click</p>"},
+		{"unsafe link in capitals", "[click](JAVASCRIPT:alert(1))", "<p>click</p>"},
+		{"unsafe link in mixed case", "[click](JaVaScRiPt:alert(1))", "<p>click</p>"},
+		{"data link", "[click](data:text/html;base64,PHNjcmlwdD4=)", "<p>click</p>"},
+		{"vbscript link", "[click](vbscript:msgbox(1))", "<p>click</p>"},
+		{"entity encoded scheme", "[click](javascript&#58;alert(1))", "<p>click</p>"},
+		{"entity encoded first letter", "[click](&#106;avascript:alert(1))", "<p>click</p>"},
+		{"percent encoded scheme", "[click](javascript%3Aalert(1))", "<p>click</p>"},
+		{"link with spaces", "[click](java script:alert(1))", "<p>[click](java script:alert(1))</p>"},
+		{"quote in href", `[x](https://example.com/"onmouseover="alert(1))`, `<p><a href="https://example.com/%22onmouseover=%22alert%281%29" rel="nofollow ugc">x</a></p>`},
+		{"quote in label", `[a"b](https://example.com)`, `<p><a href="https://example.com" rel="nofollow ugc">a&#34;b</a></p>`},
+		{"html in href", "[x](https://example.com/<script>)", `<p><a href="https://example.com/%3Cscript%3E" rel="nofollow ugc">x</a></p>`},
+		{"relative link", "[home](/home)", "<p>home</p>"},
+		{"protocol relative link", "[home](//evil.example/x)", "<p>home</p>"},
+		{"nested emphasis", "**bold _and em_** and _em with **bold**_", "<p><strong>bold <em>and em</em></strong> and <em>em with <strong>bold</strong></em></p>"},
+		{"unclosed emphasis", "**open and *half", "<p>**open and *half</p>"},
+		{"code in emphasis", "*see `a*b`*", "<p><em>see <code>a*b</code></em></p>"},
+		{"lists", "- a\n- b\n1. c", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>"},
+		{"link in list item", "- see [docs](https://example.com)\n- *em* item", `<ul>` + "\n" + `<li>see <a href="https://example.com" rel="nofollow ugc">docs</a></li>` + "\n" + `<li><em>em</em> item</li>` + "\n" + `</ul>`},
+		{"unsafe link in list item", "1. [x](javascript:alert(1))", "<ol>\n<li>x</li>\n</ol>"},
+		{"html in list item", "- <img src=x onerror=alert(1)>", "<ul>\n<li>&lt;img src=x onerror=alert(1)&gt;</li>\n</ul>"},
+		{"code block", "```go\nif a < b {\n```", "<pre><code class=\"language-go\">if a &lt; b {</code></pre>"},
+		{"odd language", "```\"><script>\nx\n```", "<pre><code>x</code></pre>"},
+		{"html", "<script>alert('x')</script>", "<p>&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;</p>"},
+		{"html in code block", "```\n</code></pre><script>x</script>\n```", "<pre><code>&lt;/code&gt;&lt;/pre&gt;&lt;script&gt;x&lt;/script&gt;</code></pre>"},
+		{"unclosed code block", "```\n<b>", "<pre><code>&lt;b&gt;</code></pre>"},
+		{"escaped", `\*not em\*`, "<p>*not em*</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in); got != tt.want {
+				t.Errorf("Render(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
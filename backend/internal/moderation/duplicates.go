@@ -0,0 +1,95 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Where the recent content of an author comes from
+type HistorySource interface {
+	// Returns the texts of the author's content of the kind, leaving out the content with the excluded id
+	GetRecentContent(ctx context.Context, userId int64, kind string, excludeId int64, since time.Time, limit int) ([]string, error)
+}
+
+// Holds content which is nearly the same as something the author published recently, the usual
+// shape of spam. Texts are compared as sets of three word shingles, so small edits such as a
+// changed link or some added punctuation do not make a copy look new.
+type DuplicateCheck struct {
+	source    HistorySource
+	threshold float64       // Similarity from 0 to 1 at which content counts as a copy
+	window    time.Duration // How far back the author's content is compared
+	lookback  int           // At most how many of the author's recent texts are compared
+}
+
+func NewDuplicateCheck(source HistorySource, threshold float64, window time.Duration, lookback int) *DuplicateCheck {
+	return &DuplicateCheck{
+		source:    source,
+		threshold: threshold,
+		window:    window,
+		lookback:  lookback,
+	}
+}
+
+func (c *DuplicateCheck) Name() string {
+	return "duplicate"
+}
+
+func (c *DuplicateCheck) Check(ctx context.Context, content *Content) ([]Finding, error) {
+	shingles := Shingles(content.Title + "\n" + content.Body)
+	if len(shingles) == 0 {
+		return nil, nil
+	}
+
+	recent, err := c.source.GetRecentContent(ctx, content.AuthorId, content.Kind, content.Id, time.Now().Add(-c.window), c.lookback)
+	if err != nil {
+		return nil, err
+	}
+
+	best := 0.0
+	for _, text := range recent {
+		if s := Similarity(shingles, Shingles(text)); s > best {
+			best = s
+		}
+	}
+
+	if best < c.threshold {
+		return nil, nil
+	}
+	return []Finding{{
+		Check:   c.Name(),
+		Verdict: Hold.String(),
+		Reason:  fmt.Sprintf("is %.0f%% similar to a recent %s of the author", best*100, content.Kind),
+	}}, nil
+}
+
+// Returns the set of three word shingles of the text. Texts shorter than three words have none,
+// repeating a short reply such as "thank you" is not spam.
+func Shingles(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	shingles := make(map[string]struct{})
+	for i := 0; i+3 <= len(words); i++ {
+		shingles[strings.Join(words[i:i+3], " ")] = struct{}{}
+	}
+	return shingles
+}
+
+// Jaccard similarity of two shingle sets, from 0 for nothing in common to 1 for the same text
+func Similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for s := range a {
+		if _, ok := b[s]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
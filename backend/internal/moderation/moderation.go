@@ -0,0 +1,127 @@
+// Package moderation runs automated checks on the text users publish. Every check gives a verdict
+// and the pipeline keeps the strictest one, so a single failing check is enough to stop content.
+package moderation
+
+import (
+	"context"
+	"fmt"
+)
+
+// What happens to checked content, ordered from the most to the least permissive
+type Verdict int
+
+const (
+	Allow  Verdict = iota
+	Flag           // Published, but moderators should have a look
+	Hold           // Hidden from everyone but the author until a moderator approves it
+	Reject         // Not stored at all
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Flag:
+		return "flag"
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// Parses the name of a verdict, it is how rules store their action
+func ParseVerdict(name string) (Verdict, error) {
+	for _, v := range []Verdict{Allow, Flag, Hold, Reject} {
+		if v.String() == name {
+			return v, nil
+		}
+	}
+	return Allow, fmt.Errorf("unknown verdict %q", name)
+}
+
+// Kinds of content
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+)
+
+// The text being published
+type Content struct {
+	Id       int64 // Set when existing content is edited, so it is not compared with itself
+	Kind     string
+	AuthorId int64
+	Title    string
+	Body     string
+	Tags     []string
+}
+
+// All the text of the content, as the checks read it
+func (c *Content) Text() string {
+	text := c.Title + "\n" + c.Body
+	for _, tag := range c.Tags {
+		text += "\n" + tag
+	}
+	return text
+}
+
+// Why a check did not simply allow the content
+type Finding struct {
+	Check   string `json:"check"`
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason"`
+	RuleId  int64  `json:"rule_id,omitempty"` // Set when an admin managed rule matched
+}
+
+type Result struct {
+	Verdict  Verdict
+	Findings []Finding
+}
+
+// Reasons of the findings which decided the verdict, meant for the author
+func (r *Result) Reasons() []string {
+	var reasons []string
+	for _, f := range r.Findings {
+		if f.Verdict == r.Verdict.String() {
+			reasons = append(reasons, f.Reason)
+		}
+	}
+	return reasons
+}
+
+type Check interface {
+	Name() string
+	// Returns the findings about the content, none when the check allows it
+	Check(ctx context.Context, content *Content) ([]Finding, error)
+}
+
+type Pipeline struct {
+	checks []Check
+}
+
+func NewPipeline(checks ...Check) *Pipeline {
+	return &Pipeline{checks: checks}
+}
+
+// Runs every check, the findings of all of them are kept so the recorded decision tells the whole story
+func (p *Pipeline) Run(ctx context.Context, content *Content) (*Result, error) {
+	result := &Result{Verdict: Allow, Findings: []Finding{}}
+	for _, check := range p.checks {
+		findings, err := check.Check(ctx, content)
+		if err != nil {
+			return nil, fmt.Errorf("moderation check %s: %w", check.Name(), err)
+		}
+
+		for _, f := range findings {
+			verdict, err := ParseVerdict(f.Verdict)
+			if err != nil {
+				return nil, fmt.Errorf("moderation check %s: %w", check.Name(), err)
+			}
+			if verdict > result.Verdict {
+				result.Verdict = verdict
+			}
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	return result, nil
+}
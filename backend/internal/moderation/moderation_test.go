@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+type testRules []store.ModerationRule
+
+func (r testRules) GetRules(ctx context.Context) ([]store.ModerationRule, error) {
+	return r, nil
+}
+
+type testHistory []string
+
+func (h testHistory) GetRecentContent(ctx context.Context, userId int64, kind string, excludeId int64, since time.Time, limit int) ([]string, error) {
+	return h, nil
+}
+
+func TestPipeline(t *testing.T) {
+	rules := testRules{
+		{Id: 1, Kind: store.ModerationRuleTerm, Pattern: "scam", Action: "hold"},
+		{Id: 2, Kind: store.ModerationRuleTerm, Pattern: "$$$", Action: "flag"},
+		{Id: 3, Kind: store.ModerationRuleRegex, Pattern: `buy\s+followers`, Action: "reject"},
+		{Id: 4, Kind: store.ModerationRuleDomain, Pattern: "spam.example", Action: "reject"},
+	}
+	history := testHistory{"Big news\nour new release is out today with many fixes"}
+	pipeline := NewPipeline(NewRulesCheck(rules, time.Minute), NewDuplicateCheck(history, 0.8, time.Hour, 10))
+
+	tests := []struct {
+		name    string
+		content Content
+		verdict Verdict
+	}{
+		{"clean", Content{Title: "Hello", Body: "a scampi recipe"}, Allow},
+		{"term", Content{Title: "Hello", Body: "This is a SCAM!"}, Hold},
+		{"term without word characters", Content{Body: "make $$$ fast"}, Flag},
+		{"regex", Content{Body: "Buy   followers here"}, Reject},
+		{"subdomain of a blocked domain", Content{Body: "see https://www.cheap.spam.example/offer"}, Reject},
+		{"tag", Content{Body: "hi", Tags: []string{"scam"}}, Hold},
+		{"near duplicate", Content{Title: "Big news", Body: "our new release is out today with many fixes!!"}, Hold},
+		{"different text", Content{Title: "Big news", Body: "we are hiring two engineers for the backend team"}, Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.content.Kind = KindPost
+			result, err := pipeline.Run(context.Background(), &tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Verdict != tt.verdict {
+				t.Errorf("expected %s, got %s (%v)", tt.verdict, result.Verdict, result.Findings)
+			}
+			if tt.verdict != Allow && len(result.Reasons()) == 0 {
+				t.Error("expected the reasons of the verdict")
+			}
+		})
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := map[string]string{
+		"Example.COM":                 "example.com",
+		"https://www.example.com/a?b": "example.com",
+		"sub.example.co.uk.":          "sub.example.co.uk",
+		"not a domain":                "",
+		"localhost":                   "",
+	}
+
+	for in, want := range tests {
+		if got := NormalizeDomain(in); got != want {
+			t.Errorf("NormalizeDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if MatchesDomain("notexample.com", "example.com") {
+		t.Error("a domain must not match another one ending with the same letters")
+	}
+}
@@ -0,0 +1,199 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+)
+
+// Where the admin managed rules are stored
+type RuleSource interface {
+	GetRules(ctx context.Context) ([]store.ModerationRule, error)
+}
+
+// Matches host names in the text, with or without a scheme in front of them
+var domainRe = regexp.MustCompile(`(?i)(?:https?://)?((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,})`)
+
+type compiledRule struct {
+	store.ModerationRule
+	re *regexp.Regexp // Terms and regexes, domains are compared as strings
+}
+
+// Checks the text against the banned terms, regex rules and blocked link domains. The rules are
+// loaded from the source and kept for the refresh interval, so publishing does not query them
+// every time. Invalidate drops them right away, after an admin changed a rule.
+type RulesCheck struct {
+	source  RuleSource
+	refresh time.Duration
+
+	mu       sync.Mutex
+	rules    []compiledRule
+	loadedAt time.Time
+}
+
+func NewRulesCheck(source RuleSource, refresh time.Duration) *RulesCheck {
+	return &RulesCheck{source: source, refresh: refresh}
+}
+
+func (c *RulesCheck) Name() string {
+	return "rules"
+}
+
+func (c *RulesCheck) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = nil
+}
+
+func (c *RulesCheck) Check(ctx context.Context, content *Content) ([]Finding, error) {
+	rules, err := c.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	text := content.Text()
+	var domains []string
+	var findings []Finding
+	for _, rule := range rules {
+		var reason string
+		switch rule.Kind {
+		case store.ModerationRuleTerm:
+			if rule.re.MatchString(text) {
+				reason = fmt.Sprintf("contains the banned term %q", rule.Pattern)
+			}
+		case store.ModerationRuleRegex:
+			if rule.re.MatchString(text) {
+				reason = "matches a blocked pattern"
+			}
+		case store.ModerationRuleDomain:
+			if domains == nil {
+				domains = LinkDomains(text)
+			}
+			for _, domain := range domains {
+				if MatchesDomain(domain, rule.Pattern) {
+					reason = fmt.Sprintf("links to the blocked domain %s", rule.Pattern)
+					break
+				}
+			}
+		}
+
+		if reason != "" {
+			findings = append(findings, Finding{
+				Check:   rule.Kind,
+				Verdict: rule.Action,
+				Reason:  reason,
+				RuleId:  rule.Id,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// Compiles a rule the way the check uses it, so invalid rules can be refused before they are stored
+func CompileRule(rule store.ModerationRule) (*regexp.Regexp, error) {
+	switch rule.Kind {
+	case store.ModerationRuleTerm:
+		return termRegexp(rule.Pattern), nil
+	case store.ModerationRuleRegex:
+		// Rules are case insensitive unless they say otherwise
+		return regexp.Compile("(?i)" + rule.Pattern)
+	case store.ModerationRuleDomain:
+		if NormalizeDomain(rule.Pattern) == "" {
+			return nil, fmt.Errorf("%q is not a domain", rule.Pattern)
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown rule kind %q", rule.Kind)
+	}
+}
+
+// Returns the host names of the links in the text, lower cased and without a www. prefix
+func LinkDomains(text string) []string {
+	domains := []string{}
+	for _, m := range domainRe.FindAllStringSubmatch(text, -1) {
+		if domain := NormalizeDomain(m[1]); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// Turns what an admin typed, a bare domain or a whole link, into a domain
+func NormalizeDomain(s string) string {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return ""
+		}
+		s = u.Hostname()
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "www."), ".")
+	if !domainRe.MatchString(s) || domainRe.FindString(s) != s {
+		return ""
+	}
+	return s
+}
+
+// A blocked domain also blocks its subdomains
+func MatchesDomain(domain string, blocked string) bool {
+	blocked = NormalizeDomain(blocked)
+	return blocked != "" && (domain == blocked || strings.HasSuffix(domain, "."+blocked))
+}
+
+/* Helper Functions */
+
+func (c *RulesCheck) load(ctx context.Context) ([]compiledRule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rules != nil && time.Since(c.loadedAt) < c.refresh {
+		return c.rules, nil
+	}
+
+	rules, err := c.source.GetRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := CompileRule(rule)
+		if err != nil {
+			// Rules are validated when they are created, a broken one must not stop publishing
+			continue
+		}
+		compiled = append(compiled, compiledRule{ModerationRule: rule, re: re})
+	}
+
+	c.rules = compiled
+	c.loadedAt = time.Now()
+	return c.rules, nil
+}
+
+// Matches the term as a whole word, "ass" must not match "class". Word boundaries are only added
+// on the sides of the term which are word characters, a term like "$$$" has none to match.
+func termRegexp(term string) *regexp.Regexp {
+	term = strings.TrimSpace(term)
+	pattern := regexp.QuoteMeta(term)
+	if r := []rune(term); len(r) > 0 {
+		if isWordRune(r[0]) {
+			pattern = `\b` + pattern
+		}
+		if isWordRune(r[len(r)-1]) {
+			pattern = pattern + `\b`
+		}
+	}
+	return regexp.MustCompile("(?i)" + pattern)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
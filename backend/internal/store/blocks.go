@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSelfBlock = errors.New("users can not block themselves")
+	ErrBlocked   = errors.New("one of the users blocked the other")
+)
+
+type BlocksStore struct {
+	db *sql.DB
+}
+
+// Blocks the other user. A block cuts both users off from each other, so the follows between
+// them, their pending follow requests and their close friends entries are removed with it.
+func (s *BlocksStore) Block(ctx context.Context, userId int64, blockedId int64) error {
+	if userId == blockedId {
+		return ErrSelfBlock
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_blocks (user_id, blocked_id)
+			VALUES ($1, $2)
+		`, userId, blockedId)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case "23505": // unique_violation
+					return ErrConflict
+				case "23503": // foreign_key_violation, the blocked user does not exist
+					return ErrNotFound
+				}
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`, userId, blockedId); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM follow_requests
+			WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+		`, userId, blockedId); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM close_friends
+			WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+		`, userId, blockedId)
+		return err
+	})
+}
+
+// Lifts the block, the follows it removed are not restored
+func (s *BlocksStore) Unblock(ctx context.Context, userId int64, blockedId int64) error {
+	query := `
+		DELETE FROM user_blocks
+		WHERE user_id = $1 AND blocked_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, blockedId)
+	return err
+}
+
+// Returns the users blocked by the user, the most recently blocked first unless sort=asc
+func (s *BlocksStore) GetBlocked(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = $1
+		ORDER BY b.created_at ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+// Reports whether either of the users blocked the other
+func (s *BlocksStore) IsBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	query := `SELECT ` + blockedCondition("$1", "$2")
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userId, otherId).Scan(&blocked); err != nil {
+		return false, err
+	}
+	return blocked, nil
+}
+
+/* Helper Functions */
+
+// SQL condition which holds when either of the users blocked the other, the arguments are SQL
+// expressions such as placeholders or columns
+func blockedCondition(a, b string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM user_blocks
+		WHERE (user_id = %[1]s AND blocked_id = %[2]s) OR (user_id = %[2]s AND blocked_id = %[1]s)
+	)`, a, b)
+}
+
+// SQL condition which holds when the viewer muted the author
+func mutedCondition(viewer, author string) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM user_mutes WHERE user_id = %s AND muted_id = %s)`, viewer, author)
+}
+
+// SQL condition which holds when one of the texts contains a word muted by the viewer. Words are
+// matched case insensitively anywhere in the text.
+func mutedWordCondition(viewer string, texts ...string) string {
+	matches := ""
+	for i, text := range texts {
+		if i > 0 {
+			matches += " OR "
+		}
+		matches += fmt.Sprintf("strpos(lower(%s), lower(mw.word)) > 0", text)
+	}
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM muted_words mw WHERE mw.user_id = %s AND (%s))`, viewer, matches)
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestBlock(t *testing.T) {
+	t.Run("should remove the follows, follow requests and close friends of both users with the block", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		s := &BlocksStore{db: db}
+
+		if err := s.Block(context.Background(), 1, 2); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"BEGIN", "INSERT INTO user_blocks", "DELETE FROM followers", "DELETE FROM follow_requests", "DELETE FROM close_friends", "COMMIT"}
+		checkQueries(t, rec.queries(), want)
+		for _, statement := range rec.statements[1:5] {
+			if !reflect.DeepEqual(statement.args, []any{int64(1), int64(2)}) {
+				t.Errorf("%q run with %v, want [1 2]", statement.query, statement.args)
+			}
+		}
+		for _, statement := range rec.statements[2:5] {
+			// Both directions of the relationship go
+			if !strings.Contains(statement.query, "OR") {
+				t.Errorf("%q only removes one direction", statement.query)
+			}
+		}
+	})
+
+	t.Run("should keep the follows when the user was blocked already", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		rec.failing["INSERT INTO user_blocks"] = &pq.Error{Code: "23505"}
+		s := &BlocksStore{db: db}
+
+		if err := s.Block(context.Background(), 1, 2); !errors.Is(err, ErrConflict) {
+			t.Fatalf("got error %v, want %v", err, ErrConflict)
+		}
+		checkQueries(t, rec.queries(), []string{"BEGIN", "INSERT INTO user_blocks", "ROLLBACK"})
+	})
+
+	t.Run("should not block the user itself", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		s := &BlocksStore{db: db}
+
+		if err := s.Block(context.Background(), 1, 1); !errors.Is(err, ErrSelfBlock) {
+			t.Fatalf("got error %v, want %v", err, ErrSelfBlock)
+		}
+		checkQueries(t, rec.queries(), nil)
+	})
+}
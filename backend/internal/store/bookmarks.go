@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// A named folder of bookmarks, only its owner sees it
+type BookmarkFolder struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"` // Bookmarks in the folder
+	CreatedAt string `json:"created_at"`
+}
+
+// A post the user saved for later, only its owner sees it
+type Bookmark struct {
+	UserId    int64             `json:"user_id"`
+	PostId    int64             `json:"post_id"`
+	FolderId  *int64            `json:"folder_id"` // Nil for bookmarks outside of any folder
+	CreatedAt string            `json:"created_at"`
+	Post      *PostWithMetaData `json:"post,omitempty"`
+}
+
+type BookmarksStore struct {
+	db *sql.DB
+}
+
+// Bookmarks the post, or moves an existing bookmark to the folder. A folder which does not exist
+// or belongs to someone else is ErrNotFound.
+func (s *BookmarksStore) Add(ctx context.Context, bookmark *Bookmark) error {
+	query := `
+		INSERT INTO bookmarks (user_id, post_id, folder_id)
+		SELECT $1, $2, $3
+		WHERE $3::bigint IS NULL OR EXISTS (SELECT 1 FROM bookmark_folders WHERE id = $3 AND user_id = $1)
+		ON CONFLICT (user_id, post_id) DO UPDATE SET folder_id = EXCLUDED.folder_id
+		RETURNING created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, bookmark.UserId, bookmark.PostId, bookmark.FolderId).Scan(&bookmark.CreatedAt)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BookmarksStore) Remove(ctx context.Context, userId int64, postId int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, postId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Returns the bookmarks of the user with their posts, the newest bookmark first unless fq.Sort is
+// asc. A nil folderId lists every folder, fq.Tags keeps the posts with any of the tags. Posts the
+// user can not see anymore are left out but keep their bookmark.
+func (s *BookmarksStore) GetByUserId(ctx context.Context, userId int64, folderId *int64, fq PaginatedFeedQuery) ([]Bookmark, error) {
+	query := `
+		SELECT
+			b.user_id, b.post_id, b.folder_id, b.created_at,
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.held_at IS NULL)
+		FROM bookmarks b
+		JOIN posts p ON p.id = b.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE b.user_id = $1 AND p.deleted_at IS NULL AND p.status = 'published'
+			AND ($2::bigint IS NULL OR b.folder_id = $2)
+			AND (
+				cardinality($5::text[]) = 0
+				OR EXISTS (
+					SELECT 1 FROM post_tags pt
+					JOIN tags t ON t.id = pt.tag_id
+					WHERE pt.post_id = p.id AND t.name = ANY($5)
+				)
+			)
+			AND (p.title ILIKE '%' || $6 || '%' OR p.content ILIKE '%' || $6 || '%')
+			AND (
+				p.user_id = $1
+				OR (
+					p.held_at IS NULL
+					AND (
+						NOT u.is_private
+						OR EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $1)
+					)
+					AND ` + audienceCondition("p", "$1") + `
+					AND NOT ` + blockedCondition("p.user_id", "$1") + `
+				)
+			)
+		ORDER BY b.created_at ` + fq.Sort + `, b.post_id ` + fq.Sort + `
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, folderId, fq.Limit, fq.Offset, pq.Array(fq.Tags), fq.Search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		b := Bookmark{Post: &PostWithMetaData{}}
+		p := b.Post
+		err := rows.Scan(
+			&b.UserId,
+			&b.PostId,
+			&b.FolderId,
+			&b.CreatedAt,
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.PublishedAt,
+			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
+			pq.Array(&p.Tags),
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Bookmarked = true
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+// Returns which of the posts the user bookmarked
+func (s *BookmarksStore) GetBookmarkedIds(ctx context.Context, userId int64, postIds []int64) (map[int64]bool, error) {
+	bookmarked := make(map[int64]bool)
+	if len(postIds) == 0 {
+		return bookmarked, nil
+	}
+
+	query := `SELECT post_id FROM bookmarks WHERE user_id = $1 AND post_id = ANY($2)`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postId int64
+		if err := rows.Scan(&postId); err != nil {
+			return nil, err
+		}
+		bookmarked[postId] = true
+	}
+	return bookmarked, rows.Err()
+}
+
+func (s *BookmarksStore) CreateFolder(ctx context.Context, folder *BookmarkFolder) error {
+	query := `
+		INSERT INTO bookmark_folders (user_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, folder.UserId, folder.Name).Scan(&folder.Id, &folder.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// Returns the folders of the user by name, with how many bookmarks each holds
+func (s *BookmarksStore) GetFolders(ctx context.Context, userId int64) ([]BookmarkFolder, error) {
+	query := `
+		SELECT f.id, f.user_id, f.name, COUNT(b.post_id), f.created_at
+		FROM bookmark_folders f
+		LEFT JOIN bookmarks b ON b.folder_id = f.id
+		WHERE f.user_id = $1
+		GROUP BY f.id
+		ORDER BY lower(f.name)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []BookmarkFolder{}
+	for rows.Next() {
+		var f BookmarkFolder
+		if err := rows.Scan(&f.Id, &f.UserId, &f.Name, &f.Count, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// Deletes a folder of the user, its bookmarks stay outside of any folder
+func (s *BookmarksStore) DeleteFolder(ctx context.Context, userId int64, folderId int64) error {
+	query := `DELETE FROM bookmark_folders WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, folderId, userId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -9,7 +9,8 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:     &MockUserStore{},
+		Timelines: &MockTimelineStore{},
 	}
 }
 
@@ -30,3 +31,37 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 func (m *MockUserStore) Delete(ctx context.Context, userID int64) {
 	m.Called(userID)
 }
+
+type MockTimelineStore struct {
+	mock.Mock
+}
+
+func (m *MockTimelineStore) Get(ctx context.Context, userID int64, count int) ([]store.TimelineEntry, bool, error) {
+	args := m.Called(userID, count)
+	entries, _ := args.Get(0).([]store.TimelineEntry)
+	return entries, args.Bool(1), args.Error(2)
+}
+
+func (m *MockTimelineStore) Rebuild(ctx context.Context, userID int64, entries []store.TimelineEntry) error {
+	args := m.Called(userID, entries)
+	return args.Error(0)
+}
+
+func (m *MockTimelineStore) Push(ctx context.Context, entry store.TimelineEntry, userIds []int64) error {
+	args := m.Called(entry, userIds)
+	return args.Error(0)
+}
+
+func (m *MockTimelineStore) Delete(ctx context.Context, userID int64) {
+	m.Called(userID)
+}
+
+func (m *MockTimelineStore) AddLargeAccount(ctx context.Context, userID int64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockTimelineStore) GetLargeAccounts(ctx context.Context) ([]int64, error) {
+	args := m.Called()
+	return nil, args.Error(1)
+}
@@ -13,10 +13,19 @@ type Storage struct {
 		Set(context.Context, *store.User) error
 		Delete(context.Context, int64)
 	}
+	Timelines interface {
+		Get(context.Context, int64, int) ([]store.TimelineEntry, bool, error)
+		Rebuild(context.Context, int64, []store.TimelineEntry) error
+		Push(context.Context, store.TimelineEntry, []int64) error
+		Delete(context.Context, int64)
+		AddLargeAccount(context.Context, int64) error
+		GetLargeAccounts(context.Context) ([]int64, error)
+	}
 }
 
 func NewRedisStorage(rbd *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rbd},
+		Users:     &UserStore{rdb: rbd},
+		Timelines: &TimelineStore{rdb: rbd},
 	}
 }
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// Timelines are sorted sets of post ids scored by the creation time of the post. Each one also
+// holds a marker scored +inf, so a timeline without posts is told apart from a missing one.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+const (
+	TimelineExpTime   = time.Hour * 6 // Unused timelines are dropped and rebuilt when they are read again
+	TimelineMaxLength = 800           // Older posts are only reachable through the database
+
+	timelineMarker      = "0"
+	largeAccountsKey    = "timeline-large-accounts"
+	timelinePushBatch   = 500
+	timelineKeyTemplate = "timeline-%d"
+)
+
+// Adds the post to the timelines which exist and trims them, missing ones are rebuilt with the post
+// when they are read
+var pushScript = redis.NewScript(`
+	for _, key in ipairs(KEYS) do
+		if redis.call('EXISTS', key) == 1 then
+			redis.call('ZADD', key, ARGV[1], ARGV[2])
+			redis.call('ZREMRANGEBYRANK', key, 0, -tonumber(ARGV[3]) - 2)
+		end
+	end
+	return 0
+`)
+
+// Returns the newest count entries of the timeline of the user, found is false when the user has
+// no timeline and it has to be rebuilt
+func (s *TimelineStore) Get(ctx context.Context, userID int64, count int) ([]store.TimelineEntry, bool, error) {
+	members, err := s.rdb.ZRevRangeWithScores(ctx, timelineKey(userID), 0, int64(count)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(members) == 0 {
+		return nil, false, nil
+	}
+
+	entries := make([]store.TimelineEntry, 0, len(members))
+	for _, m := range members {
+		member, _ := m.Member.(string)
+		if member == timelineMarker {
+			continue
+		}
+		postId, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, false, err
+		}
+		entries = append(entries, store.TimelineEntry{PostId: postId, CreatedAt: time.Unix(int64(m.Score), 0)})
+	}
+	return entries, true, nil
+}
+
+// Replaces the timeline of the user with the entries
+func (s *TimelineStore) Rebuild(ctx context.Context, userID int64, entries []store.TimelineEntry) error {
+	key := timelineKey(userID)
+	members := make([]redis.Z, 0, len(entries)+1)
+	members = append(members, redis.Z{Score: math.Inf(1), Member: timelineMarker})
+	for _, e := range entries {
+		members = append(members, redis.Z{Score: float64(e.CreatedAt.Unix()), Member: e.PostId})
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.ZAdd(ctx, key, members...)
+	pipe.ZRemRangeByRank(ctx, key, 0, -TimelineMaxLength-2)
+	pipe.Expire(ctx, key, TimelineExpTime)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Adds the entry to the timelines of the users which currently exist
+func (s *TimelineStore) Push(ctx context.Context, entry store.TimelineEntry, userIds []int64) error {
+	for start := 0; start < len(userIds); start += timelinePushBatch {
+		end := min(start+timelinePushBatch, len(userIds))
+
+		keys := make([]string, 0, end-start)
+		for _, id := range userIds[start:end] {
+			keys = append(keys, timelineKey(id))
+		}
+		err := pushScript.Run(ctx, s.rdb, keys, entry.CreatedAt.Unix(), entry.PostId, TimelineMaxLength).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drops the timeline of the user, the next read rebuilds it
+func (s *TimelineStore) Delete(ctx context.Context, userID int64) {
+	s.rdb.Del(ctx, timelineKey(userID))
+}
+
+// Remembers that the posts of the user are not pushed to their followers
+func (s *TimelineStore) AddLargeAccount(ctx context.Context, userID int64) error {
+	return s.rdb.SAdd(ctx, largeAccountsKey, userID).Err()
+}
+
+// Returns the users whose posts are merged into timelines when they are read
+func (s *TimelineStore) GetLargeAccounts(ctx context.Context) ([]int64, error) {
+	members, err := s.rdb.SMembers(ctx, largeAccountsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf(timelineKeyTemplate, userID)
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var ErrSelfCloseFriend = errors.New("users can not add themselves to their close friends")
+
+type CloseFriendsStore struct {
+	db *sql.DB
+}
+
+// Adds the other user to the close friends of the user, they see the posts the user shares with
+// close friends. The other user is not told.
+func (s *CloseFriendsStore) Add(ctx context.Context, userId int64, friendId int64) error {
+	if userId == friendId {
+		return ErrSelfCloseFriend
+	}
+
+	query := `
+		INSERT INTO close_friends (user_id, friend_id)
+		VALUES ($1, $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, query, userId, friendId); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505": // unique_violation
+				return ErrConflict
+			case "23503": // foreign_key_violation, the friend does not exist
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *CloseFriendsStore) Remove(ctx context.Context, userId int64, friendId int64) error {
+	query := `
+		DELETE FROM close_friends
+		WHERE user_id = $1 AND friend_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, friendId)
+	return err
+}
+
+// Returns the close friends of the user, the most recently added first unless sort=asc
+func (s *CloseFriendsStore) GetByUserId(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, cf.created_at
+		FROM close_friends cf
+		JOIN users u ON u.id = cf.friend_id
+		WHERE cf.user_id = $1
+		ORDER BY cf.created_at ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+// Returns the ids of the close friends of the user which the posts of the user reach, close friends
+// who muted the user are left out
+func (s *CloseFriendsStore) GetIds(ctx context.Context, userId int64) ([]int64, error) {
+	query := `
+		SELECT friend_id FROM close_friends
+		WHERE user_id = $1 AND NOT ` + mutedCondition("friend_id", "$1") + `
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Reports whether the friend is one of the close friends of the user
+func (s *CloseFriendsStore) IsCloseFriend(ctx context.Context, userId int64, friendId int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM close_friends WHERE user_id = $1 AND friend_id = $2)`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	var isCloseFriend bool
+	if err := s.db.QueryRowContext(ctx, query, userId, friendId).Scan(&isCloseFriend); err != nil {
+		return false, err
+	}
+	return isCloseFriend, nil
+}
+
+/* Helper Functions */
+
+// SQL condition which holds when the visibility of the post lets the viewer see it, the author
+// always sees their own posts. post is the alias of the posts table and viewer an SQL expression.
+func audienceCondition(post, viewer string) string {
+	return fmt.Sprintf(`(
+		%[1]s.user_id = %[2]s
+		OR %[1]s.visibility = 'public'
+		OR (
+			%[1]s.visibility = 'followers'
+			AND EXISTS (SELECT 1 FROM followers WHERE user_id = %[1]s.user_id AND follower_id = %[2]s)
+		)
+		OR (
+			%[1]s.visibility = 'close_friends'
+			AND EXISTS (SELECT 1 FROM close_friends WHERE user_id = %[1]s.user_id AND friend_id = %[2]s)
+		)
+	)`, post, viewer)
+}
@@ -4,15 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
+
+	"github.com/Sumitwarrior7/social/internal/markdown"
 )
 
 type Comment struct {
-	Id        int64
-	PostId    int64
-	UserId    int64
-	Content   string
-	CreatedAt string
-	User      User
+	Id          int64
+	PostId      int64
+	UserId      int64
+	Content     string
+	ContentHtml string // Rendered from the Markdown of the content, safe to embed as it is
+	CreatedAt   string
+	UpdatedAt   string
+	Version     int64   // Used for optimistic concurrency on updates
+	DeletedAt   *string `json:",omitempty"` // Only set for comments in the trash
+	DeletedBy   *int64  `json:",omitempty"`
+	HeldAt      *string `json:",omitempty"` // Set while the comment waits for a moderator, only its author and moderators see it
+	User        User
 }
 
 type CommentsStore struct {
@@ -21,9 +30,9 @@ type CommentsStore struct {
 
 func (s *CommentsStore) GetById(ctx context.Context, commentId int64) (*Comment, error) {
 	query := `
-		SELECT id, user_id, post_id, content, created_at
+		SELECT id, user_id, post_id, content, content_html, created_at, updated_at, version, held_at
 		FROM comments
-		WHERE id = $1;
+		WHERE id = $1 AND deleted_at IS NULL;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
@@ -40,12 +49,16 @@ func (s *CommentsStore) GetById(ctx context.Context, commentId int64) (*Comment,
 		&comment.UserId,
 		&comment.PostId,
 		&comment.Content,
+		&comment.ContentHtml,
 		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.Version,
+		&comment.HeldAt,
 	)
 
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrNotFound):
+		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
 		default:
 			return nil, err
@@ -57,9 +70,11 @@ func (s *CommentsStore) GetById(ctx context.Context, commentId int64) (*Comment,
 
 func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-		INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3) RETURNING id, created_at
+		INSERT INTO comments (post_id, user_id, content, held_at, content_html)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at, version, held_at
 	`
+	comment.ContentHtml = markdown.Render(comment.Content)
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
 	defer cancel()
 
@@ -69,9 +84,14 @@ func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 		comment.PostId,
 		comment.UserId,
 		comment.Content,
+		comment.HeldAt,
+		comment.ContentHtml,
 	).Scan(
 		&comment.Id,
 		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.Version,
+		&comment.HeldAt,
 	)
 
 	if err != nil {
@@ -106,35 +126,69 @@ func (s *CommentsStore) Delete(ctx context.Context, commentId int64) error {
 	return nil
 }
 
+// Renders the content of up to limit comments saved before content was rendered, from the one
+// after afterId on. It returns the id of the last comment it went through, 0 when none were left.
+func (s *CommentsStore) RenderMissing(ctx context.Context, afterId int64, limit int) (int64, error) {
+	return renderMissing(ctx, s.db, "comments", afterId, limit)
+}
+
+// Saves the new content, it fails with ErrEditConflict if the comment was changed since it was read.
+// Like posts, an edit can put the comment on hold but never releases it.
 func (s *CommentsStore) Update(ctx context.Context, comment *Comment) error {
 	query := `
 		UPDATE comments 
-		SET content = $1
-		WHERE id = $2;
+		SET content = $1, content_html = $5, version = version+1, updated_at = NOW(), held_at = COALESCE(held_at, $4)
+		WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+		RETURNING version, updated_at, held_at;
 	`
+	comment.ContentHtml = markdown.Render(comment.Content)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(
+	err := s.db.QueryRowContext(
 		ctx,
 		query,
 		comment.Content,
 		comment.Id,
+		comment.Version,
+		comment.HeldAt,
+		comment.ContentHtml,
+	).Scan(
+		&comment.Version,
+		&comment.UpdatedAt,
+		&comment.HeldAt,
 	)
 
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
 	}
 	return nil
 }
 
-func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Comment, error) {
+// Returns the comments of a post as the viewer sees them, without the comments of users the viewer
+// blocked, was blocked by or muted, the ones containing words the viewer muted, nor the ones held
+// for review. The viewer's own comments are always shown.
+func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64, viewerId int64) ([]Comment, error) {
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, c.version, c.held_at, u.username
 		FROM comments AS c
 		JOIN users AS u ON u.id = c.user_id
-		WHERE c.post_id = $1
+		WHERE c.post_id = $1 AND c.deleted_at IS NULL
+			AND (
+				c.user_id = $2
+				OR (
+					c.held_at IS NULL
+					AND NOT ` + blockedCondition("c.user_id", "$2") + `
+					AND NOT ` + mutedCondition("$2", "c.user_id") + `
+					AND NOT ` + mutedWordCondition("$2", "c.content") + `
+				)
+			)
 		ORDER BY c.created_at DESC;
 	`
 
@@ -146,6 +200,7 @@ func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Commen
 		ctx,
 		query,
 		postId,
+		viewerId,
 	)
 	if err != nil {
 		return nil, err
@@ -161,7 +216,11 @@ func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Commen
 			&c.PostId,
 			&c.UserId,
 			&c.Content,
+			&c.ContentHtml,
 			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Version,
+			&c.HeldAt,
 			&c.User.Username,
 		)
 		if err != nil {
@@ -172,3 +231,140 @@ func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Commen
 
 	return comments, nil
 }
+
+// Moves a comment to the trash of its author, it stays restorable until the purge job removes it
+func (s *CommentsStore) SoftDelete(ctx context.Context, commentId int64, deletedBy int64) error {
+	query := `
+		UPDATE comments SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentId, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Returns a comment from the trash
+func (s *CommentsStore) GetDeletedById(ctx context.Context, commentId int64) (*Comment, error) {
+	query := `
+		SELECT id, user_id, post_id, content, content_html, created_at, updated_at, version, deleted_at, deleted_by
+		FROM comments
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	comment := &Comment{}
+	err := s.db.QueryRowContext(ctx, query, commentId).Scan(
+		&comment.Id,
+		&comment.UserId,
+		&comment.PostId,
+		&comment.Content,
+		&comment.ContentHtml,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.Version,
+		&comment.DeletedAt,
+		&comment.DeletedBy,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return comment, nil
+}
+
+func (s *CommentsStore) Restore(ctx context.Context, commentId int64) error {
+	query := `
+		UPDATE comments SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Returns the deleted comments of a user, the most recently deleted first
+func (s *CommentsStore) GetTrash(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]Comment, error) {
+	query := `
+		SELECT id, user_id, post_id, content, content_html, created_at, updated_at, version, deleted_at, deleted_by
+		FROM comments
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(
+			&c.Id,
+			&c.UserId,
+			&c.PostId,
+			&c.Content,
+			&c.ContentHtml,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Version,
+			&c.DeletedAt,
+			&c.DeletedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// Permanently deletes the comments which have been in the trash since before the provided time
+func (s *CommentsStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM comments WHERE deleted_at < $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// A database which records the statements run on it instead of running them, so the stores can be
+// tested without Postgres. Statements starting with one of the failing prefixes return its error,
+// queries starting with one of the results prefixes return its rows and others return no rows.
+// Other statements affect one row, unless they start with one of the unaffected prefixes.
+type recordingDB struct {
+	statements []recordedStatement
+	failing    map[string]error
+	results    map[string][][]driver.Value
+	unaffected []string
+}
+
+type recordedStatement struct {
+	query string // With the whitespace collapsed
+	args  []any
+}
+
+func newRecordingDB() (*sql.DB, *recordingDB) {
+	rec := &recordingDB{failing: map[string]error{}, results: map[string][][]driver.Value{}}
+	return sql.OpenDB(rec), rec
+}
+
+// The queries run, in order. Transactions show up as BEGIN, COMMIT and ROLLBACK.
+func (rec *recordingDB) queries() []string {
+	queries := make([]string, len(rec.statements))
+	for i, statement := range rec.statements {
+		queries[i] = statement.query
+	}
+	return queries
+}
+
+func (rec *recordingDB) record(query string, args []any) error {
+	query = strings.Join(strings.Fields(query), " ")
+	rec.statements = append(rec.statements, recordedStatement{query: query, args: args})
+	for prefix, err := range rec.failing {
+		if strings.HasPrefix(query, prefix) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rec *recordingDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{rec: rec}, nil
+}
+
+func (rec *recordingDB) Driver() driver.Driver {
+	return nil
+}
+
+type recordingConn struct {
+	rec *recordingDB
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not recorded")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recordingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.rec.record("BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *recordingConn) Commit() error {
+	return c.rec.record("COMMIT", nil)
+}
+
+func (c *recordingConn) Rollback() error {
+	return c.rec.record("ROLLBACK", nil)
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	if err := c.rec.record(query, values); err != nil {
+		return nil, err
+	}
+	for _, prefix := range c.rec.unaffected {
+		if strings.HasPrefix(strings.Join(strings.Fields(query), " "), prefix) {
+			return driver.RowsAffected(0), nil
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if _, err := c.ExecContext(ctx, query, args); err != nil {
+		return nil, err
+	}
+
+	query = c.rec.statements[len(c.rec.statements)-1].query
+	for prefix, rows := range c.rec.results {
+		if strings.HasPrefix(query, prefix) {
+			return &recordedRows{rows: rows}, nil
+		}
+	}
+	return &recordedRows{}, nil
+}
+
+type recordedRows struct {
+	rows [][]driver.Value
+}
+
+func (r *recordedRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *recordedRows) Close() error {
+	return nil
+}
+
+func (r *recordedRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// Checks the queries run are the wanted ones in the same order, each compared by its start
+func checkQueries(t *testing.T, queries []string, want []string) {
+	t.Helper()
+
+	if len(queries) != len(want) {
+		t.Fatalf("ran %q, want %q", queries, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(queries[i], want[i]) {
+			t.Fatalf("ran %q, want %q", queries, want)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Exports are built by a background worker, the archive can be downloaded until it expires
+const (
+	ExportStatusPending    = "pending"
+	ExportStatusProcessing = "processing"
+	ExportStatusReady      = "ready"
+	ExportStatusFailed     = "failed"
+)
+
+type DataExport struct {
+	Id          int64      `json:"id"`
+	UserId      int64      `json:"user_id"`
+	Status      string     `json:"status"`
+	StorageKey  string     `json:"-"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Error       string     `json:"error,omitempty"`
+	DownloadUrl string     `json:"download_url,omitempty"` // Filled by the API layer, it is not stored in the database
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Everything stored about a user, read from a single snapshot of the database
+type UserData struct {
+	Profile   *User
+	Posts     []Post
+	Comments  []Comment
+	Followers []FollowConnection
+	Following []FollowConnection
+	Media     []Media
+}
+
+type ExportsStore struct {
+	db *sql.DB
+}
+
+const exportColumns = `
+	id, user_id, status, storage_key, size_bytes, error, expires_at, created_at, completed_at
+`
+
+// Scan object must follow the order of exportColumns
+func scanExport(row rowScanner, export *DataExport) error {
+	return row.Scan(
+		&export.Id,
+		&export.UserId,
+		&export.Status,
+		&export.StorageKey,
+		&export.SizeBytes,
+		&export.Error,
+		&export.ExpiresAt,
+		&export.CreatedAt,
+		&export.CompletedAt,
+	)
+}
+
+// Creates a pending export, it fails with ErrConflict while another export of the user is still being built
+func (s *ExportsStore) Create(ctx context.Context, export *DataExport) error {
+	query := `
+		INSERT INTO data_exports (user_id, status)
+		SELECT $1, $2
+		WHERE NOT EXISTS (
+			SELECT 1 FROM data_exports WHERE user_id = $1 AND status IN ($2, $3)
+		)
+		RETURNING ` + exportColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := scanExport(s.db.QueryRowContext(ctx, query, export.UserId, ExportStatusPending, ExportStatusProcessing), export)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ExportsStore) GetById(ctx context.Context, exportId int64) (*DataExport, error) {
+	query := `
+		SELECT ` + exportColumns + ` FROM data_exports WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	export := &DataExport{}
+	err := scanExport(s.db.QueryRowContext(ctx, query, exportId), export)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+// Returns the exports of a user, newest first
+func (s *ExportsStore) GetByUserId(ctx context.Context, userId int64) ([]DataExport, error) {
+	query := `
+		SELECT ` + exportColumns + ` FROM data_exports
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []DataExport{}
+	for rows.Next() {
+		var e DataExport
+		if err := scanExport(rows, &e); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// Marks a pending export as being built, it returns ErrNotFound if another worker claimed it already
+func (s *ExportsStore) ClaimPending(ctx context.Context, exportId int64) (*DataExport, error) {
+	query := `
+		UPDATE data_exports SET status = $1
+		WHERE id = $2 AND status = $3
+		RETURNING ` + exportColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	export := &DataExport{}
+	err := scanExport(s.db.QueryRowContext(ctx, query, ExportStatusProcessing, exportId, ExportStatusPending), export)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+func (s *ExportsStore) Complete(ctx context.Context, export *DataExport) error {
+	query := `
+		UPDATE data_exports
+		SET status = $1, storage_key = $2, size_bytes = $3, expires_at = $4, error = '', completed_at = NOW()
+		WHERE id = $5
+		RETURNING completed_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		ExportStatusReady,
+		export.StorageKey,
+		export.SizeBytes,
+		export.ExpiresAt,
+		export.Id,
+	).Scan(
+		&export.CompletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	export.Status = ExportStatusReady
+	return nil
+}
+
+func (s *ExportsStore) Fail(ctx context.Context, exportId int64, reason string) error {
+	query := `
+		UPDATE data_exports SET status = $1, error = $2, completed_at = NOW() WHERE id = $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, ExportStatusFailed, reason, exportId)
+	return err
+}
+
+// Puts exports interrupted by a restart back into the queue and returns everything waiting to be built
+func (s *ExportsStore) GetPendingIds(ctx context.Context, resetProcessing bool) ([]int64, error) {
+	resetQuery := `
+		UPDATE data_exports SET status = $1 WHERE status = $2
+	`
+	query := `
+		SELECT id FROM data_exports WHERE status = $1 ORDER BY id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	if resetProcessing {
+		if _, err := s.db.ExecContext(ctx, resetQuery, ExportStatusPending, ExportStatusProcessing); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, ExportStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Removes the expired archives from the database and returns their storage keys, so the blobs can be deleted
+func (s *ExportsStore) DeleteExpired(ctx context.Context, before time.Time) ([]string, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE status = $1 AND expires_at < $2
+		RETURNING storage_key
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, ExportStatusReady, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Collects everything stored about a user. All queries run in one read only transaction,
+// so the export is consistent even when the user keeps posting while it is built.
+func (s *ExportsStore) GetUserData(ctx context.Context, userId int64) (*UserData, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	data := &UserData{}
+	if data.Profile, err = exportProfile(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+	if data.Posts, err = exportPosts(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+	if data.Comments, err = exportComments(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+	if data.Followers, err = exportConnections(ctx, tx, followersQuery, userId); err != nil {
+		return nil, err
+	}
+	if data.Following, err = exportConnections(ctx, tx, followingQuery, userId); err != nil {
+		return nil, err
+	}
+	if data.Media, err = exportMedia(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+
+	return data, tx.Commit()
+}
+
+/* Helper Functions */
+func exportProfile(ctx context.Context, tx *sql.Tx, userId int64) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.display_name, u.bio, u.location, u.website, u.avatar_url,
+			u.is_private, u.version, u.created_at, u.updated_at, u.is_active, r.id, r.name, r.level, r.description
+		FROM users AS u
+		JOIN roles AS r ON u.role_id = r.id
+		WHERE u.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, userId).Scan(
+		&user.Id,
+		&user.Username,
+		&user.Email,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Location,
+		&user.Website,
+		&user.AvatarUrl,
+		&user.IsPrivate,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.IsActive,
+		&user.Role.Id,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	user.RoleId = user.Role.Id
+	return user, nil
+}
+
+func exportPosts(ctx context.Context, tx *sql.Tx, userId int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, tags, version, created_at, updated_at
+		FROM posts
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+func exportComments(ctx context.Context, tx *sql.Tx, userId int64) ([]Comment, error) {
+	query := `
+		SELECT id, post_id, user_id, content, created_at
+		FROM comments
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.Id, &c.PostId, &c.UserId, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+const followersQuery = `
+	SELECT u.id, u.username, f.created_at
+	FROM followers AS f
+	JOIN users AS u ON u.id = f.follower_id
+	WHERE f.user_id = $1
+	ORDER BY f.created_at, u.id
+`
+
+const followingQuery = `
+	SELECT u.id, u.username, f.created_at
+	FROM followers AS f
+	JOIN users AS u ON u.id = f.user_id
+	WHERE f.follower_id = $1
+	ORDER BY f.created_at, u.id
+`
+
+func exportConnections(ctx context.Context, tx *sql.Tx, query string, userId int64) ([]FollowConnection, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connections := []FollowConnection{}
+	for rows.Next() {
+		var c FollowConnection
+		if err := rows.Scan(&c.UserId, &c.Username, &c.Since); err != nil {
+			return nil, err
+		}
+		connections = append(connections, c)
+	}
+	return connections, rows.Err()
+}
+
+func exportMedia(ctx context.Context, tx *sql.Tx, userId int64) ([]Media, error) {
+	query := `
+		SELECT ` + mediaColumns + ` FROM media
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []Media{}
+	for rows.Next() {
+		var m Media
+		if err := scanMedia(rows, &m); err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
@@ -0,0 +1,505 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// How the ranked feed scores its posts
+type FeedWeights struct {
+	Recency        float64       // Weight of the age of the post
+	HalfLife       time.Duration // Age at which a post counts half as recent
+	Engagement     float64       // Weight of how many other users commented on the post
+	Relationship   float64       // Weight of how much the viewer interacts with the author
+	Seen           float64       // Subtracted from posts the feed already served to the viewer
+	RecommendEvery int           // Every how many slots a recommended post is mixed in, below 2 turns them off
+	RecommendSpan  time.Duration // Only posts this young are recommended
+}
+
+// Posts of the user $1 and of the users they follow, and the posts any of them reposted, except the
+// reposts of muted users. A post which comes up several times is one entry, at its latest appearance.
+var feedEntries = `feed_entries AS (
+	SELECT post_id, MAX(at) AS feed_at
+	FROM (
+		SELECT p.id AS post_id, p.published_at AS at FROM posts p
+		WHERE p.user_id = $1 OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+		UNION ALL
+		SELECT r.post_id, r.created_at FROM reposts r
+		WHERE r.user_id = $1 OR (
+			r.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+			AND NOT ` + mutedCondition("$1", "r.user_id") + `
+		)
+	) e
+	GROUP BY post_id
+)`
+
+// Which posts the feed of the user $1 shows, p being the post and u its author. Reposts bring posts
+// of users the user does not follow, so the privacy of the author and the visibility of the post are
+// checked as well. Drafts and scheduled posts never show, not even to their author.
+var feedVisible = `p.status = 'published' AND (
+	p.user_id = $1
+	OR (
+		p.held_at IS NULL
+		AND (
+			NOT u.is_private
+			OR EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $1)
+		)
+		AND ` + audienceCondition("p", "$1") + `
+		AND NOT ` + blockedCondition("p.user_id", "$1") + `
+		AND NOT ` + mutedCondition("$1", "p.user_id") + `
+		AND NOT ` + mutedWordCondition("$1", "p.title", "p.content", "array_to_string(p.tags, ' ')") + `
+	)
+)`
+
+// Rankings are cut at this many posts, and kept for a day after they were computed
+const (
+	feedSnapshotSize = 1000
+	FeedSnapshotTTL  = 24 * time.Hour
+)
+
+// Shows the same posts as the chronological feed, ordered by score, with posts of users the viewer
+// and their followees did not post or repost mixed in every few slots. A recommendation is a public post which other users
+// commented on, or which was trending when the snapshot was taken, and which passes the same filters as the feed.
+//
+// The first page of a snapshot ranks everything as of fq.AsOf and stores the ranking, later pages
+// are read from it, so the pages of one snapshot neither repeat nor skip posts however the feed
+// changes meanwhile. Posts which can no longer be shown, e.g. because they were deleted or their
+// author was blocked, are left out of their page, which comes back shorter.
+func (s *PostsStore) GetRankedFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery, weights FeedWeights) ([]PostWithMetaData, error) {
+	if err := s.createFeedSnapshot(ctx, userID, fq, weights); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.held_at IS NULL),
+			e.recommended
+		FROM feed_snapshots fs
+		CROSS JOIN unnest(fs.post_ids, fs.recommended) WITH ORDINALITY AS e (post_id, recommended, position)
+		JOIN posts p ON p.id = e.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE fs.user_id = $1 AND fs.as_of = $2 AND fs.search = $3
+			AND e.position > $4 AND e.position <= $4::bigint + $5::bigint
+			AND p.deleted_at IS NULL
+			AND ` + feedVisible + `
+		ORDER BY e.position
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.AsOf, fq.Search, fq.Offset, fq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []PostWithMetaData{}
+	for rows.Next() {
+		var p PostWithMetaData
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.PublishedAt,
+			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
+			pq.Array(&p.Tags),
+			&p.User.Username,
+			&p.CommentCount,
+			&p.Recommended,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feed = append(feed, p)
+	}
+	return feed, rows.Err()
+}
+
+// Ranks the feed of the user as of fq.AsOf and stores the ranking, unless it was stored already.
+// Snapshots of the user which expired are dropped meanwhile.
+func (s *PostsStore) createFeedSnapshot(ctx context.Context, userID int64, fq PaginatedFeedQuery, weights FeedWeights) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM feed_snapshots WHERE user_id = $1 AND as_of = $2 AND search = $3)
+	`, userID, fq.AsOf, fq.Search).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	candidates, err := s.getFeedCandidates(ctx, userID, fq, weights)
+	if err != nil {
+		return err
+	}
+	ranked := rankFeed(candidates, weights)
+	if len(ranked) > feedSnapshotSize {
+		ranked = ranked[:feedSnapshotSize]
+	}
+
+	postIds := make([]int64, len(ranked))
+	recommended := make([]bool, len(ranked))
+	for i, c := range ranked {
+		postIds[i] = c.id
+		recommended[i] = c.recommended
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM feed_snapshots
+			WHERE user_id = $1 AND created_at < NOW() - make_interval(secs => $2)
+		`, userID, FeedSnapshotTTL.Seconds())
+		if err != nil {
+			return err
+		}
+
+		// A concurrent request for the same snapshot may have stored it first, its ranking is as good
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO feed_snapshots (user_id, as_of, search, post_ids, recommended)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING
+		`, userID, fq.AsOf, fq.Search, pq.Array(postIds), pq.Array(recommended))
+		return err
+	})
+}
+
+// A post the ranked feed may show, with what it is scored on
+type feedCandidate struct {
+	id           int64
+	recommended  bool
+	own          bool    // Posted by the viewer
+	age          float64 // Seconds between the post and the snapshot
+	commenters   int     // Other users who commented on the post
+	interactions int     // Comments of the viewer on posts of the author
+	followsBack  bool    // Whether the author follows the viewer
+	seen         bool    // Whether the feed served the post to the viewer before the snapshot
+}
+
+// Returns the posts the ranked feed of the user may show as of fq.AsOf. Posts, comments, reposts
+// and impressions which came later are ignored.
+func (s *PostsStore) getFeedCandidates(ctx context.Context, userID int64, fq PaginatedFeedQuery, weights FeedWeights) ([]feedCandidate, error) {
+	visible := `
+		p.held_at IS NULL
+		AND NOT ` + blockedCondition("p.user_id", "$1") + `
+		AND NOT ` + mutedCondition("$1", "p.user_id") + `
+		AND NOT ` + mutedWordCondition("$1", "p.title", "p.content", "array_to_string(p.tags, ' ')") + `
+	`
+
+	query := `
+		WITH followed AS (
+			SELECT p.id, p.user_id, p.published_at, false AS recommended
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.deleted_at IS NULL AND p.published_at <= $2::timestamptz
+				AND (p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%')
+				AND (
+					p.user_id = $1
+					OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+					OR p.id IN (
+						SELECT r.post_id FROM reposts r
+						WHERE r.created_at <= $2::timestamptz
+							AND r.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+							AND NOT ` + mutedCondition("$1", "r.user_id") + `
+					)
+				)
+				AND ` + feedVisible + `
+		), recommended AS (
+			SELECT p.id, p.user_id, p.published_at, true AS recommended
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.deleted_at IS NULL AND p.status = 'published' AND p.visibility = 'public'
+				AND NOT u.is_private AND p.user_id <> $1
+				AND p.published_at <= $2::timestamptz AND $4::float8 > 0
+				AND p.user_id NOT IN (SELECT user_id FROM followers WHERE follower_id = $1)
+				AND p.id NOT IN (SELECT id FROM followed)
+				AND (p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%')
+				AND (
+					(
+						p.published_at > $2::timestamptz - make_interval(secs => $4)
+						AND EXISTS (
+							SELECT 1 FROM comments c
+							WHERE c.post_id = p.id AND c.user_id <> p.user_id AND c.created_at <= $2::timestamptz
+								AND c.deleted_at IS NULL AND c.held_at IS NULL
+						)
+					)
+					-- Trending posts are recommended however old they are
+					OR p.id IN (SELECT post_id FROM trending_posts WHERE computed_at <= $2::timestamptz)
+				)
+				AND ` + visible + `
+		)
+		SELECT cp.id, cp.recommended, cp.user_id = $1,
+			extract(epoch FROM $2::timestamptz - cp.published_at),
+			(
+				SELECT COUNT(DISTINCT c.user_id) FROM comments c
+				WHERE c.post_id = cp.id AND c.user_id <> cp.user_id AND c.created_at <= $2::timestamptz
+					AND c.deleted_at IS NULL AND c.held_at IS NULL
+			),
+			(
+				SELECT COUNT(*) FROM comments c
+				JOIN posts ap ON ap.id = c.post_id
+				WHERE c.user_id = $1 AND ap.user_id = cp.user_id AND c.created_at <= $2::timestamptz
+					AND c.deleted_at IS NULL
+			),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = cp.user_id),
+			EXISTS (
+				SELECT 1 FROM feed_impressions fi
+				WHERE fi.user_id = $1 AND fi.post_id = cp.id AND fi.seen_at < $2::timestamptz
+			)
+		FROM (SELECT * FROM followed UNION ALL SELECT * FROM recommended) cp
+	`
+
+	// Without recommendations the span is empty, so no post is recommended, trending ones included
+	recommendSpan := weights.RecommendSpan.Seconds()
+	if weights.RecommendEvery < 2 {
+		recommendSpan = 0
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.AsOf, fq.Search, recommendSpan)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []feedCandidate{}
+	for rows.Next() {
+		var c feedCandidate
+		err := rows.Scan(&c.id, &c.recommended, &c.own, &c.age, &c.commenters, &c.interactions, &c.followsBack, &c.seen)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// Scores a post of the ranked feed. Recency halves every HalfLife, engagement and the relationship
+// with the author grow logarithmically, and posts the feed served before sink.
+func (w FeedWeights) score(c feedCandidate) float64 {
+	score := w.Engagement * math.Log1p(float64(c.commenters))
+	if w.HalfLife > 0 {
+		score += w.Recency * math.Exp(-math.Ln2*c.age/w.HalfLife.Seconds())
+	}
+	if !c.own {
+		relationship := math.Log1p(float64(c.interactions))
+		if c.followsBack {
+			relationship++
+		}
+		score += w.Relationship * relationship
+	}
+	if c.seen {
+		score -= w.Seen
+	}
+	return score
+}
+
+// Orders the candidates by score, ties newest first. Recommendations take every RecommendEvery-th
+// slot and the followed posts fill the slots in between, whatever is left of either comes last.
+func rankFeed(candidates []feedCandidate, weights FeedWeights) []feedCandidate {
+	scores := make(map[int64]float64, len(candidates))
+	var followed, recommended []feedCandidate
+	for _, c := range candidates {
+		scores[c.id] = weights.score(c)
+		if c.recommended {
+			recommended = append(recommended, c)
+		} else {
+			followed = append(followed, c)
+		}
+	}
+	byScore := func(list []feedCandidate) {
+		sort.Slice(list, func(i, j int) bool {
+			if scores[list[i].id] != scores[list[j].id] {
+				return scores[list[i].id] > scores[list[j].id]
+			}
+			return list[i].id > list[j].id
+		})
+	}
+	byScore(followed)
+	byScore(recommended)
+
+	ranked := make([]feedCandidate, 0, len(candidates))
+	for slot := 1; len(followed) > 0 || len(recommended) > 0; slot++ {
+		recommendSlot := weights.RecommendEvery >= 2 && slot%weights.RecommendEvery == 0
+		if len(recommended) > 0 && (recommendSlot || len(followed) == 0) {
+			ranked = append(ranked, recommended[0])
+			recommended = recommended[1:]
+			continue
+		}
+		ranked = append(ranked, followed[0])
+		followed = followed[1:]
+	}
+	return ranked
+}
+
+// Records that the ranked feed served the posts to the user, posts served before keep their first time
+func (s *PostsStore) MarkSeen(ctx context.Context, userID int64, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO feed_impressions (user_id, post_id)
+		SELECT $1, unnest($2::bigint[])
+		ON CONFLICT DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, pq.Array(postIds))
+	return err
+}
+
+// A post on the timeline of a user, timelines are kept newest first
+type TimelineEntry struct {
+	PostId    int64
+	AuthorId  int64     // Whoever brought the post to the timeline, the author or a user who reposted it
+	CreatedAt time.Time // When the post was published or reposted
+}
+
+// Returns the newest entries of the feed of the user, which is what their timeline is rebuilt
+// from. Posts held for review only count for their author, posts of muted users do not count.
+func (s *PostsStore) GetTimelineEntries(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		WITH ` + feedEntries + `
+		SELECT p.id, p.user_id, fe.feed_at
+		FROM feed_entries fe
+		JOIN posts p ON p.id = fe.post_id
+		WHERE p.deleted_at IS NULL AND p.status = 'published' AND (p.user_id = $1 OR p.held_at IS NULL)
+			AND ` + audienceCondition("p", "$1") + `
+			AND NOT ` + mutedCondition("$1", "p.user_id") + `
+		ORDER BY fe.feed_at DESC, p.id DESC
+		LIMIT $2
+	`
+	return s.queryTimelineEntries(ctx, query, userID, limit)
+}
+
+// Returns the newest posts and reposts of those authors which the user follows and did not mute,
+// for authors whose posts are not pushed to the timelines of their followers
+func (s *PostsStore) GetTimelineEntriesOf(ctx context.Context, userID int64, authorIds []int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		WITH authors AS (
+			SELECT user_id FROM followers
+			WHERE follower_id = $1 AND user_id = ANY($3) AND NOT ` + mutedCondition("$1", "user_id") + `
+		)
+		SELECT p.id, p.user_id, e.feed_at
+		FROM (
+			SELECT post_id, MAX(at) AS feed_at
+			FROM (
+				SELECT id AS post_id, published_at AS at FROM posts WHERE user_id IN (SELECT user_id FROM authors)
+				UNION ALL
+				SELECT post_id, created_at FROM reposts WHERE user_id IN (SELECT user_id FROM authors)
+			) a
+			GROUP BY post_id
+		) e
+		JOIN posts p ON p.id = e.post_id
+		WHERE p.deleted_at IS NULL AND p.held_at IS NULL AND p.status = 'published'
+			AND ` + audienceCondition("p", "$1") + `
+			AND NOT ` + mutedCondition("$1", "p.user_id") + `
+		ORDER BY e.feed_at DESC, p.id DESC
+		LIMIT $2
+	`
+	return s.queryTimelineEntries(ctx, query, userID, limit, pq.Array(authorIds))
+}
+
+// Shows the posts out of postIds which the chronological feed would show the user, in its order.
+// The ids come from a timeline which may be outdated, so every filter of the feed is applied again
+// and a post whose author was unfollowed and whose reposts were undone drops out.
+func (s *PostsStore) GetFeedByIds(ctx context.Context, userID int64, postIds []int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+		SELECT id, user_id, title, content, content_html, created_at, published_at, version, edited_at, held_at, visibility, tags, username, comment_count
+		FROM (
+			SELECT
+				p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
+				u.username,
+				(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.held_at IS NULL) AS comment_count,
+				GREATEST(
+					CASE WHEN p.user_id = $1 OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1) THEN p.published_at END,
+					(
+						SELECT MAX(r.created_at) FROM reposts r
+						WHERE r.post_id = p.id AND (
+							r.user_id = $1 OR (
+								r.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+								AND NOT ` + mutedCondition("$1", "r.user_id") + `
+							)
+						)
+					)
+				) AS feed_at
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.id = ANY($2) AND p.deleted_at IS NULL AND ` + feedVisible + `
+		) f
+		WHERE feed_at IS NOT NULL
+		ORDER BY feed_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(postIds), fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []PostWithMetaData{}
+	for rows.Next() {
+		var p PostWithMetaData
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.PublishedAt,
+			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
+			pq.Array(&p.Tags),
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feed = append(feed, p)
+	}
+	return feed, rows.Err()
+}
+
+/* Helper Functions */
+
+func (s *PostsStore) queryTimelineEntries(ctx context.Context, query string, args ...any) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var e TimelineEntry
+		if err := rows.Scan(&e.PostId, &e.AuthorId, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
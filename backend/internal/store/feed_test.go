@@ -0,0 +1,114 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFeedScore(t *testing.T) {
+	weights := FeedWeights{Recency: 8, HalfLife: time.Hour, Engagement: 2, Relationship: 1, Seen: 3}
+
+	tests := []struct {
+		name      string
+		candidate feedCandidate
+		want      float64
+	}{
+		{"new post", feedCandidate{}, 8},
+		{"post one half life old", feedCandidate{age: 3600}, 4},
+		{"post two half lives old", feedCandidate{age: 7200}, 2},
+		{"commented post", feedCandidate{age: 3600, commenters: 3}, 4 + 2*math.Log1p(3)},
+		{"author the viewer comments on", feedCandidate{age: 3600, interactions: 4}, 4 + math.Log1p(4)},
+		{"author who follows the viewer", feedCandidate{age: 3600, followsBack: true}, 4 + 1},
+		{"own post", feedCandidate{age: 3600, interactions: 4, followsBack: true, own: true}, 4},
+		{"post served before", feedCandidate{age: 3600, seen: true}, 4 - 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weights.score(tt.candidate); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("score = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("should ignore recency without a half life", func(t *testing.T) {
+		if got := (FeedWeights{Recency: 8}).score(feedCandidate{}); got != 0 {
+			t.Errorf("score = %v, want 0", got)
+		}
+	})
+}
+
+func TestRankFeed(t *testing.T) {
+	// With only the recency weighted, the youngest post ranks first
+	weights := FeedWeights{Recency: 1, HalfLife: time.Hour, RecommendEvery: 3}
+	followed := func(id int64, age float64) feedCandidate {
+		return feedCandidate{id: id, age: age}
+	}
+	recommended := func(id int64, age float64) feedCandidate {
+		return feedCandidate{id: id, age: age, recommended: true}
+	}
+
+	tests := []struct {
+		name       string
+		candidates []feedCandidate
+		every      int
+		want       []int64
+	}{
+		{
+			name:       "should order by score",
+			candidates: []feedCandidate{followed(1, 300), followed(2, 100), followed(3, 200)},
+			every:      3,
+			want:       []int64{2, 3, 1},
+		},
+		{
+			name:       "should break ties newest first",
+			candidates: []feedCandidate{followed(1, 100), followed(3, 100), followed(2, 100)},
+			every:      3,
+			want:       []int64{3, 2, 1},
+		},
+		{
+			name: "should put recommendations in every third slot",
+			candidates: []feedCandidate{
+				followed(1, 100), followed(2, 200), followed(3, 300), followed(4, 400), followed(5, 500),
+				recommended(10, 600), recommended(11, 50),
+			},
+			every: 3,
+			want:  []int64{1, 2, 11, 3, 4, 10, 5},
+		},
+		{
+			name:       "should append the recommendations left when the followed posts run out",
+			candidates: []feedCandidate{followed(1, 100), recommended(10, 100), recommended(11, 200)},
+			every:      3,
+			want:       []int64{1, 10, 11},
+		},
+		{
+			name:       "should fill the slots with followed posts when recommendations run out",
+			candidates: []feedCandidate{followed(1, 100), followed(2, 200), followed(3, 300), followed(4, 400)},
+			every:      2,
+			want:       []int64{1, 2, 3, 4},
+		},
+		{
+			name:       "should not interleave when recommendations are off",
+			candidates: []feedCandidate{followed(1, 100), recommended(10, 50), followed(2, 200)},
+			every:      1,
+			want:       []int64{1, 2, 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weights.RecommendEvery = tt.every
+			ranked := rankFeed(tt.candidates, weights)
+
+			if len(ranked) != len(tt.want) {
+				t.Fatalf("got %d posts, want %d", len(ranked), len(tt.want))
+			}
+			for i, c := range ranked {
+				if c.id != tt.want[i] {
+					t.Errorf("slot %d holds post %d, want %d", i+1, c.id, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/lib/pq"
 )
 
+var ErrSelfFollow = errors.New("users can not follow themselves")
+
 type FollowedUserDetails struct {
 	UserId    int64
 	Email     string
@@ -14,61 +17,194 @@ type FollowedUserDetails struct {
 	CreatedAt string
 }
 
+// A relationship between two users seen from one side, UserId is the other user. It is used for
+// follows, follow requests, blocks and mutes.
+type FollowConnection struct {
+	UserId      int64  `json:"user_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarUrl   string `json:"avatar_url,omitempty"`
+	Since       string `json:"since"`
+}
+
+type FollowCounts struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// How the user relates to another user
+type Relationship struct {
+	UserId     int64 `json:"user_id"`     // The other user
+	Following  bool  `json:"following"`   // The user follows the other user
+	FollowedBy bool  `json:"followed_by"` // The other user follows the user
+	Requested  bool  `json:"requested"`   // The user asked to follow the other user, who has not answered yet
+	Blocking   bool  `json:"blocking"`    // The user blocked the other user
+	BlockedBy  bool  `json:"blocked_by"`  // The other user blocked the user
+	Muting     bool  `json:"muting"`      // The user muted the other user
+}
+
 type FollowersStore struct {
 	db *sql.DB
 }
 
-func (s *FollowersStore) Follow(ctx context.Context, followerId int64, userId int64) error {
-	query := `
-		INSERT INTO followers (user_id, follower_id)
-		VALUES ($1, $2)
-	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
-	defer cancel()
+// Follows the user, or asks to when the account is private. It reports whether a follow request
+// was created instead of a follow.
+func (s *FollowersStore) Follow(ctx context.Context, followerId int64, userId int64) (bool, error) {
+	if followerId == userId {
+		return false, ErrSelfFollow
+	}
 
-	_, err := s.db.ExecContext(
-		ctx,
-		query,
-		userId,
-		followerId,
-	)
+	requested := false
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
 
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		// Locks the user, so a request can not slip in while the account turns public
+		var isPrivate, following, blocked bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT is_private, EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2),
+				`+blockedCondition("$1", "$2")+`
+			FROM users WHERE id = $1
+			FOR SHARE
+		`, userId, followerId).Scan(&isPrivate, &following, &blocked)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if blocked {
+			return ErrBlocked
+		}
+		if following {
 			return ErrConflict
 		}
-	}
-	return nil
+
+		query := `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)`
+		if isPrivate {
+			query = `INSERT INTO follow_requests (user_id, requester_id) VALUES ($1, $2)`
+			requested = true
+		}
+		if _, err := tx.ExecContext(ctx, query, userId, followerId); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+				return ErrConflict
+			}
+			return err
+		}
+		return nil
+	})
+	return requested, err
 }
 
+// Unfollows the user, a pending follow request is withdrawn as well
 func (s *FollowersStore) Unfollow(ctx context.Context, followerId int64, userId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM followers
+			WHERE user_id = $1 AND follower_id = $2
+		`, userId, followerId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM follow_requests
+			WHERE user_id = $1 AND requester_id = $2
+		`, userId, followerId)
+		return err
+	})
+}
+
+// Returns the pending follow requests of the user, the requester is in UserId
+func (s *FollowersStore) GetRequests(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, fr.created_at
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1
+		ORDER BY fr.created_at ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+// Turns the follow request of the requester into a follow
+func (s *FollowersStore) ApproveRequest(ctx context.Context, userId int64, requesterId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM follow_requests
+			WHERE user_id = $1 AND requester_id = $2
+		`, userId, requesterId)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO followers (user_id, follower_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, userId, requesterId)
+		return err
+	})
+}
+
+func (s *FollowersStore) RejectRequest(ctx context.Context, userId int64, requesterId int64) error {
 	query := `
-		DELETE FROM followers 
-		WHERE user_id = $1 AND follower_id = $2
+		DELETE FROM follow_requests
+		WHERE user_id = $1 AND requester_id = $2
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(
-		ctx,
-		query,
-		userId,
-		followerId,
-	)
-
+	res, err := s.db.ExecContext(ctx, query, userId, requesterId)
 	if err != nil {
 		return err
 	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
+// Approves every pending request, used when a private account turns public
+func (s *FollowersStore) ApproveAllRequests(ctx context.Context, userId int64) error {
+	query := `
+		WITH approved AS (
+			DELETE FROM follow_requests WHERE user_id = $1
+			RETURNING user_id, requester_id
+		)
+		INSERT INTO followers (user_id, follower_id)
+		SELECT user_id, requester_id FROM approved
+		ON CONFLICT DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId)
+	return err
+}
+
 // Returns all the users that are followed by the user with provided id
 func (s *FollowersStore) GetFollowedUsersById(ctx context.Context, userId int64) ([]FollowedUserDetails, error) {
 	query := `
-		SELECT id, email, username, created_at 
-		FROM users 
+		SELECT id, email, username, created_at
+		FROM users
 		WHERE id IN
-			(SELECT user_id FROM followers 
+			(SELECT user_id FROM followers
 			WHERE follower_id = $1)
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
@@ -86,8 +222,8 @@ func (s *FollowersStore) GetFollowedUsersById(ctx context.Context, userId int64)
 		var fu FollowedUserDetails
 		err := rows.Scan(
 			&fu.UserId,
-			&fu.Username,
 			&fu.Email,
+			&fu.Username,
 			&fu.CreatedAt,
 		)
 		if err != nil {
@@ -96,8 +232,144 @@ func (s *FollowersStore) GetFollowedUsersById(ctx context.Context, userId int64)
 		followedUsers = append(followedUsers, fu)
 	}
 
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return followedUsers, nil
 }
+
+// Returns the users following the user, ordered by when they followed
+func (s *FollowersStore) GetFollowers(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+// Returns the users the user follows, ordered by when they were followed
+func (s *FollowersStore) GetFollowing(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+// Returns the users who follow the user and are followed back. Since is when the later of
+// the two follows happened, which is when they became mutual.
+func (s *FollowersStore) GetMutuals(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, GREATEST(f1.created_at, f2.created_at) AS since
+		FROM followers f1
+		JOIN followers f2 ON f2.user_id = f1.follower_id AND f2.follower_id = f1.user_id
+		JOIN users u ON u.id = f1.follower_id
+		WHERE f1.user_id = $1
+		ORDER BY since ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+func (s *FollowersStore) GetCounts(ctx context.Context, userId int64) (*FollowCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+			(SELECT COUNT(*) FROM followers WHERE follower_id = $1)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	counts := &FollowCounts{}
+	if err := s.db.QueryRowContext(ctx, query, userId).Scan(&counts.Followers, &counts.Following); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Returns the ids of the followers of the user which the posts of the user reach, followers who muted
+// the user are left out
+func (s *FollowersStore) GetFollowerIds(ctx context.Context, userId int64) ([]int64, error) {
+	query := `
+		SELECT follower_id FROM followers
+		WHERE user_id = $1 AND NOT ` + mutedCondition("follower_id", "$1") + `
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Returns how the user relates to the other user, in both directions
+func (s *FollowersStore) GetRelationship(ctx context.Context, userId int64, otherId int64) (*Relationship, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2),
+			EXISTS (SELECT 1 FROM follow_requests WHERE user_id = $2 AND requester_id = $1),
+			EXISTS (SELECT 1 FROM user_blocks WHERE user_id = $1 AND blocked_id = $2),
+			EXISTS (SELECT 1 FROM user_blocks WHERE user_id = $2 AND blocked_id = $1),
+			` + mutedCondition("$1", "$2") + `
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	relationship := &Relationship{UserId: otherId}
+	err := s.db.QueryRowContext(ctx, query, userId, otherId).Scan(
+		&relationship.Following,
+		&relationship.FollowedBy,
+		&relationship.Requested,
+		&relationship.Blocking,
+		&relationship.BlockedBy,
+		&relationship.Muting,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return relationship, nil
+}
+
+/* Helper Functions */
+
+func queryConnections(ctx context.Context, db *sql.DB, query string, args ...any) ([]FollowConnection, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connections := []FollowConnection{}
+	for rows.Next() {
+		var c FollowConnection
+		if err := rows.Scan(&c.UserId, &c.Username, &c.DisplayName, &c.AvatarUrl, &c.Since); err != nil {
+			return nil, err
+		}
+		connections = append(connections, c)
+	}
+	return connections, rows.Err()
+}
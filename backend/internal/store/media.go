@@ -0,0 +1,498 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Images go through the processing pipeline before they can be served, other media is ready right away
+const (
+	MediaStatusPending    = "pending"
+	MediaStatusProcessing = "processing"
+	MediaStatusReady      = "ready"
+	MediaStatusFailed     = "failed"
+)
+
+type Media struct {
+	Id              int64          `json:"id"`
+	UserId          int64          `json:"user_id"`
+	PostId          *int64         `json:"post_id"`
+	Position        int            `json:"position"`
+	AltText         string         `json:"alt_text"`
+	Filename        string         `json:"filename"`
+	ContentType     string         `json:"content_type"`
+	SizeBytes       int64          `json:"size_bytes"`
+	StorageKey      string         `json:"-"`
+	ContentHash     string         `json:"-"`
+	Status          string         `json:"status"`
+	ProcessingError string         `json:"processing_error,omitempty"`
+	Width           int            `json:"width"`
+	Height          int            `json:"height"`
+	Url             string         `json:"url"` // Filled by the API layer, it is not stored in the database
+	Variants        []MediaVariant `json:"variants"`
+	CreatedAt       string         `json:"created_at"`
+}
+
+type MediaVariant struct {
+	MediaId     int64  `json:"-"`
+	Name        string `json:"name"`
+	StorageKey  string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	SizeBytes   int64  `json:"size_bytes"`
+	Url         string `json:"url"` // Filled by the API layer, it is not stored in the database
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+const mediaColumns = `
+	id, user_id, post_id, position, alt_text, filename, content_type, size_bytes, storage_key,
+	content_hash, status, processing_error, width, height, created_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Scan object must follow the order of mediaColumns
+func scanMedia(row rowScanner, media *Media) error {
+	return row.Scan(
+		&media.Id,
+		&media.UserId,
+		&media.PostId,
+		&media.Position,
+		&media.AltText,
+		&media.Filename,
+		&media.ContentType,
+		&media.SizeBytes,
+		&media.StorageKey,
+		&media.ContentHash,
+		&media.Status,
+		&media.ProcessingError,
+		&media.Width,
+		&media.Height,
+		&media.CreatedAt,
+	)
+}
+
+func (s *MediaStore) Create(ctx context.Context, media *Media) error {
+	query := `
+		INSERT INTO media (user_id, alt_text, filename, content_type, size_bytes, storage_key, content_hash, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		media.UserId,
+		media.AltText,
+		media.Filename,
+		media.ContentType,
+		media.SizeBytes,
+		media.StorageKey,
+		media.ContentHash,
+		media.Status,
+	).Scan(
+		&media.Id,
+		&media.CreatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Creates a media record which reuses the blobs and processed variants of an identical earlier upload
+func (s *MediaStore) CreateFromDuplicate(ctx context.Context, media *Media, source *Media) error {
+	query := `
+		INSERT INTO media (user_id, alt_text, filename, content_type, size_bytes, storage_key, content_hash, status, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at
+	`
+	variantsQuery := `
+		INSERT INTO media_variants (media_id, name, storage_key, content_type, width, height, size_bytes)
+		SELECT $1, name, storage_key, content_type, width, height, size_bytes
+		FROM media_variants WHERE media_id = $2
+	`
+
+	media.StorageKey = source.StorageKey
+	media.ContentType = source.ContentType
+	media.SizeBytes = source.SizeBytes
+	media.ContentHash = source.ContentHash
+	media.Status = MediaStatusReady
+	media.Width = source.Width
+	media.Height = source.Height
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			media.UserId,
+			media.AltText,
+			media.Filename,
+			media.ContentType,
+			media.SizeBytes,
+			media.StorageKey,
+			media.ContentHash,
+			media.Status,
+			media.Width,
+			media.Height,
+		).Scan(
+			&media.Id,
+			&media.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, variantsQuery, media.Id, source.Id); err != nil {
+			return err
+		}
+
+		media.Variants = make([]MediaVariant, len(source.Variants))
+		for i, v := range source.Variants {
+			v.MediaId = media.Id
+			media.Variants[i] = v
+		}
+		return nil
+	})
+}
+
+func (s *MediaStore) GetById(ctx context.Context, mediaId int64) (*Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	media := &Media{}
+	if err := scanMedia(s.db.QueryRowContext(ctx, query, mediaId), media); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := s.loadVariants(ctx, []*Media{media}); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// Returns the most recent processed upload with the same content, used to skip duplicate processing
+func (s *MediaStore) GetReadyByContentHash(ctx context.Context, hash string) (*Media, error) {
+	query := `
+		SELECT ` + mediaColumns + ` FROM media
+		WHERE content_hash = $1 AND status = $2
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	media := &Media{}
+	if err := scanMedia(s.db.QueryRowContext(ctx, query, hash, MediaStatusReady), media); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := s.loadVariants(ctx, []*Media{media}); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// Counts the media records sharing the blobs of a content hash
+func (s *MediaStore) CountByContentHash(ctx context.Context, hash string) (int, error) {
+	query := `SELECT COUNT(*) FROM media WHERE content_hash = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, hash).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Returns the attachments of every provided post, grouped by post id and ordered by their position
+func (s *MediaStore) GetByPostIds(ctx context.Context, postIds []int64) (map[int64][]Media, error) {
+	query := `
+		SELECT ` + mediaColumns + ` FROM media
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, position
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	attachments := make(map[int64][]Media)
+	if len(postIds) == 0 {
+		return attachments, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []*Media
+	for rows.Next() {
+		m := &Media{}
+		if err := scanMedia(rows, m); err != nil {
+			return nil, err
+		}
+		all = append(all, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadVariants(ctx, all); err != nil {
+		return nil, err
+	}
+
+	for _, m := range all {
+		attachments[*m.PostId] = append(attachments[*m.PostId], *m)
+	}
+	return attachments, nil
+}
+
+func (s *MediaStore) loadVariants(ctx context.Context, medias []*Media) error {
+	query := `
+		SELECT media_id, name, storage_key, content_type, width, height, size_bytes
+		FROM media_variants
+		WHERE media_id = ANY($1)
+		ORDER BY media_id, width
+	`
+	if len(medias) == 0 {
+		return nil
+	}
+
+	byId := make(map[int64]*Media, len(medias))
+	ids := make([]int64, len(medias))
+	for i, m := range medias {
+		byId[m.Id] = m
+		ids[i] = m.Id
+		m.Variants = []MediaVariant{}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v MediaVariant
+		err := rows.Scan(
+			&v.MediaId,
+			&v.Name,
+			&v.StorageKey,
+			&v.ContentType,
+			&v.Width,
+			&v.Height,
+			&v.SizeBytes,
+		)
+		if err != nil {
+			return err
+		}
+		byId[v.MediaId].Variants = append(byId[v.MediaId].Variants, v)
+	}
+	return rows.Err()
+}
+
+// Marks a pending media as being processed, ErrNotFound is returned if another worker already took it
+func (s *MediaStore) ClaimPending(ctx context.Context, mediaId int64) (*Media, error) {
+	query := `
+		UPDATE media SET status = $1
+		WHERE id = $2 AND status = $3
+		RETURNING ` + mediaColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	media := &Media{}
+	err := scanMedia(s.db.QueryRowContext(ctx, query, MediaStatusProcessing, mediaId, MediaStatusPending), media)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return media, nil
+}
+
+// Stores the generated variants and marks the media as ready in a single transaction
+func (s *MediaStore) CompleteProcessing(ctx context.Context, media *Media) error {
+	variantQuery := `
+		INSERT INTO media_variants (media_id, name, storage_key, content_type, width, height, size_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (media_id, name) DO UPDATE
+		SET storage_key = EXCLUDED.storage_key, content_type = EXCLUDED.content_type,
+			width = EXCLUDED.width, height = EXCLUDED.height, size_bytes = EXCLUDED.size_bytes
+	`
+	mediaQuery := `
+		UPDATE media SET status = $1, processing_error = '', width = $2, height = $3
+		WHERE id = $4
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		for _, v := range media.Variants {
+			_, err := tx.ExecContext(ctx, variantQuery, media.Id, v.Name, v.StorageKey, v.ContentType, v.Width, v.Height, v.SizeBytes)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, mediaQuery, MediaStatusReady, media.Width, media.Height, media.Id)
+		if err != nil {
+			return err
+		}
+
+		media.Status = MediaStatusReady
+		return nil
+	})
+}
+
+func (s *MediaStore) FailProcessing(ctx context.Context, mediaId int64, reason string) error {
+	query := `
+		UPDATE media SET status = $1, processing_error = $2 WHERE id = $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, MediaStatusFailed, reason, mediaId)
+	return err
+}
+
+// Puts media interrupted by a restart back into the queue and returns everything waiting to be processed
+func (s *MediaStore) GetPendingIds(ctx context.Context, resetProcessing bool) ([]int64, error) {
+	resetQuery := `
+		UPDATE media SET status = $1 WHERE status = $2
+	`
+	query := `
+		SELECT id FROM media WHERE status = $1 ORDER BY id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	if resetProcessing {
+		if _, err := s.db.ExecContext(ctx, resetQuery, MediaStatusPending, MediaStatusProcessing); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, MediaStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Links the uploaded media to a post, the order of mediaIds becomes the display order.
+// Only media owned by the user which is not attached to another post can be linked.
+func (s *MediaStore) AttachToPost(ctx context.Context, postId int64, userId int64, mediaIds []int64) error {
+	query := `
+		UPDATE media
+		SET post_id = $1, position = $2
+		WHERE id = $3 AND user_id = $4 AND post_id IS NULL AND status <> $5
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		for position, mediaId := range mediaIds {
+			res, err := tx.ExecContext(ctx, query, postId, position, mediaId, userId, MediaStatusFailed)
+			if err != nil {
+				return err
+			}
+
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rows == 0 {
+				return ErrNotFound
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MediaStore) UpdateAltText(ctx context.Context, media *Media) error {
+	query := `
+		UPDATE media SET alt_text = $1 WHERE id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, media.AltText, media.Id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MediaStore) Delete(ctx context.Context, mediaId int64) error {
+	query := `
+		DELETE FROM media WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, mediaId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// A post or comment mentioning a user
+type Mention struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id"` // The mentioned user
+	AuthorId  int64  `json:"author_id"`
+	Author    string `json:"author"` // Username of the author
+	PostId    int64  `json:"post_id"`
+	CommentId *int64 `json:"comment_id"` // Nil for mentions in the post itself
+	Title     string `json:"title"`      // Title of the post
+	Content   string `json:"content"`    // Content of the post or the comment
+	CreatedAt string `json:"created_at"`
+}
+
+type MentionsStore struct {
+	db *sql.DB
+}
+
+// Makes the mentions of a post, or of one of its comments, match the usernames. Unknown usernames,
+// the author, users blocked either way and users who can not see the post are skipped. The ids of
+// the users who were not mentioned before are returned, so only they are notified after an edit.
+func (s *MentionsStore) Sync(ctx context.Context, authorId int64, postId int64, commentId *int64, usernames []string) ([]int64, error) {
+	var mentioned []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM mentions m
+			USING users u
+			WHERE u.id = m.user_id AND m.post_id = $1 AND m.comment_id IS NOT DISTINCT FROM $2
+				AND NOT lower(u.username) = ANY($3)
+		`, postId, commentId, pq.Array(usernames))
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			INSERT INTO mentions (user_id, author_id, post_id, comment_id)
+			SELECT u.id, $1, p.id, $3
+			FROM users u
+			JOIN posts p ON p.id = $2
+			JOIN users pa ON pa.id = p.user_id
+			WHERE lower(u.username) = ANY($4) AND u.id <> $1
+				AND NOT `+blockedCondition("u.id", "$1")+`
+				AND (
+					NOT pa.is_private
+					OR u.id = pa.id
+					OR EXISTS (SELECT 1 FROM followers WHERE user_id = pa.id AND follower_id = u.id)
+				)
+				AND `+audienceCondition("p", "u.id")+`
+			ON CONFLICT DO NOTHING
+			RETURNING user_id
+		`, authorId, postId, commentId, pq.Array(usernames))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userId int64
+			if err := rows.Scan(&userId); err != nil {
+				return err
+			}
+			mentioned = append(mentioned, userId)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mentioned, nil
+}
+
+// Returns the posts and comments mentioning the user as the viewer sees them, held and deleted
+// content and content the viewer is not allowed to see are left out
+func (s *MentionsStore) GetByUserId(ctx context.Context, userId int64, viewerId int64, fq PaginatedFeedQuery) ([]Mention, error) {
+	query := `
+		SELECT m.id, m.user_id, m.author_id, a.username, m.post_id, m.comment_id, p.title,
+			COALESCE(c.content, p.content), m.created_at
+		FROM mentions m
+		JOIN users a ON a.id = m.author_id
+		JOIN posts p ON p.id = m.post_id
+		JOIN users pa ON pa.id = p.user_id
+		LEFT JOIN comments c ON c.id = m.comment_id
+		WHERE m.user_id = $1
+			AND p.deleted_at IS NULL AND p.status = 'published'
+			AND (m.comment_id IS NULL OR c.deleted_at IS NULL)
+			AND (p.held_at IS NULL OR p.user_id = $2)
+			AND (c.held_at IS NULL OR c.user_id = $2)
+			AND (
+				NOT pa.is_private
+				OR pa.id = $2
+				OR EXISTS (SELECT 1 FROM followers WHERE user_id = pa.id AND follower_id = $2)
+			)
+			AND ` + audienceCondition("p", "$2") + `
+			AND NOT ` + blockedCondition("m.author_id", "$2") + `
+			AND NOT ` + blockedCondition("pa.id", "$2") + `
+		ORDER BY m.created_at ` + fq.Sort + `, m.id ` + fq.Sort + `
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, viewerId, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var m Mention
+		err := rows.Scan(
+			&m.Id,
+			&m.UserId,
+			&m.AuthorId,
+			&m.Author,
+			&m.PostId,
+			&m.CommentId,
+			&m.Title,
+			&m.Content,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
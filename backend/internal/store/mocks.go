@@ -8,10 +8,243 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Posts:        &MockPostsStore{},
+		Reposts:      &MockRepostsStore{},
+		Polls:        &MockPollsStore{},
+		Bookmarks:    &MockBookmarksStore{},
+		Mentions:     &MockMentionsStore{},
+		Users:        &MockUserStore{},
+		Comments:     &MockCommentsStore{},
+		Followers:    &MockFollowersStore{},
+		Blocks:       &MockBlocksStore{},
+		CloseFriends: &MockCloseFriendsStore{},
+		Moderation:   &MockModerationStore{},
+		Media:        &MockMediaStore{},
+		Roles:        &MockRolesStore{},
 	}
 }
 
+type MockPostsStore struct{}
+
+func (m *MockPostsStore) Create(ctx context.Context, post *Post) error {
+	post.Id = 1
+	return nil
+}
+
+func (m *MockPostsStore) GetById(ctx context.Context, postId int64) (*Post, error) {
+	return &Post{Id: postId, Status: PostPublished, Visibility: PostPublic}, nil
+}
+
+func (m *MockPostsStore) Delete(ctx context.Context, postId int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) Update(ctx context.Context, post *Post, editorId int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+func (m *MockPostsStore) GetRankedFeed(context.Context, int64, PaginatedFeedQuery, FeedWeights) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+func (m *MockPostsStore) MarkSeen(ctx context.Context, userId int64, postIds []int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) GetTimelineEntries(context.Context, int64, int) ([]TimelineEntry, error) {
+	return []TimelineEntry{}, nil
+}
+
+func (m *MockPostsStore) GetTimelineEntriesOf(context.Context, int64, []int64, int) ([]TimelineEntry, error) {
+	return []TimelineEntry{}, nil
+}
+
+func (m *MockPostsStore) GetFeedByIds(context.Context, int64, []int64, PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+func (m *MockPostsStore) GetPostsByUserId(context.Context, int64, int64, PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+func (m *MockPostsStore) SoftDelete(ctx context.Context, postId int64, deletedBy int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) GetDeletedById(ctx context.Context, postId int64) (*Post, error) {
+	return &Post{Id: postId}, nil
+}
+
+func (m *MockPostsStore) Restore(ctx context.Context, postId int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) GetTrash(context.Context, int64, PaginatedFeedQuery) ([]Post, error) {
+	return []Post{}, nil
+}
+
+func (m *MockPostsStore) GetPurgeableIds(context.Context, time.Time, int) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockPostsStore) Purge(ctx context.Context, postIds []int64) ([]int64, error) {
+	return postIds, nil
+}
+
+func (m *MockPostsStore) GetUnpublished(context.Context, int64, string, PaginatedFeedQuery) ([]Post, error) {
+	return []Post{}, nil
+}
+
+func (m *MockPostsStore) Schedule(context.Context, *Post, *time.Time, int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) Publish(context.Context, *Post, int64) error {
+	return nil
+}
+
+func (m *MockPostsStore) GetDueIds(context.Context, time.Time, int) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockPostsStore) RenderMissing(context.Context, int64, int) (int64, error) {
+	return 0, nil
+}
+
+type MockRepostsStore struct{}
+
+func (m *MockRepostsStore) Create(context.Context, *Repost) error {
+	return nil
+}
+
+func (m *MockRepostsStore) Delete(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockRepostsStore) GetStats(context.Context, int64, []int64) (map[int64]RepostStats, error) {
+	return map[int64]RepostStats{}, nil
+}
+
+func (m *MockRepostsStore) GetQuoted(context.Context, int64, []int64) (map[int64]QuotedPost, error) {
+	return map[int64]QuotedPost{}, nil
+}
+
+type MockPollsStore struct{}
+
+func (m *MockPollsStore) Create(ctx context.Context, poll *Poll) error {
+	poll.Id = 1
+	return nil
+}
+
+func (m *MockPollsStore) GetByPostIds(context.Context, int64, []int64) (map[int64]*Poll, error) {
+	return map[int64]*Poll{}, nil
+}
+
+func (m *MockPollsStore) Vote(context.Context, int64, int64, []int64) error {
+	return nil
+}
+
+type MockMentionsStore struct{}
+
+func (m *MockMentionsStore) Sync(context.Context, int64, int64, *int64, []string) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockMentionsStore) GetByUserId(context.Context, int64, int64, PaginatedFeedQuery) ([]Mention, error) {
+	return []Mention{}, nil
+}
+
+type MockBookmarksStore struct{}
+
+func (m *MockBookmarksStore) Add(ctx context.Context, bookmark *Bookmark) error {
+	return nil
+}
+
+func (m *MockBookmarksStore) Remove(ctx context.Context, userId int64, postId int64) error {
+	return nil
+}
+
+func (m *MockBookmarksStore) GetByUserId(context.Context, int64, *int64, PaginatedFeedQuery) ([]Bookmark, error) {
+	return []Bookmark{}, nil
+}
+
+func (m *MockBookmarksStore) GetBookmarkedIds(context.Context, int64, []int64) (map[int64]bool, error) {
+	return map[int64]bool{}, nil
+}
+
+func (m *MockBookmarksStore) CreateFolder(ctx context.Context, folder *BookmarkFolder) error {
+	folder.Id = 1
+	return nil
+}
+
+func (m *MockBookmarksStore) GetFolders(ctx context.Context, userId int64) ([]BookmarkFolder, error) {
+	return []BookmarkFolder{}, nil
+}
+
+func (m *MockBookmarksStore) DeleteFolder(ctx context.Context, userId int64, folderId int64) error {
+	return nil
+}
+
+type MockMediaStore struct{}
+
+func (m *MockMediaStore) Create(ctx context.Context, media *Media) error {
+	media.Id = 1
+	return nil
+}
+
+func (m *MockMediaStore) GetById(ctx context.Context, mediaId int64) (*Media, error) {
+	return &Media{Id: mediaId}, nil
+}
+
+func (m *MockMediaStore) GetByPostIds(context.Context, []int64) (map[int64][]Media, error) {
+	return map[int64][]Media{}, nil
+}
+
+func (m *MockMediaStore) AttachToPost(ctx context.Context, postId int64, userId int64, mediaIds []int64) error {
+	return nil
+}
+
+func (m *MockMediaStore) UpdateAltText(ctx context.Context, media *Media) error {
+	return nil
+}
+
+func (m *MockMediaStore) Delete(ctx context.Context, mediaId int64) error {
+	return nil
+}
+
+func (m *MockMediaStore) CreateFromDuplicate(ctx context.Context, media *Media, original *Media) error {
+	media.Id = 1
+	return nil
+}
+
+func (m *MockMediaStore) GetReadyByContentHash(ctx context.Context, hash string) (*Media, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockMediaStore) CountByContentHash(ctx context.Context, hash string) (int, error) {
+	return 0, nil
+}
+
+func (m *MockMediaStore) ClaimPending(ctx context.Context, mediaId int64) (*Media, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockMediaStore) CompleteProcessing(ctx context.Context, media *Media) error {
+	return nil
+}
+
+func (m *MockMediaStore) FailProcessing(ctx context.Context, mediaId int64, reason string) error {
+	return nil
+}
+
+func (m *MockMediaStore) GetPendingIds(context.Context, bool) ([]int64, error) {
+	return []int64{}, nil
+}
+
 type MockUserStore struct{}
 
 func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, u *User) error {
@@ -38,6 +271,211 @@ func (m *MockUserStore) Activate(ctx context.Context, t string) error {
 	return nil
 }
 
+func (m *MockUserStore) UpdateProfile(ctx context.Context, u *User) error {
+	return nil
+}
+
+func (m *MockUserStore) Suspend(ctx context.Context, id int64, until time.Time) error {
+	return nil
+}
+
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 	return nil
 }
+
+type MockCommentsStore struct{}
+
+func (m *MockCommentsStore) GetById(ctx context.Context, commentId int64) (*Comment, error) {
+	return &Comment{Id: commentId}, nil
+}
+
+func (m *MockCommentsStore) Create(ctx context.Context, comment *Comment) error {
+	comment.Id = 1
+	return nil
+}
+
+func (m *MockCommentsStore) Delete(context.Context, int64) error {
+	return nil
+}
+
+func (m *MockCommentsStore) SoftDelete(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockCommentsStore) GetDeletedById(context.Context, int64) (*Comment, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockCommentsStore) Restore(context.Context, int64) error {
+	return nil
+}
+
+func (m *MockCommentsStore) GetTrash(context.Context, int64, PaginatedFeedQuery) ([]Comment, error) {
+	return []Comment{}, nil
+}
+
+func (m *MockCommentsStore) PurgeDeleted(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockCommentsStore) Update(context.Context, *Comment) error {
+	return nil
+}
+
+func (m *MockCommentsStore) GetByPostId(context.Context, int64, int64) ([]Comment, error) {
+	return []Comment{}, nil
+}
+
+func (m *MockCommentsStore) RenderMissing(context.Context, int64, int) (int64, error) {
+	return 0, nil
+}
+
+type MockFollowersStore struct{}
+
+func (m *MockFollowersStore) Follow(ctx context.Context, followerId int64, userId int64) (bool, error) {
+	if followerId == userId {
+		return false, ErrSelfFollow
+	}
+	return false, nil
+}
+
+func (m *MockFollowersStore) Unfollow(ctx context.Context, followerId int64, userId int64) error {
+	return nil
+}
+
+func (m *MockFollowersStore) GetFollowedUsersById(ctx context.Context, userId int64) ([]FollowedUserDetails, error) {
+	return nil, nil
+}
+
+func (m *MockFollowersStore) GetFollowers(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error) {
+	return []FollowConnection{}, nil
+}
+
+func (m *MockFollowersStore) GetFollowing(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error) {
+	return []FollowConnection{}, nil
+}
+
+func (m *MockFollowersStore) GetMutuals(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error) {
+	return []FollowConnection{}, nil
+}
+
+func (m *MockFollowersStore) GetCounts(ctx context.Context, userId int64) (*FollowCounts, error) {
+	return &FollowCounts{}, nil
+}
+
+func (m *MockFollowersStore) GetFollowerIds(ctx context.Context, userId int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockFollowersStore) GetRelationship(ctx context.Context, userId int64, otherId int64) (*Relationship, error) {
+	return &Relationship{UserId: otherId}, nil
+}
+
+func (m *MockFollowersStore) GetRequests(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error) {
+	return []FollowConnection{}, nil
+}
+
+func (m *MockFollowersStore) ApproveRequest(ctx context.Context, userId int64, requesterId int64) error {
+	return nil
+}
+
+func (m *MockFollowersStore) RejectRequest(ctx context.Context, userId int64, requesterId int64) error {
+	return nil
+}
+
+func (m *MockFollowersStore) ApproveAllRequests(ctx context.Context, userId int64) error {
+	return nil
+}
+
+type MockCloseFriendsStore struct{}
+
+func (m *MockCloseFriendsStore) Add(ctx context.Context, userId int64, friendId int64) error {
+	if userId == friendId {
+		return ErrSelfCloseFriend
+	}
+	return nil
+}
+
+func (m *MockCloseFriendsStore) Remove(ctx context.Context, userId int64, friendId int64) error {
+	return nil
+}
+
+func (m *MockCloseFriendsStore) GetByUserId(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error) {
+	return []FollowConnection{}, nil
+}
+
+func (m *MockCloseFriendsStore) GetIds(ctx context.Context, userId int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockCloseFriendsStore) IsCloseFriend(ctx context.Context, userId int64, friendId int64) (bool, error) {
+	return false, nil
+}
+
+type MockBlocksStore struct{}
+
+func (m *MockBlocksStore) Block(ctx context.Context, userId int64, blockedId int64) error {
+	if userId == blockedId {
+		return ErrSelfBlock
+	}
+	return nil
+}
+
+func (m *MockBlocksStore) Unblock(ctx context.Context, userId int64, blockedId int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) GetBlocked(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error) {
+	return []FollowConnection{}, nil
+}
+
+func (m *MockBlocksStore) IsBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	return false, nil
+}
+
+type MockModerationStore struct{}
+
+func (m *MockModerationStore) GetRules(ctx context.Context) ([]ModerationRule, error) {
+	return []ModerationRule{}, nil
+}
+
+func (m *MockModerationStore) CreateRule(ctx context.Context, rule *ModerationRule) error {
+	rule.Id = 1
+	return nil
+}
+
+func (m *MockModerationStore) DeleteRule(ctx context.Context, ruleId int64) error {
+	return nil
+}
+
+func (m *MockModerationStore) GetRecentContent(context.Context, int64, string, int64, time.Time, int) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *MockModerationStore) RecordDecision(ctx context.Context, decision *ModerationDecision) error {
+	decision.Id = 1
+	return nil
+}
+
+func (m *MockModerationStore) GetDecisionById(ctx context.Context, decisionId int64) (*ModerationDecision, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockModerationStore) GetDecisions(context.Context, DecisionQuery) ([]ModerationDecision, error) {
+	return []ModerationDecision{}, nil
+}
+
+func (m *MockModerationStore) ReviewDecision(ctx context.Context, decision *ModerationDecision) error {
+	return nil
+}
+
+type MockRolesStore struct{}
+
+func (m *MockRolesStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	levels := map[string]int64{"user": 1, "moderator": 2, "admin": 3}
+	level, ok := levels[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &Role{Name: name, Level: level}, nil
+}
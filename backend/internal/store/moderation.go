@@ -0,0 +1,380 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of moderation rules
+const (
+	ModerationRuleTerm   = "term"
+	ModerationRuleRegex  = "regex"
+	ModerationRuleDomain = "domain"
+)
+
+// Outcomes of a moderator's review of flagged or held content
+const (
+	ModerationReviewApprove = "approve"
+	ModerationReviewReject  = "reject"
+)
+
+type ModerationRule struct {
+	Id        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Pattern   string `json:"pattern"`
+	Action    string `json:"action"` // flag, hold or reject
+	Note      string `json:"note"`
+	CreatedBy *int64 `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ModerationDecision struct {
+	Id            int64           `json:"id"`
+	ContentType   string          `json:"content_type"`
+	ContentId     *int64          `json:"content_id"` // Nil for rejected content, which is never stored
+	AuthorId      int64           `json:"author_id"`
+	Verdict       string          `json:"verdict"`
+	Findings      json.RawMessage `json:"findings"`
+	Snapshot      string          `json:"snapshot"` // The text as it was checked
+	CreatedAt     string          `json:"created_at"`
+	ReviewedBy    *int64          `json:"reviewed_by"`
+	ReviewedAt    *string         `json:"reviewed_at"`
+	ReviewOutcome *string         `json:"review_outcome"`
+}
+
+// Filters of the decisions moderators browse
+type DecisionQuery struct {
+	Limit       int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset      int    `json:"offset" validate:"gte=0"`
+	Verdict     string `json:"verdict" validate:"omitempty,oneof=allow flag hold reject"`
+	ContentType string `json:"content_type" validate:"omitempty,oneof=post comment"`
+	AuthorId    int64  `json:"author_id" validate:"gte=0"`
+	Pending     bool   `json:"pending"` // Only flagged and held content nobody reviewed yet
+}
+
+func (dq DecisionQuery) Parse(r *http.Request) (DecisionQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return dq, err
+		}
+		dq.Limit = l
+	}
+
+	if offset := qs.Get("offset"); offset != "" {
+		ofs, err := strconv.Atoi(offset)
+		if err != nil {
+			return dq, err
+		}
+		dq.Offset = ofs
+	}
+
+	if verdict := qs.Get("verdict"); verdict != "" {
+		dq.Verdict = verdict
+	}
+	if contentType := qs.Get("content_type"); contentType != "" {
+		dq.ContentType = contentType
+	}
+
+	if authorId := qs.Get("author_id"); authorId != "" {
+		id, err := strconv.ParseInt(authorId, 10, 64)
+		if err != nil {
+			return dq, err
+		}
+		dq.AuthorId = id
+	}
+
+	if pending := qs.Get("pending"); pending != "" {
+		p, err := strconv.ParseBool(pending)
+		if err != nil {
+			return dq, err
+		}
+		dq.Pending = p
+	}
+
+	return dq, nil
+}
+
+type ModerationStore struct {
+	db *sql.DB
+}
+
+func (s *ModerationStore) GetRules(ctx context.Context) ([]ModerationRule, error) {
+	query := `
+		SELECT id, kind, pattern, action, note, created_by, created_at
+		FROM moderation_rules
+		ORDER BY kind, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []ModerationRule{}
+	for rows.Next() {
+		var rule ModerationRule
+		err := rows.Scan(
+			&rule.Id,
+			&rule.Kind,
+			&rule.Pattern,
+			&rule.Action,
+			&rule.Note,
+			&rule.CreatedBy,
+			&rule.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// Adds a rule, the same pattern can only be added once per kind
+func (s *ModerationStore) CreateRule(ctx context.Context, rule *ModerationRule) error {
+	query := `
+		INSERT INTO moderation_rules (kind, pattern, action, note, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, rule.Kind, rule.Pattern, rule.Action, rule.Note, rule.CreatedBy).Scan(
+		&rule.Id,
+		&rule.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ModerationStore) DeleteRule(ctx context.Context, ruleId int64) error {
+	query := `DELETE FROM moderation_rules WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, ruleId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Returns the texts of the user's posts or comments created since the provided time, the newest
+// first. Deleted content counts too, deleting a copy must not make room for the next one.
+func (s *ModerationStore) GetRecentContent(ctx context.Context, userId int64, kind string, excludeId int64, since time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT title || E'\n' || content FROM posts
+		WHERE user_id = $1 AND id <> $2 AND created_at >= $3
+		ORDER BY created_at DESC
+		LIMIT $4
+	`
+	if kind == "comment" {
+		query = `
+			SELECT content FROM comments
+			WHERE user_id = $1 AND id <> $2 AND created_at >= $3
+			ORDER BY created_at DESC
+			LIMIT $4
+		`
+	}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, excludeId, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var texts []string
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+	return texts, rows.Err()
+}
+
+func (s *ModerationStore) RecordDecision(ctx context.Context, decision *ModerationDecision) error {
+	query := `
+		INSERT INTO moderation_decisions (content_type, content_id, author_id, verdict, findings, snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	findings := decision.Findings
+	if findings == nil {
+		findings = json.RawMessage(`[]`)
+	}
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		decision.ContentType,
+		decision.ContentId,
+		decision.AuthorId,
+		decision.Verdict,
+		[]byte(findings),
+		decision.Snapshot,
+	).Scan(
+		&decision.Id,
+		&decision.CreatedAt,
+	)
+}
+
+func (s *ModerationStore) GetDecisionById(ctx context.Context, decisionId int64) (*ModerationDecision, error) {
+	query := `SELECT ` + decisionColumns + ` FROM moderation_decisions WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	decision := &ModerationDecision{}
+	if err := scanDecision(s.db.QueryRowContext(ctx, query, decisionId), decision); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return decision, nil
+}
+
+// Returns the decisions matching the query, the oldest first so held content does not wait forever
+func (s *ModerationStore) GetDecisions(ctx context.Context, dq DecisionQuery) ([]ModerationDecision, error) {
+	query := `
+		SELECT ` + decisionColumns + `
+		FROM moderation_decisions
+		WHERE ($3 = '' OR verdict = $3)
+			AND ($4 = '' OR content_type = $4)
+			AND ($5 = 0 OR author_id = $5)
+			AND (NOT $6 OR verdict IN ('flag', 'hold') AND reviewed_at IS NULL)
+		ORDER BY created_at, id
+		LIMIT $1 OFFSET $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, dq.Limit, dq.Offset, dq.Verdict, dq.ContentType, dq.AuthorId, dq.Pending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []ModerationDecision{}
+	for rows.Next() {
+		var decision ModerationDecision
+		if err := scanDecision(rows, &decision); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, rows.Err()
+}
+
+// Records the review of flagged or held content. Approving held content publishes it, earlier
+// decisions about the same content are closed with the same outcome. A decision which was
+// reviewed already is a conflict.
+func (s *ModerationStore) ReviewDecision(ctx context.Context, decision *ModerationDecision) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+			UPDATE moderation_decisions
+			SET reviewed_by = $2, reviewed_at = NOW(), review_outcome = $3
+			WHERE id = $1 AND verdict IN ('flag', 'hold') AND reviewed_at IS NULL AND content_id IS NOT NULL
+			RETURNING reviewed_at
+		`,
+			decision.Id,
+			decision.ReviewedBy,
+			decision.ReviewOutcome,
+		).Scan(&decision.ReviewedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrConflict
+			}
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE moderation_decisions
+			SET reviewed_by = $3, reviewed_at = NOW(), review_outcome = $4
+			WHERE content_type = $1 AND content_id = $2 AND verdict IN ('flag', 'hold') AND reviewed_at IS NULL
+		`,
+			decision.ContentType,
+			decision.ContentId,
+			decision.ReviewedBy,
+			decision.ReviewOutcome,
+		)
+		if err != nil {
+			return err
+		}
+
+		if *decision.ReviewOutcome != ModerationReviewApprove {
+			return nil
+		}
+		release := `UPDATE posts SET held_at = NULL WHERE id = $1`
+		if decision.ContentType == "comment" {
+			release = `UPDATE comments SET held_at = NULL WHERE id = $1`
+		}
+		_, err = tx.ExecContext(ctx, release, decision.ContentId)
+		return err
+	})
+}
+
+/* Helper Functions */
+
+const decisionColumns = `
+	id, content_type, content_id, author_id, verdict, findings, snapshot, created_at,
+	reviewed_by, reviewed_at, review_outcome
+`
+
+func scanDecision(row rowScanner, decision *ModerationDecision) error {
+	var findings []byte
+	err := row.Scan(
+		&decision.Id,
+		&decision.ContentType,
+		&decision.ContentId,
+		&decision.AuthorId,
+		&decision.Verdict,
+		&findings,
+		&decision.Snapshot,
+		&decision.CreatedAt,
+		&decision.ReviewedBy,
+		&decision.ReviewedAt,
+		&decision.ReviewOutcome,
+	)
+	if err != nil {
+		return err
+	}
+	decision.Findings = json.RawMessage(findings)
+	return nil
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrSelfMute = errors.New("users can not mute themselves")
+
+// A word hidden from the feed and the comments a user reads
+type MutedWord struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id"`
+	Word      string `json:"word"`
+	CreatedAt string `json:"created_at"`
+}
+
+type MutesStore struct {
+	db *sql.DB
+}
+
+// Mutes the other user. Unlike a block it only changes what the user sees, the muted user is not told.
+func (s *MutesStore) Mute(ctx context.Context, userId int64, mutedId int64) error {
+	if userId == mutedId {
+		return ErrSelfMute
+	}
+
+	query := `
+		INSERT INTO user_mutes (user_id, muted_id)
+		VALUES ($1, $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, query, userId, mutedId); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505": // unique_violation
+				return ErrConflict
+			case "23503": // foreign_key_violation, the muted user does not exist
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *MutesStore) Unmute(ctx context.Context, userId int64, mutedId int64) error {
+	query := `
+		DELETE FROM user_mutes
+		WHERE user_id = $1 AND muted_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, mutedId)
+	return err
+}
+
+// Returns the users muted by the user, the most recently muted first unless sort=asc
+func (s *MutesStore) GetMuted(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]FollowConnection, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, m.created_at
+		FROM user_mutes m
+		JOIN users u ON u.id = m.muted_id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at ` + fq.Sort + `, u.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	return queryConnections(ctx, s.db, query, userId, fq.Limit, fq.Offset)
+}
+
+// Returns the muted words of the user in alphabetical order
+func (s *MutesStore) GetWords(ctx context.Context, userId int64) ([]MutedWord, error) {
+	query := `
+		SELECT id, user_id, word, created_at
+		FROM muted_words
+		WHERE user_id = $1
+		ORDER BY lower(word)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []MutedWord{}
+	for rows.Next() {
+		var w MutedWord
+		if err := rows.Scan(&w.Id, &w.UserId, &w.Word, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, rows.Err()
+}
+
+// Adds a muted word, the same word in another case is a conflict
+func (s *MutesStore) AddWord(ctx context.Context, word *MutedWord) error {
+	query := `
+		INSERT INTO muted_words (user_id, word)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, word.UserId, word.Word).Scan(&word.Id, &word.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *MutesStore) DeleteWord(ctx context.Context, userId int64, wordId int64) error {
+	query := `
+		DELETE FROM muted_words
+		WHERE id = $1 AND user_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, wordId, userId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// Kinds of notifications
+const (
+	NotificationReportResolved  = "report_resolved"
+	NotificationContentRemoved  = "content_removed"
+	NotificationContentApproved = "content_approved"
+	NotificationMention         = "mention"
+	NotificationWarning         = "moderation_warning"
+	NotificationSuspension      = "account_suspended"
+)
+
+type Notification struct {
+	Id        int64           `json:"id"`
+	UserId    int64           `json:"user_id"`
+	ActorId   *int64          `json:"actor_id"` // Nil when the notification comes from the platform itself
+	Type      string          `json:"type"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"` // Ids of the objects the notification is about, depends on the type
+	ReadAt    *string         `json:"read_at"`
+	CreatedAt string          `json:"created_at"`
+}
+
+type NotificationsStore struct {
+	db *sql.DB
+}
+
+// Stores the notifications in one transaction, so either all recipients get theirs or none does
+func (s *NotificationsStore) Create(ctx context.Context, notifications ...*Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, actor_id, type, message, data)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		for _, n := range notifications {
+			data := n.Data
+			if data == nil {
+				data = json.RawMessage(`{}`)
+			}
+			err := tx.QueryRowContext(ctx, query, n.UserId, n.ActorId, n.Type, n.Message, []byte(data)).Scan(
+				&n.Id,
+				&n.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the notifications of the user, the newest first. Notifications caused by users the
+// user blocked, was blocked by or muted are left out.
+func (s *NotificationsStore) GetByUserId(ctx context.Context, userId int64, unreadOnly bool, fq PaginatedFeedQuery) ([]Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.message, n.data, n.read_at, n.created_at
+		FROM notifications n
+		WHERE n.user_id = $1
+			AND (NOT $4 OR n.read_at IS NULL)
+			AND (
+				n.actor_id IS NULL
+				OR (NOT ` + blockedCondition("n.actor_id", "$1") + ` AND NOT ` + mutedCondition("$1", "n.actor_id") + `)
+			)
+		ORDER BY n.created_at ` + fq.Sort + `, n.id ` + fq.Sort + `
+		LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, fq.Limit, fq.Offset, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var data []byte
+		err := rows.Scan(
+			&n.Id,
+			&n.UserId,
+			&n.ActorId,
+			&n.Type,
+			&n.Message,
+			&data,
+			&n.ReadAt,
+			&n.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		n.Data = json.RawMessage(data)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *NotificationsStore) MarkRead(ctx context.Context, userId int64, notificationId int64) error {
+	query := `
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, notificationId, userId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *NotificationsStore) MarkAllRead(ctx context.Context, userId int64) error {
+	query := `
+		UPDATE notifications SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId)
+	return err
+}
@@ -16,6 +16,8 @@ type PaginatedFeedQuery struct {
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	Mode   string   `json:"mode" validate:"omitempty,oneof=chronological ranked"`
+	AsOf   string   `json:"as_of"` // Snapshot the ranked feed is computed at, so its pages stay consistent
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -64,6 +66,19 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if until != "" {
 		fq.Until = parseTime(until)
 	}
+	mode := qs.Get("mode")
+	if mode != "" {
+		fq.Mode = mode
+	}
+
+	asOf := qs.Get("as_of")
+	if asOf != "" {
+		t, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			return fq, err
+		}
+		fq.AsOf = t.Format(time.RFC3339)
+	}
 	log.Println("fq :", fq.Search)
 
 	return fq, nil
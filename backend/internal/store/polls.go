@@ -0,0 +1,196 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollClosed       = errors.New("the poll is closed")
+	ErrPollSingleChoice = errors.New("only one option can be picked in the poll")
+	ErrPollOption       = errors.New("the option is not part of the poll")
+)
+
+// A poll attached to a post, as the viewer sees it
+type Poll struct {
+	Id             int64        `json:"id"`
+	PostId         int64        `json:"post_id"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	HideResults    bool         `json:"hide_results"` // Whether the author hides the results until the viewer votes
+	ClosesAt       string       `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	Voters         int          `json:"voters"`
+	Voted          bool         `json:"voted"`          // Whether the viewer voted
+	ResultsHidden  bool         `json:"results_hidden"` // Set while the option counts are withheld from the viewer
+	Options        []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	Id     int64  `json:"id"`
+	Text   string `json:"text"`
+	Votes  *int   `json:"votes,omitempty"` // Nil while the results are hidden from the viewer
+	Chosen bool   `json:"chosen"`          // Whether the viewer picked the option
+}
+
+type PollsStore struct {
+	db *sql.DB
+}
+
+// Creates the poll of a post together with its options, which keep their order
+func (s *PollsStore) Create(ctx context.Context, poll *Poll) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO polls (post_id, multiple_choice, hide_results, closes_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, closes_at
+		`, poll.PostId, poll.MultipleChoice, poll.HideResults, poll.ClosesAt).Scan(&poll.Id, &poll.ClosesAt)
+		if err != nil {
+			return err
+		}
+
+		for i := range poll.Options {
+			option := &poll.Options[i]
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO poll_options (poll_id, position, text)
+				VALUES ($1, $2, $3)
+				RETURNING id
+			`, poll.Id, i, option.Text).Scan(&option.Id)
+			if err != nil {
+				return err
+			}
+			votes := 0
+			option.Votes = &votes
+		}
+		return nil
+	})
+}
+
+// Returns the polls of the posts keyed by post id, as the viewer sees them. The option counts of
+// a poll which hides its results are withheld until the viewer voted or the poll closed, its
+// author always sees them.
+func (s *PollsStore) GetByPostIds(ctx context.Context, viewerId int64, postIds []int64) (map[int64]*Poll, error) {
+	polls := map[int64]*Poll{}
+	if len(postIds) == 0 {
+		return polls, nil
+	}
+
+	query := `
+		SELECT
+			pl.id, pl.post_id, pl.multiple_choice, pl.hide_results, pl.closes_at, pl.closes_at <= NOW(),
+			(SELECT COUNT(*) FROM poll_voters v WHERE v.poll_id = pl.id),
+			EXISTS (SELECT 1 FROM poll_voters v WHERE v.poll_id = pl.id AND v.user_id = $1),
+			p.user_id = $1,
+			o.id, o.text,
+			(SELECT COUNT(*) FROM poll_votes pv WHERE pv.option_id = o.id),
+			EXISTS (SELECT 1 FROM poll_votes pv WHERE pv.option_id = o.id AND pv.user_id = $1)
+		FROM polls pl
+		JOIN posts p ON p.id = pl.post_id
+		JOIN poll_options o ON o.poll_id = pl.id
+		WHERE pl.post_id = ANY($2)
+		ORDER BY pl.id, o.position
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			p        Poll
+			isAuthor bool
+			o        PollOption
+			votes    int
+		)
+		err := rows.Scan(
+			&p.Id,
+			&p.PostId,
+			&p.MultipleChoice,
+			&p.HideResults,
+			&p.ClosesAt,
+			&p.Closed,
+			&p.Voters,
+			&p.Voted,
+			&isAuthor,
+			&o.Id,
+			&o.Text,
+			&votes,
+			&o.Chosen,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		poll, ok := polls[p.PostId]
+		if !ok {
+			p.ResultsHidden = p.HideResults && !p.Voted && !p.Closed && !isAuthor
+			poll = &p
+			polls[p.PostId] = poll
+		}
+		if !poll.ResultsHidden {
+			o.Votes = &votes
+		}
+		poll.Options = append(poll.Options, o)
+	}
+	return polls, rows.Err()
+}
+
+// Records the vote of the user for the options. Every user votes once, a second vote fails with
+// ErrConflict, and only while the poll is open.
+func (s *PollsStore) Vote(ctx context.Context, pollId int64, userId int64, optionIds []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		var closed, multipleChoice bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT closes_at <= NOW(), multiple_choice FROM polls WHERE id = $1
+		`, pollId).Scan(&closed, &multipleChoice)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if closed {
+			return ErrPollClosed
+		}
+		if !multipleChoice && len(optionIds) > 1 {
+			return ErrPollSingleChoice
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO poll_voters (poll_id, user_id) VALUES ($1, $2)`, pollId, userId)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+				return ErrConflict
+			}
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO poll_votes (poll_id, option_id, user_id)
+			SELECT poll_id, id, $2 FROM poll_options
+			WHERE poll_id = $1 AND id = ANY($3)
+		`, pollId, userId, pq.Array(optionIds))
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows != int64(len(optionIds)) {
+			return ErrPollOption
+		}
+		return nil
+	})
+}
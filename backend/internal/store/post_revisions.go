@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// A stored version of a post. Every version has one, the current version included.
+type PostRevision struct {
+	PostId    int64  `json:"post_id"`
+	Version   int64  `json:"version"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	EditedBy  *int64 `json:"edited_by"` // Author or moderator who wrote this version
+	CreatedAt string `json:"created_at"`
+}
+
+type PostRevisionsStore struct {
+	db *sql.DB
+}
+
+// Returns all versions of a post, the oldest first
+func (s *PostRevisionsStore) GetByPostId(ctx context.Context, postId int64) ([]PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, edited_by, created_at
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY version
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var r PostRevision
+		err := rows.Scan(
+			&r.PostId,
+			&r.Version,
+			&r.Title,
+			&r.Content,
+			&r.EditedBy,
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *PostRevisionsStore) GetByVersion(ctx context.Context, postId int64, version int64) (*PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, edited_by, created_at
+		FROM post_revisions
+		WHERE post_id = $1 AND version = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	revision := &PostRevision{}
+	err := s.db.QueryRowContext(ctx, query, postId, version).Scan(
+		&revision.PostId,
+		&revision.Version,
+		&revision.Title,
+		&revision.Content,
+		&revision.EditedBy,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return revision, nil
+}
+
+/* Helper Functions */
+
+// Records the current state of a post, it has to run in the transaction which wrote that state
+func insertPostRevision(ctx context.Context, tx *sql.Tx, post *Post, editorId int64) error {
+	query := `
+		INSERT INTO post_revisions (post_id, version, title, content, edited_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, post.Id, post.Version, post.Title, post.Content, editorId)
+	return err
+}
@@ -4,63 +4,130 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/Sumitwarrior7/social/internal/markdown"
 	"github.com/lib/pq"
 )
 
 type Post struct {
-	Id        int64
-	Content   string
-	Title     string
-	UserId    int64
-	Tags      []string
-	CreatedAt string
-	UpdatedAt string
-	Version   int64 // Getting added through add_version migrations[It is mainly used for optimistic concurrency]
-	Comments  []Comment
-	User      User
+	Id          int64
+	Content     string
+	ContentHtml string // Rendered from the Markdown of the content, safe to embed as it is
+	Title       string
+	UserId      int64
+	Tags        []string
+	CreatedAt   string
+	UpdatedAt   string
+	Version     int64       // Getting added through add_version migrations[It is mainly used for optimistic concurrency]
+	EditedAt    *string     // Time of the last edit, nil for posts which were never edited
+	DeletedAt   *string     `json:",omitempty"` // Only set for posts in the trash
+	DeletedBy   *int64      `json:",omitempty"`
+	HeldAt      *string     `json:",omitempty"` // Set while the post waits for a moderator, only its author and moderators see it
+	Status      string      `json:",omitempty"` // One of PostDraft, PostScheduled and PostPublished, lists only hold published posts
+	PublishAt   *string     `json:",omitempty"` // When a scheduled post is published
+	PublishedAt *string     `json:",omitempty"` // When the post was published, feeds are ordered by it
+	Visibility  string      // One of PostPublic, PostFollowers, PostCloseFriends and PostPrivate
+	Bookmarked  bool        // Whether the user reading the post bookmarked it
+	QuoteOf     *int64      `json:",omitempty"` // The post this one quotes
+	Quoted      *QuotedPost `json:",omitempty"` // What the user reading the post may see of the quoted one
+	RepostCount int
+	QuoteCount  int
+	Reposted    bool     // Whether the user reading the post reposted it
+	RepostedBy  []string `json:",omitempty"` // Users the reader follows who reposted the post
+	Poll        *Poll    `json:",omitempty"`
+	Comments    []Comment
+	Media       []Media
+	User        User
 }
 
+// States of a post, drafts and scheduled posts are only visible to their author
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
+// Audiences of a post besides its author. Posts of private accounts never reach beyond their
+// followers, whatever their visibility.
+const (
+	PostPublic       = "public"
+	PostFollowers    = "followers"
+	PostCloseFriends = "close_friends" // Only the users the author added to their close friends
+	PostPrivate      = "private"       // Only the author
+)
+
 type PostWithMetaData struct {
 	Post
 	CommentCount int
+	Recommended  bool `json:",omitempty"` // Set on posts the ranked feed mixes in from users the viewer does not follow
 }
 
 type PostsStore struct {
 	db *sql.DB
 }
 
+// Creates the post together with its first revision and the links to its tags. Posts without a
+// status are published right away.
 func (s *PostsStore) Create(ctx context.Context, post *Post) error {
 	query := `
-		INSERT INTO posts (content, title, user_id, tags)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+		INSERT INTO posts (content, title, user_id, tags, held_at, quote_of, status, publish_at, visibility, content_html, published_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CASE WHEN $7 = 'published' THEN NOW() END)
+		RETURNING id, created_at, updated_at, version, held_at, status, publish_at, published_at, visibility
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
-	defer cancel()
-
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserId,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.Id,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
 
-	if err != nil {
-		return err
+	if post.Status == "" {
+		post.Status = PostPublished
 	}
-	return nil
+	if post.Visibility == "" {
+		post.Visibility = PostPublic
+	}
+	post.ContentHtml = markdown.Render(post.Content)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserId,
+			pq.Array(post.Tags),
+			post.HeldAt,
+			post.QuoteOf,
+			post.Status,
+			post.PublishAt,
+			post.Visibility,
+			post.ContentHtml,
+		).Scan(
+			&post.Id,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+			&post.HeldAt,
+			&post.Status,
+			&post.PublishAt,
+			&post.PublishedAt,
+			&post.Visibility,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := syncPostTags(ctx, tx, post.Id, post.Tags); err != nil {
+			return err
+		}
+		return insertPostRevision(ctx, tx, post, post.UserId)
+	})
 }
 
 func (s *PostsStore) GetById(ctx context.Context, postId int64) (*Post, error) {
 	query := `
-		SELECT id, title, user_id, content, created_at, updated_at, tags, version 
-		FROM posts WHERE id = $1
+		SELECT id, title, user_id, content, content_html, created_at, updated_at, tags, version, edited_at, held_at, status, publish_at,
+			published_at, visibility
+		FROM posts WHERE id = $1 AND deleted_at IS NULL
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
 	defer cancel()
@@ -77,15 +144,22 @@ func (s *PostsStore) GetById(ctx context.Context, postId int64) (*Post, error) {
 		&post.Title,
 		&post.UserId,
 		&post.Content,
+		&post.ContentHtml,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
+		&post.EditedAt,
+		&post.HeldAt,
+		&post.Status,
+		&post.PublishAt,
+		&post.PublishedAt,
+		&post.Visibility,
 	)
 
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrNotFound):
+		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
 		default:
 			return nil, err
@@ -121,60 +195,404 @@ func (s *PostsStore) Delete(ctx context.Context, postId int64) error {
 	return nil
 }
 
-func (s *PostsStore) Update(ctx context.Context, post *Post) error {
+// Saves a new version of the post and keeps it as a revision in the same transaction.
+// It fails with ErrEditConflict if the post was changed since it was read. An edit can put
+// the post on hold but never releases it, only a moderator does.
+func (s *PostsStore) Update(ctx context.Context, post *Post, editorId int64) error {
 	query := `
 		UPDATE posts 
-		SET title = $1, content = $2, version = version+1
-		WHERE id = $3 AND version = $4
-		RETURNING version
+		SET title = $1, content = $2, content_html = $8, tags = $6, visibility = $7, version = version+1,
+			updated_at = NOW(), edited_at = NOW(), held_at = COALESCE(held_at, $5)
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+		RETURNING version, updated_at, edited_at, held_at
+	`
+
+	post.ContentHtml = markdown.Render(post.Content)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			post.Id,
+			post.Version,
+			post.HeldAt,
+			pq.Array(post.Tags),
+			post.Visibility,
+			post.ContentHtml,
+		).Scan(
+			&post.Version,
+			&post.UpdatedAt,
+			&post.EditedAt,
+			&post.HeldAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		if err := syncPostTags(ctx, tx, post.Id, post.Tags); err != nil {
+			return err
+		}
+		return insertPostRevision(ctx, tx, post, editorId)
+	})
+}
+
+// Moves a post to the trash of its author, it stays restorable until the purge job removes it
+func (s *PostsStore) SoftDelete(ctx context.Context, postId int64, deletedBy int64) error {
+	query := `
+		UPDATE posts SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
 	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
 
+	res, err := s.db.ExecContext(ctx, query, postId, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Returns a post from the trash
+func (s *PostsStore) GetDeletedById(ctx context.Context, postId int64) (*Post, error) {
+	query := `
+		SELECT id, title, user_id, content, content_html, created_at, updated_at, tags, version, edited_at, deleted_at, deleted_by
+		FROM posts WHERE id = $1 AND deleted_at IS NOT NULL
+	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.Id,
-		post.Version,
-	).Scan(
+	post := &Post{}
+	err := s.db.QueryRowContext(ctx, query, postId).Scan(
+		&post.Id,
+		&post.Title,
+		&post.UserId,
+		&post.Content,
+		&post.ContentHtml,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		pq.Array(&post.Tags),
 		&post.Version,
+		&post.EditedAt,
+		&post.DeletedAt,
+		&post.DeletedBy,
 	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return post, nil
+}
 
+func (s *PostsStore) Restore(ctx context.Context, postId int64) error {
+	query := `
+		UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rows == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-// Shows the posts of the user and the other users that he followed
+// Returns the deleted posts of a user, the most recently deleted first
+func (s *PostsStore) GetTrash(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]Post, error) {
+	query := `
+		SELECT id, title, user_id, content, content_html, created_at, updated_at, tags, version, edited_at, deleted_at, deleted_by
+		FROM posts
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.Id,
+			&p.Title,
+			&p.UserId,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.EditedAt,
+			&p.DeletedAt,
+			&p.DeletedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// Returns up to limit posts which have been in the trash since before the provided time
+func (s *PostsStore) GetPurgeableIds(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	query := `
+		SELECT id FROM posts
+		WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Permanently deletes the provided posts together with their comments and media records.
+// Posts restored in the meantime are skipped, the ids of the removed posts are returned.
+func (s *PostsStore) Purge(ctx context.Context, postIds []int64) ([]int64, error) {
+	query := `
+		DELETE FROM posts
+		WHERE id = ANY($1) AND deleted_at IS NOT NULL
+		RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purged []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		purged = append(purged, id)
+	}
+	return purged, rows.Err()
+}
+
+// Returns the drafts and scheduled posts of a user, an empty status returns both. Scheduled posts
+// come in the order they go out, drafts the most recently edited first.
+func (s *PostsStore) GetUnpublished(ctx context.Context, userId int64, status string, fq PaginatedFeedQuery) ([]Post, error) {
+	query := `
+		SELECT id, title, user_id, content, content_html, created_at, updated_at, tags, version, edited_at, held_at, status, publish_at,
+			visibility
+		FROM posts
+		WHERE user_id = $1 AND deleted_at IS NULL AND status <> 'published' AND ($2 = '' OR status = $2)
+		ORDER BY publish_at ASC NULLS LAST, updated_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, status, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.Id,
+			&p.Title,
+			&p.UserId,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Status,
+			&p.PublishAt,
+			&p.Visibility,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// Schedules an unpublished post for the provided time, a nil time turns it back into a draft.
+// The new version is kept as a revision by the editor in the same transaction. Published posts can
+// not be scheduled, it fails with ErrNotFound for them.
+func (s *PostsStore) Schedule(ctx context.Context, post *Post, publishAt *time.Time, editorId int64) error {
+	query := `
+		UPDATE posts
+		SET status = CASE WHEN $2::timestamptz IS NULL THEN 'draft' ELSE 'scheduled' END,
+			publish_at = $2, version = version+1, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL AND status <> 'published'
+		RETURNING title, content, status, publish_at, version, updated_at
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, post.Id, publishAt).Scan(
+			&post.Title,
+			&post.Content,
+			&post.Status,
+			&post.PublishAt,
+			&post.Version,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		return insertPostRevision(ctx, tx, post, editorId)
+	})
+}
+
+// Publishes a draft or scheduled post, feeds place it at the moment it is published. The new
+// version is kept as a revision by the editor in the same transaction. It fails with ErrNotFound
+// if the post is already published.
+func (s *PostsStore) Publish(ctx context.Context, post *Post, editorId int64) error {
+	query := `
+		UPDATE posts
+		SET status = 'published', publish_at = NULL, published_at = NOW(), version = version+1, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL AND status <> 'published'
+		RETURNING title, content, status, publish_at, published_at, version, updated_at
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, post.Id).Scan(
+			&post.Title,
+			&post.Content,
+			&post.Status,
+			&post.PublishAt,
+			&post.PublishedAt,
+			&post.Version,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		return insertPostRevision(ctx, tx, post, editorId)
+	})
+}
+
+// Returns up to limit scheduled posts which are due at the provided time, the most overdue first
+func (s *PostsStore) GetDueIds(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	query := `
+		SELECT id FROM posts
+		WHERE status = 'scheduled' AND publish_at <= $1 AND deleted_at IS NULL
+		ORDER BY publish_at
+		LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Shows the posts of the user and the other users that he followed, and the posts they reposted.
+// A post reposted several times shows once, at its latest repost. Only accepted follows count, so
+// posts of private accounts never reach anyone else, searches and reposts included. Posts of
+// blocked and muted users, posts containing muted words and posts held for review are left out.
 func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
 	query := `
+		WITH ` + feedEntries + `
 		SELECT 
-			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
 			u.username,
-			COUNT(c.id) AS comments_count
-		FROM posts p
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.held_at IS NULL)
+		FROM feed_entries fe
+		JOIN posts p ON p.id = fe.post_id
 		JOIN users u ON p.user_id = u.id
-		LEFT JOIN comments c ON p.id = c.post_id
 		WHERE 
-			(
-				p.user_id = $1 
-				AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
-			)
-			OR  
-			(
-				p.user_id IN (
-					SELECT user_id 
-					FROM followers 
-					WHERE follower_id = $1
-				)
-				AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
-			)	
-		GROUP BY p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, u.username
-		ORDER BY p.created_at ` + fq.Sort + `
+			p.deleted_at IS NULL
+			AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
+			AND ` + feedVisible + `
+		ORDER BY fe.feed_at ` + fq.Sort + `, p.id ` + fq.Sort + `
 		LIMIT $2 OFFSET $3;
 	`
 
@@ -196,8 +614,13 @@ func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 			&p.UserId,
 			&p.Title,
 			&p.Content,
+			&p.ContentHtml,
 			&p.CreatedAt,
+			&p.PublishedAt,
 			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
 			pq.Array(&p.Tags),
 			&p.User.Username,
 			&p.CommentCount,
@@ -211,25 +634,36 @@ func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 	return feed, nil
 }
 
-func (s *PostsStore) GetPostsByUserId(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+// Shows the published posts of the user as the viewer sees them, private accounts only show posts to
+// their followers, held posts are only shown to their author and the visibility of each post is
+// respected
+func (s *PostsStore) GetPostsByUserId(ctx context.Context, userID int64, viewerId int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
 	query := `
 		SELECT 
-			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
 			u.username,
 			COUNT(c.id) AS comments_count
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		LEFT JOIN comments c ON p.id = c.post_id
-		WHERE p.user_id = $1 
-		GROUP BY p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, u.username
-		ORDER BY p.created_at DESC
+		LEFT JOIN comments c ON p.id = c.post_id AND c.deleted_at IS NULL AND c.held_at IS NULL
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL AND p.status = 'published'
+			AND (p.held_at IS NULL OR u.id = $4)
+			AND (
+				NOT u.is_private
+				OR u.id = $4
+				OR EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $4)
+			)
+			AND ` + audienceCondition("p", "$4") + `
+			AND NOT ` + blockedCondition("u.id", "$4") + `
+		GROUP BY p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags, u.username
+		ORDER BY p.published_at DESC
 		LIMIT $2 OFFSET $3;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset)
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, viewerId)
 	if err != nil {
 		return nil, err
 	}
@@ -244,8 +678,13 @@ func (s *PostsStore) GetPostsByUserId(ctx context.Context, userID int64, fq Pagi
 			&p.UserId,
 			&p.Title,
 			&p.Content,
+			&p.ContentHtml,
 			&p.CreatedAt,
+			&p.PublishedAt,
 			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
 			pq.Array(&p.Tags),
 			&p.User.Username,
 			&p.CommentCount,
@@ -258,3 +697,61 @@ func (s *PostsStore) GetPostsByUserId(ctx context.Context, userID int64, fq Pagi
 
 	return posts, nil
 }
+
+// Renders the content of up to limit posts saved before content was rendered, from the one after
+// afterId on. It returns the id of the last post it went through, 0 when none were left.
+func (s *PostsStore) RenderMissing(ctx context.Context, afterId int64, limit int) (int64, error) {
+	return renderMissing(ctx, s.db, "posts", afterId, limit)
+}
+
+/* Helper Functions */
+
+// Renders the Markdown of the rows of the table which have no HTML yet, the table is posts or
+// comments. Rows edited in the meantime were rendered on save and are left as they are.
+func renderMissing(ctx context.Context, db *sql.DB, table string, afterId int64, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, content FROM `+table+`
+		WHERE content_html = '' AND id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterId, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var contents []string
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+		contents = append(contents, content)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	rendered := make([]string, len(contents))
+	for i, content := range contents {
+		rendered[i] = markdown.Render(content)
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE `+table+` t SET content_html = r.html
+		FROM unnest($1::bigint[], $2::text[]) AS r (id, html)
+		WHERE t.id = r.id AND t.content_html = ''
+	`, pq.Array(ids), pq.Array(rendered))
+	if err != nil {
+		return 0, err
+	}
+	return ids[len(ids)-1], nil
+}
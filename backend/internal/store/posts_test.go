@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	publishAt := time.Now().Add(time.Hour)
+
+	t.Run("should keep the new version as a revision", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		rec.results["UPDATE posts"] = [][]driver.Value{{"Title", "Content", PostScheduled, "2024-05-01T12:00:00Z", int64(3), "2024-05-01T10:00:00Z"}}
+		s := &PostsStore{db: db}
+
+		post := &Post{Id: 10, Version: 2}
+		if err := s.Schedule(context.Background(), post, &publishAt, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		checkQueries(t, rec.queries(), []string{"BEGIN", "UPDATE posts", "INSERT INTO post_revisions", "COMMIT"})
+		want := []any{int64(10), int64(3), "Title", "Content", int64(1)}
+		if args := rec.statements[2].args; !reflect.DeepEqual(args, want) {
+			t.Errorf("revision written with %v, want %v", args, want)
+		}
+		if post.Status != PostScheduled || post.Version != 3 {
+			t.Errorf("got a %s post at version %d, want a scheduled post at version 3", post.Status, post.Version)
+		}
+	})
+
+	t.Run("should not schedule published posts", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		s := &PostsStore{db: db}
+
+		if err := s.Schedule(context.Background(), &Post{Id: 10}, nil, 1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrNotFound)
+		}
+		checkQueries(t, rec.queries(), []string{"BEGIN", "UPDATE posts", "ROLLBACK"})
+	})
+}
+
+func TestPublish(t *testing.T) {
+	t.Run("should keep the new version as a revision", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		rec.results["UPDATE posts"] = [][]driver.Value{{"Title", "Content", PostPublished, nil, "2024-05-01T12:00:00Z", int64(3), "2024-05-01T12:00:00Z"}}
+		s := &PostsStore{db: db}
+
+		post := &Post{Id: 10, Version: 2}
+		if err := s.Publish(context.Background(), post, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		checkQueries(t, rec.queries(), []string{"BEGIN", "UPDATE posts", "INSERT INTO post_revisions", "COMMIT"})
+		want := []any{int64(10), int64(3), "Title", "Content", int64(1)}
+		if args := rec.statements[2].args; !reflect.DeepEqual(args, want) {
+			t.Errorf("revision written with %v, want %v", args, want)
+		}
+		if post.PublishedAt == nil || *post.PublishedAt != "2024-05-01T12:00:00Z" {
+			t.Errorf("published at %v, want 2024-05-01T12:00:00Z", post.PublishedAt)
+		}
+	})
+
+	t.Run("should keep the creation time", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		s := &PostsStore{db: db}
+
+		_ = s.Publish(context.Background(), &Post{Id: 10}, 1)
+		if update := rec.statements[1].query; strings.Contains(update, "created_at") {
+			t.Errorf("%q changes the creation time", update)
+		}
+	})
+
+	t.Run("should not publish published posts", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		s := &PostsStore{db: db}
+
+		if err := s.Publish(context.Background(), &Post{Id: 10}, 1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrNotFound)
+		}
+		checkQueries(t, rec.queries(), []string{"BEGIN", "UPDATE posts", "ROLLBACK"})
+	})
+}
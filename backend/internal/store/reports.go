@@ -0,0 +1,346 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// What can be reported
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+// Lifecycle of a report: open -> claimed -> resolved or dismissed
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusClaimed   = "claimed"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Actions a moderator can take to close a report
+const (
+	ReportActionDismiss       = "dismiss"
+	ReportActionDeleteContent = "delete_content"
+	ReportActionWarn          = "warn"
+	ReportActionSuspend       = "suspend"
+)
+
+type Report struct {
+	Id             int64   `json:"id"`
+	ReporterId     int64   `json:"reporter_id"`
+	TargetType     string  `json:"target_type"`
+	TargetId       int64   `json:"target_id"`
+	TargetUserId   int64   `json:"target_user_id"` // Author of the reported content, the user itself for user reports
+	Reason         string  `json:"reason"`
+	Details        string  `json:"details"`
+	Status         string  `json:"status"`
+	ClaimedBy      *int64  `json:"claimed_by"`
+	ClaimedAt      *string `json:"claimed_at"`
+	Resolution     *string `json:"resolution"`
+	ResolutionNote string  `json:"resolution_note"`
+	ResolvedBy     *int64  `json:"resolved_by"`
+	ResolvedAt     *string `json:"resolved_at"`
+	CreatedAt      string  `json:"created_at"`
+	TargetReports  int64   `json:"target_reports"` // Unresolved reports about the same content, this one included
+}
+
+// Filters of the moderation queue
+type ReportQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	Status     string `json:"status" validate:"omitempty,oneof=open claimed resolved dismissed"`
+	TargetType string `json:"target_type" validate:"omitempty,oneof=post comment user"`
+	Reason     string `json:"reason" validate:"omitempty,max=30"`
+	ClaimedBy  int64  `json:"claimed_by" validate:"gte=0"` // Only reports claimed by this moderator
+	Unclaimed  bool   `json:"unclaimed"`
+}
+
+func (rq ReportQuery) Parse(r *http.Request) (ReportQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return rq, err
+		}
+		rq.Limit = l
+	}
+
+	if offset := qs.Get("offset"); offset != "" {
+		ofs, err := strconv.Atoi(offset)
+		if err != nil {
+			return rq, err
+		}
+		rq.Offset = ofs
+	}
+
+	if status := qs.Get("status"); status != "" {
+		rq.Status = status
+	}
+	if targetType := qs.Get("target_type"); targetType != "" {
+		rq.TargetType = targetType
+	}
+	if reason := qs.Get("reason"); reason != "" {
+		rq.Reason = reason
+	}
+
+	if claimedBy := qs.Get("claimed_by"); claimedBy != "" {
+		id, err := strconv.ParseInt(claimedBy, 10, 64)
+		if err != nil {
+			return rq, err
+		}
+		rq.ClaimedBy = id
+	}
+
+	if unclaimed := qs.Get("unclaimed"); unclaimed != "" {
+		u, err := strconv.ParseBool(unclaimed)
+		if err != nil {
+			return rq, err
+		}
+		rq.Unclaimed = u
+	}
+
+	return rq, nil
+}
+
+type ReportsStore struct {
+	db *sql.DB
+}
+
+const reportColumns = `
+	r.id, r.reporter_id, r.target_type, r.target_id, r.target_user_id, r.reason, r.details, r.status,
+	r.claimed_by, r.claimed_at, r.resolution, r.resolution_note, r.resolved_by, r.resolved_at, r.created_at,
+	(SELECT COUNT(*) FROM reports o
+		WHERE o.target_type = r.target_type AND o.target_id = r.target_id AND o.status IN ('open', 'claimed'))
+`
+
+func scanReport(row rowScanner, report *Report) error {
+	return row.Scan(
+		&report.Id,
+		&report.ReporterId,
+		&report.TargetType,
+		&report.TargetId,
+		&report.TargetUserId,
+		&report.Reason,
+		&report.Details,
+		&report.Status,
+		&report.ClaimedBy,
+		&report.ClaimedAt,
+		&report.Resolution,
+		&report.ResolutionNote,
+		&report.ResolvedBy,
+		&report.ResolvedAt,
+		&report.CreatedAt,
+		&report.TargetReports,
+	)
+}
+
+// Files a report, a reporter can only have one unresolved report about the same content
+func (s *ReportsStore) Create(ctx context.Context, report *Report) error {
+	query := `
+		INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		report.ReporterId,
+		report.TargetType,
+		report.TargetId,
+		report.TargetUserId,
+		report.Reason,
+		report.Details,
+	).Scan(
+		&report.Id,
+		&report.Status,
+		&report.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ReportsStore) GetById(ctx context.Context, reportId int64) (*Report, error) {
+	query := `SELECT ` + reportColumns + ` FROM reports r WHERE r.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	report := &Report{}
+	if err := scanReport(s.db.QueryRowContext(ctx, query, reportId), report); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// Returns the moderation queue, the oldest reports first so none of them waits forever
+func (s *ReportsStore) GetQueue(ctx context.Context, rq ReportQuery) ([]Report, error) {
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports r
+		WHERE ($3 = '' AND r.status IN ('open', 'claimed') OR r.status = $3)
+			AND ($4 = '' OR r.target_type = $4)
+			AND ($5 = '' OR r.reason = $5)
+			AND ($6 = 0 OR r.claimed_by = $6)
+			AND (NOT $7 OR r.claimed_by IS NULL)
+		ORDER BY r.created_at, r.id
+		LIMIT $1 OFFSET $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		rq.Limit,
+		rq.Offset,
+		rq.Status,
+		rq.TargetType,
+		rq.Reason,
+		rq.ClaimedBy,
+		rq.Unclaimed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var report Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// Assigns an unresolved report to the moderator. A report claimed by someone else is a conflict
+// unless force is set, which lets admins take over abandoned claims.
+func (s *ReportsStore) Claim(ctx context.Context, reportId int64, moderatorId int64, force bool) error {
+	query := `
+		UPDATE reports
+		SET status = 'claimed', claimed_by = $2, claimed_at = NOW()
+		WHERE id = $1 AND status IN ('open', 'claimed')
+			AND (claimed_by IS NULL OR claimed_by = $2 OR $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, reportId, moderatorId, force)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return s.claimConflict(ctx, reportId)
+	}
+	return nil
+}
+
+// Closes the report and every other unresolved report about the same content with the same
+// outcome. It returns the reports it closed, so their reporters can be told.
+func (s *ReportsStore) Resolve(ctx context.Context, report *Report) ([]Report, error) {
+	status := ReportStatusResolved
+	if report.Resolution != nil && *report.Resolution == ReportActionDismiss {
+		status = ReportStatusDismissed
+	}
+
+	var closed []Report
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		// The report itself must still be open and not claimed by another moderator
+		err := tx.QueryRowContext(ctx, `
+			UPDATE reports
+			SET status = $2, resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW(),
+				claimed_by = COALESCE(claimed_by, $5), claimed_at = COALESCE(claimed_at, NOW())
+			WHERE id = $1 AND status IN ('open', 'claimed') AND (claimed_by IS NULL OR claimed_by = $5)
+			RETURNING status, resolved_at
+		`,
+			report.Id,
+			status,
+			report.Resolution,
+			report.ResolutionNote,
+			report.ResolvedBy,
+		).Scan(&report.Status, &report.ResolvedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrConflict
+			}
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE reports
+			SET status = $3, resolution = $4, resolution_note = $5, resolved_by = $6, resolved_at = NOW()
+			WHERE target_type = $1 AND target_id = $2 AND status IN ('open', 'claimed')
+			RETURNING id, reporter_id, target_type, target_id, reason
+		`,
+			report.TargetType,
+			report.TargetId,
+			status,
+			report.Resolution,
+			report.ResolutionNote,
+			report.ResolvedBy,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		closed = []Report{*report}
+		for rows.Next() {
+			var r Report
+			if err := rows.Scan(&r.Id, &r.ReporterId, &r.TargetType, &r.TargetId, &r.Reason); err != nil {
+				return err
+			}
+			closed = append(closed, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+/* Helper Functions */
+
+// Tells a missing report from one which can not be claimed
+func (s *ReportsStore) claimConflict(ctx context.Context, reportId int64) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM reports WHERE id = $1)`, reportId).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrConflict
+}
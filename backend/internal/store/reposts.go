@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// A post the user shared into the feeds of their followers
+type Repost struct {
+	UserId    int64  `json:"user_id"`
+	PostId    int64  `json:"post_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// How often a post was shared, as the viewer sees it
+type RepostStats struct {
+	Reposts    int
+	Quotes     int      // Quotes which are visible to everyone, deleted, held, unpublished and restricted ones do not count
+	Reposted   bool     // Whether the viewer reposted the post
+	RepostedBy []string // Usernames of the users the viewer follows who reposted the post, the latest first
+}
+
+// The post a quote embeds, as the viewer sees it. When the quoted post was deleted or the viewer
+// may not see it anymore only its id is left.
+type QuotedPost struct {
+	Id        int64   `json:"id"`
+	Available bool    `json:"available"`
+	UserId    *int64  `json:"user_id,omitempty"`
+	Username  string  `json:"username,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	Content   string  `json:"content,omitempty"`
+	CreatedAt *string `json:"created_at,omitempty"`
+}
+
+type RepostsStore struct {
+	db *sql.DB
+}
+
+func (s *RepostsStore) Create(ctx context.Context, repost *Repost) error {
+	query := `
+		INSERT INTO reposts (user_id, post_id)
+		VALUES ($1, $2)
+		RETURNING created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, repost.UserId, repost.PostId).Scan(&repost.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *RepostsStore) Delete(ctx context.Context, userId int64, postId int64) error {
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, postId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Returns the repost and quote counts of the posts, with what the viewer has to do with them
+func (s *RepostsStore) GetStats(ctx context.Context, viewerId int64, postIds []int64) (map[int64]RepostStats, error) {
+	stats := make(map[int64]RepostStats)
+	if len(postIds) == 0 {
+		return stats, nil
+	}
+
+	query := `
+		SELECT
+			p.id,
+			(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id),
+			(
+				SELECT COUNT(*) FROM posts q
+				WHERE q.quote_of = p.id AND q.deleted_at IS NULL AND q.held_at IS NULL AND q.status = 'published'
+					AND q.visibility = 'public'
+			),
+			EXISTS (SELECT 1 FROM reposts r WHERE r.post_id = p.id AND r.user_id = $1),
+			ARRAY(
+				SELECT u.username
+				FROM reposts r
+				JOIN users u ON u.id = r.user_id
+				WHERE r.post_id = p.id AND r.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+				ORDER BY r.created_at DESC, u.username
+			)
+		FROM posts p
+		WHERE p.id = ANY($2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postId int64
+		var st RepostStats
+		if err := rows.Scan(&postId, &st.Reposts, &st.Quotes, &st.Reposted, pq.Array(&st.RepostedBy)); err != nil {
+			return nil, err
+		}
+		stats[postId] = st
+	}
+	return stats, rows.Err()
+}
+
+// Returns the posts quoted by the provided posts, keyed by the id of the quoting post. Posts which
+// quote nothing, or a post which was purged since, are left out.
+func (s *RepostsStore) GetQuoted(ctx context.Context, viewerId int64, postIds []int64) (map[int64]QuotedPost, error) {
+	quoted := make(map[int64]QuotedPost)
+	if len(postIds) == 0 {
+		return quoted, nil
+	}
+
+	query := `
+		SELECT
+			p.id, q.id,
+			q.deleted_at IS NULL AND q.status = 'published' AND (
+				q.user_id = $1
+				OR (
+					q.held_at IS NULL
+					AND (
+						NOT u.is_private
+						OR EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $1)
+					)
+					AND ` + audienceCondition("q", "$1") + `
+					AND NOT ` + blockedCondition("q.user_id", "$1") + `
+				)
+			),
+			q.user_id, u.username, q.title, q.content, q.created_at
+		FROM posts p
+		JOIN posts q ON q.id = p.quote_of
+		JOIN users u ON u.id = q.user_id
+		WHERE p.id = ANY($2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postId, userId int64
+		var q QuotedPost
+		var createdAt string
+		err := rows.Scan(&postId, &q.Id, &q.Available, &userId, &q.Username, &q.Title, &q.Content, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if !q.Available {
+			q = QuotedPost{Id: q.Id}
+		} else {
+			q.UserId = &userId
+			q.CreatedAt = &createdAt
+		}
+		quoted[postId] = q
+	}
+	return quoted, rows.Err()
+}
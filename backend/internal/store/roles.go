@@ -41,7 +41,7 @@ func (s *RolesStore) GetByName(ctx context.Context, name string) (*Role, error)
 
 	if err != nil {
 		switch {
-		case errors.Is(err, ErrNotFound):
+		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
 		default:
 			return nil, err
@@ -10,6 +10,7 @@ import (
 var (
 	ErrNotFound          = errors.New("record not found")
 	ErrConflict          = errors.New("resource already exists")
+	ErrEditConflict      = errors.New("edit conflict, the resource was modified by another request")
 	QueryTimeoutduration = time.Second * 5
 )
 
@@ -22,9 +23,57 @@ type Storage struct {
 		Create(context.Context, *Post) error
 		GetById(context.Context, int64) (*Post, error)
 		Delete(context.Context, int64) error
-		Update(context.Context, *Post) error
+		SoftDelete(context.Context, int64, int64) error
+		GetDeletedById(context.Context, int64) (*Post, error)
+		Restore(context.Context, int64) error
+		GetTrash(context.Context, int64, PaginatedFeedQuery) ([]Post, error)
+		GetPurgeableIds(context.Context, time.Time, int) ([]int64, error)
+		Purge(context.Context, []int64) ([]int64, error)
+		GetUnpublished(context.Context, int64, string, PaginatedFeedQuery) ([]Post, error)
+		Schedule(context.Context, *Post, *time.Time, int64) error
+		Publish(context.Context, *Post, int64) error
+		GetDueIds(context.Context, time.Time, int) ([]int64, error)
+		Update(context.Context, *Post, int64) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
-		GetPostsByUserId(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetRankedFeed(context.Context, int64, PaginatedFeedQuery, FeedWeights) ([]PostWithMetaData, error)
+		GetTimelineEntries(context.Context, int64, int) ([]TimelineEntry, error)
+		GetTimelineEntriesOf(context.Context, int64, []int64, int) ([]TimelineEntry, error)
+		GetFeedByIds(context.Context, int64, []int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
+		MarkSeen(context.Context, int64, []int64) error
+		GetPostsByUserId(context.Context, int64, int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
+		RenderMissing(context.Context, int64, int) (int64, error)
+	}
+	Reposts interface {
+		Create(context.Context, *Repost) error
+		Delete(context.Context, int64, int64) error
+		GetStats(context.Context, int64, []int64) (map[int64]RepostStats, error)
+		GetQuoted(context.Context, int64, []int64) (map[int64]QuotedPost, error)
+	}
+	Polls interface {
+		Create(context.Context, *Poll) error
+		GetByPostIds(context.Context, int64, []int64) (map[int64]*Poll, error)
+		Vote(context.Context, int64, int64, []int64) error
+	}
+	Bookmarks interface {
+		Add(context.Context, *Bookmark) error
+		Remove(context.Context, int64, int64) error
+		GetByUserId(context.Context, int64, *int64, PaginatedFeedQuery) ([]Bookmark, error)
+		GetBookmarkedIds(context.Context, int64, []int64) (map[int64]bool, error)
+		CreateFolder(context.Context, *BookmarkFolder) error
+		GetFolders(context.Context, int64) ([]BookmarkFolder, error)
+		DeleteFolder(context.Context, int64, int64) error
+	}
+	Tags interface {
+		GetPosts(context.Context, string, int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
+	}
+	Mentions interface {
+		Sync(context.Context, int64, int64, *int64, []string) ([]int64, error)
+		GetByUserId(context.Context, int64, int64, PaginatedFeedQuery) ([]Mention, error)
+	}
+	Trending interface {
+		Refresh(context.Context, TrendingWindow, int, int) error
+		GetTags(context.Context, string, int) ([]TrendingTag, error)
+		GetPosts(context.Context, string, int64, int) ([]PostWithMetaData, error)
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *User) error
@@ -33,31 +82,151 @@ type Storage struct {
 		GetByEmail(context.Context, string) (*User, error)
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
 		Activate(context.Context, string) error
+		UpdateProfile(context.Context, *User) error
+		Suspend(context.Context, int64, time.Time) error
 		Delete(context.Context, int64) error
 	}
 	Comments interface {
 		GetById(context.Context, int64) (*Comment, error)
 		Create(context.Context, *Comment) error
 		Delete(context.Context, int64) error
+		SoftDelete(context.Context, int64, int64) error
+		GetDeletedById(context.Context, int64) (*Comment, error)
+		Restore(context.Context, int64) error
+		GetTrash(context.Context, int64, PaginatedFeedQuery) ([]Comment, error)
+		PurgeDeleted(context.Context, time.Time) (int64, error)
 		Update(context.Context, *Comment) error
-		GetByPostId(context.Context, int64) ([]Comment, error)
+		GetByPostId(context.Context, int64, int64) ([]Comment, error)
+		RenderMissing(context.Context, int64, int) (int64, error)
 	}
 	Followers interface {
-		Follow(context.Context, int64, int64) error
+		Follow(context.Context, int64, int64) (bool, error)
 		Unfollow(context.Context, int64, int64) error
 		GetFollowedUsersById(context.Context, int64) ([]FollowedUserDetails, error)
+		GetFollowers(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		GetFollowing(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		GetMutuals(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		GetCounts(context.Context, int64) (*FollowCounts, error)
+		GetFollowerIds(context.Context, int64) ([]int64, error)
+		GetRelationship(context.Context, int64, int64) (*Relationship, error)
+		GetRequests(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		ApproveRequest(context.Context, int64, int64) error
+		RejectRequest(context.Context, int64, int64) error
+		ApproveAllRequests(context.Context, int64) error
+	}
+	Blocks interface {
+		Block(context.Context, int64, int64) error
+		Unblock(context.Context, int64, int64) error
+		GetBlocked(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		IsBlocked(context.Context, int64, int64) (bool, error)
+	}
+	Mutes interface {
+		Mute(context.Context, int64, int64) error
+		Unmute(context.Context, int64, int64) error
+		GetMuted(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		GetWords(context.Context, int64) ([]MutedWord, error)
+		AddWord(context.Context, *MutedWord) error
+		DeleteWord(context.Context, int64, int64) error
+	}
+	CloseFriends interface {
+		Add(context.Context, int64, int64) error
+		Remove(context.Context, int64, int64) error
+		GetByUserId(context.Context, int64, PaginatedFeedQuery) ([]FollowConnection, error)
+		GetIds(context.Context, int64) ([]int64, error)
+		IsCloseFriend(context.Context, int64, int64) (bool, error)
+	}
+	Reports interface {
+		Create(context.Context, *Report) error
+		GetById(context.Context, int64) (*Report, error)
+		GetQueue(context.Context, ReportQuery) ([]Report, error)
+		Claim(context.Context, int64, int64, bool) error
+		Resolve(context.Context, *Report) ([]Report, error)
+	}
+	Moderation interface {
+		GetRules(context.Context) ([]ModerationRule, error)
+		CreateRule(context.Context, *ModerationRule) error
+		DeleteRule(context.Context, int64) error
+		GetRecentContent(context.Context, int64, string, int64, time.Time, int) ([]string, error)
+		RecordDecision(context.Context, *ModerationDecision) error
+		GetDecisionById(context.Context, int64) (*ModerationDecision, error)
+		GetDecisions(context.Context, DecisionQuery) ([]ModerationDecision, error)
+		ReviewDecision(context.Context, *ModerationDecision) error
+	}
+	Notifications interface {
+		Create(context.Context, ...*Notification) error
+		GetByUserId(context.Context, int64, bool, PaginatedFeedQuery) ([]Notification, error)
+		MarkRead(context.Context, int64, int64) error
+		MarkAllRead(context.Context, int64) error
+	}
+	PostRevisions interface {
+		GetByPostId(context.Context, int64) ([]PostRevision, error)
+		GetByVersion(context.Context, int64, int64) (*PostRevision, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Media interface {
+		Create(context.Context, *Media) error
+		CreateFromDuplicate(context.Context, *Media, *Media) error
+		GetById(context.Context, int64) (*Media, error)
+		GetReadyByContentHash(context.Context, string) (*Media, error)
+		CountByContentHash(context.Context, string) (int, error)
+		GetByPostIds(context.Context, []int64) (map[int64][]Media, error)
+		ClaimPending(context.Context, int64) (*Media, error)
+		CompleteProcessing(context.Context, *Media) error
+		FailProcessing(context.Context, int64, string) error
+		GetPendingIds(context.Context, bool) ([]int64, error)
+		AttachToPost(context.Context, int64, int64, []int64) error
+		UpdateAltText(context.Context, *Media) error
+		Delete(context.Context, int64) error
+	}
+	Uploads interface {
+		Create(context.Context, *Upload) error
+		GetById(context.Context, string) (*Upload, error)
+		Lock(context.Context, string, time.Duration) error
+		Unlock(context.Context, string) error
+		UpdateOffset(context.Context, *Upload, int64) error
+		Complete(context.Context, *Upload) error
+		Delete(context.Context, string) error
+		DeleteExpired(context.Context, time.Time) ([]string, error)
+		GetUsedStorage(context.Context, int64) (int64, error)
+	}
+	Exports interface {
+		Create(context.Context, *DataExport) error
+		GetById(context.Context, int64) (*DataExport, error)
+		GetByUserId(context.Context, int64) ([]DataExport, error)
+		ClaimPending(context.Context, int64) (*DataExport, error)
+		Complete(context.Context, *DataExport) error
+		Fail(context.Context, int64, string) error
+		GetPendingIds(context.Context, bool) ([]int64, error)
+		DeleteExpired(context.Context, time.Time) ([]string, error)
+		GetUserData(context.Context, int64) (*UserData, error)
+	}
 }
 
 func NewPostgresStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostsStore{db},
-		Users:     &UsersStore{db},
-		Comments:  &CommentsStore{db},
-		Followers: &FollowersStore{db},
+		Posts:         &PostsStore{db},
+		Reposts:       &RepostsStore{db},
+		Polls:         &PollsStore{db},
+		Bookmarks:     &BookmarksStore{db},
+		Tags:          &TagsStore{db},
+		Mentions:      &MentionsStore{db},
+		Trending:      &TrendingStore{db},
+		Users:         &UsersStore{db},
+		Comments:      &CommentsStore{db},
+		Followers:     &FollowersStore{db},
+		Blocks:        &BlocksStore{db},
+		Mutes:         &MutesStore{db},
+		CloseFriends:  &CloseFriendsStore{db},
+		Reports:       &ReportsStore{db},
+		Moderation:    &ModerationStore{db},
+		Notifications: &NotificationsStore{db},
+		Roles:         &RolesStore{db},
+		PostRevisions: &PostRevisionsStore{db},
+		Media:         &MediaStore{db},
+		Uploads:       &UploadsStore{db},
+		Exports:       &ExportsStore{db},
 	}
 }
 
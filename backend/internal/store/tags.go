@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type TagsStore struct {
+	db *sql.DB
+}
+
+// Returns the posts with the tag as the viewer sees them, with the same filters as the feed: posts
+// of private accounts the viewer does not follow, of blocked and muted users, posts containing
+// muted words and posts held for review are left out
+func (s *TagsStore) GetPosts(ctx context.Context, tag string, viewerId int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.held_at IS NULL)
+		FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.id
+		JOIN posts p ON p.id = pt.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE t.name = $1 AND p.deleted_at IS NULL AND p.status = 'published'
+			AND (p.title ILIKE '%' || $5 || '%' OR p.content ILIKE '%' || $5 || '%')
+			AND (
+				p.user_id = $2
+				OR (
+					p.held_at IS NULL
+					AND (
+						NOT u.is_private
+						OR EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $2)
+					)
+					AND ` + audienceCondition("p", "$2") + `
+					AND NOT ` + blockedCondition("p.user_id", "$2") + `
+					AND NOT ` + mutedCondition("$2", "p.user_id") + `
+					AND NOT ` + mutedWordCondition("$2", "p.title", "p.content", "array_to_string(p.tags, ' ')") + `
+				)
+			)
+		ORDER BY p.published_at ` + fq.Sort + `, p.id ` + fq.Sort + `
+		LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tag, viewerId, fq.Limit, fq.Offset, fq.Search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetaData{}
+	for rows.Next() {
+		var p PostWithMetaData
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.PublishedAt,
+			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
+			pq.Array(&p.Tags),
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+/* Helper Functions */
+
+// Links the post to exactly the provided tags, creating the tags which do not exist yet.
+// The tags must be normalised already.
+func syncPostTags(ctx context.Context, tx *sql.Tx, postId int64, tags []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tags (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING
+	`, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM post_tags
+		WHERE post_id = $1 AND tag_id NOT IN (SELECT id FROM tags WHERE name = ANY($2))
+	`, postId, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_tags (post_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING
+	`, postId, pq.Array(tags))
+	return err
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A sliding window trending is computed over
+type TrendingWindow struct {
+	Name     string        // How clients ask for it, like "24h"
+	Span     time.Duration // Only posts created and comments written within it count
+	HalfLife time.Duration // Age at which a post or comment counts half
+}
+
+type TrendingTag struct {
+	Tag     string  `json:"tag"`
+	Rank    int     `json:"rank"`
+	Score   float64 `json:"score"`
+	Posts   int     `json:"posts"` // Posts with the tag which were counted
+	Authors int     `json:"authors"`
+}
+
+type TrendingStore struct {
+	db *sql.DB
+}
+
+// Recomputes the trending posts and tags of the window and replaces the stored ones.
+//
+// A post scores with the comments of other users in the window. Each commenter counts once, with
+// the weight of their latest comment halving every half-life, and the sum halves again with the
+// age of the post, so fresh posts with a lot of fresh conversation come first. Tags score with the
+// posts using them, which count one plus the engagement of the post. At most authorCap posts of the
+// same author count, for the ranking of posts and for each tag, so one account can not flood
+// either. Content of private accounts, posts which are not public and held content never trend.
+func (s *TrendingStore) Refresh(ctx context.Context, window TrendingWindow, limit int, authorCap int) error {
+	decay := func(column string) string {
+		return fmt.Sprintf("exp(-ln(2) * extract(epoch FROM NOW() - %s) / $3)", column)
+	}
+
+	// Shared by both rankings: the commenters of each post with the time of their latest comment
+	candidates := `
+		WITH engagement AS (
+			SELECT c.post_id, c.user_id, MAX(c.created_at) AS last_at
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.created_at >= $2 AND c.deleted_at IS NULL AND c.held_at IS NULL AND c.user_id <> p.user_id
+			GROUP BY c.post_id, c.user_id
+		), scored AS (
+			SELECT p.id, p.user_id,
+				COALESCE(SUM(` + decay("e.last_at") + `), 0) AS engagement,
+				` + decay("p.published_at") + ` AS freshness
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			LEFT JOIN engagement e ON e.post_id = p.id
+			WHERE p.published_at >= $2 AND p.deleted_at IS NULL AND p.held_at IS NULL AND p.status = 'published'
+				AND p.visibility = 'public' AND NOT u.is_private
+			GROUP BY p.id, p.user_id, p.published_at
+		)
+	`
+
+	postsQuery := candidates + `, capped AS (
+			SELECT id, engagement * freshness AS score,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY engagement * freshness DESC, id DESC) AS n
+			FROM scored
+			WHERE engagement > 0
+		)
+		INSERT INTO trending_posts (time_window, post_id, rank, score)
+		SELECT $1, id, ROW_NUMBER() OVER (ORDER BY score DESC, id DESC), score
+		FROM capped
+		WHERE n <= $5
+		ORDER BY score DESC, id DESC
+		LIMIT $4
+	`
+
+	// A tag used by a single author is not trending, however much that author posts
+	tagsQuery := candidates + `, tagged AS (
+			SELECT pt.tag_id, s.user_id, (1 + s.engagement) * s.freshness AS score,
+				ROW_NUMBER() OVER (PARTITION BY pt.tag_id, s.user_id ORDER BY (1 + s.engagement) * s.freshness DESC) AS n
+			FROM scored s
+			JOIN post_tags pt ON pt.post_id = s.id
+		), totals AS (
+			SELECT tag_id, SUM(score) AS score, COUNT(*) AS posts, COUNT(DISTINCT user_id) AS authors
+			FROM tagged
+			WHERE n <= $5
+			GROUP BY tag_id
+			HAVING COUNT(DISTINCT user_id) > 1
+		)
+		INSERT INTO trending_tags (time_window, tag_id, rank, score, posts, authors)
+		SELECT $1, tag_id, ROW_NUMBER() OVER (ORDER BY score DESC, tag_id), score, posts, authors
+		FROM totals
+		ORDER BY score DESC, tag_id
+		LIMIT $4
+	`
+
+	since := time.Now().Add(-window.Span)
+	halfLife := window.HalfLife.Seconds()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+		defer cancel()
+
+		for _, query := range []string{
+			`DELETE FROM trending_posts WHERE time_window = $1`,
+			`DELETE FROM trending_tags WHERE time_window = $1`,
+		} {
+			if _, err := tx.ExecContext(ctx, query, window.Name); err != nil {
+				return err
+			}
+		}
+
+		for _, query := range []string{postsQuery, tagsQuery} {
+			if _, err := tx.ExecContext(ctx, query, window.Name, since, halfLife, limit, authorCap); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the trending tags of the window, the highest ranked first
+func (s *TrendingStore) GetTags(ctx context.Context, window string, limit int) ([]TrendingTag, error) {
+	query := `
+		SELECT t.name, tt.rank, tt.score, tt.posts, tt.authors
+		FROM trending_tags tt
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.time_window = $1
+		ORDER BY tt.rank
+		LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Rank, &t.Score, &t.Posts, &t.Authors); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// Returns the trending posts of the window as the viewer sees them. The ranking is shared by all
+// users, so posts which became invisible since it was computed and posts of users the viewer
+// blocked or muted are filtered out here.
+func (s *TrendingStore) GetPosts(ctx context.Context, window string, viewerId int64, limit int) ([]PostWithMetaData, error) {
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.published_at, p.version, p.edited_at, p.held_at, p.visibility, p.tags,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.held_at IS NULL)
+		FROM trending_posts tp
+		JOIN posts p ON p.id = tp.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE tp.time_window = $1
+			AND p.deleted_at IS NULL AND p.held_at IS NULL AND p.status = 'published'
+			AND ((NOT u.is_private AND p.visibility = 'public') OR u.id = $2)
+			AND NOT ` + blockedCondition("p.user_id", "$2") + `
+			AND NOT ` + mutedCondition("$2", "p.user_id") + `
+			AND NOT ` + mutedWordCondition("$2", "p.title", "p.content", "array_to_string(p.tags, ' ')") + `
+		ORDER BY tp.rank
+		LIMIT $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window, viewerId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetaData{}
+	for rows.Next() {
+		var p PostWithMetaData
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentHtml,
+			&p.CreatedAt,
+			&p.PublishedAt,
+			&p.Version,
+			&p.EditedAt,
+			&p.HeldAt,
+			&p.Visibility,
+			pq.Array(&p.Tags),
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Upload tracks a resumable (tus) upload until all of its bytes arrived and it became a media record
+type Upload struct {
+	Id          string     `json:"id"`
+	UserId      int64      `json:"user_id"`
+	Length      int64      `json:"length"`
+	Offset      int64      `json:"offset"`
+	Filename    string     `json:"filename"`
+	AltText     string     `json:"alt_text"`
+	Metadata    string     `json:"-"` // Raw Upload-Metadata header, echoed back to tus clients
+	MediaId     *int64     `json:"media_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+type UploadsStore struct {
+	db *sql.DB
+}
+
+func (s *UploadsStore) Create(ctx context.Context, upload *Upload) error {
+	query := `
+		INSERT INTO uploads (id, user_id, length, filename, alt_text, metadata, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		upload.Id,
+		upload.UserId,
+		upload.Length,
+		upload.Filename,
+		upload.AltText,
+		upload.Metadata,
+		upload.ExpiresAt,
+	).Scan(
+		&upload.CreatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UploadsStore) GetById(ctx context.Context, uploadId string) (*Upload, error) {
+	query := `
+		SELECT id, user_id, length, upload_offset, filename, alt_text, metadata, media_id, expires_at, created_at, completed_at
+		FROM uploads WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	upload := &Upload{}
+
+	// Scan object must follow the order in which sql query is being executed
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		uploadId,
+	).Scan(
+		&upload.Id,
+		&upload.UserId,
+		&upload.Length,
+		&upload.Offset,
+		&upload.Filename,
+		&upload.AltText,
+		&upload.Metadata,
+		&upload.MediaId,
+		&upload.ExpiresAt,
+		&upload.CreatedAt,
+		&upload.CompletedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// Takes the lease on writing chunks to the upload until it is unlocked or ttl passed, so only one
+// request appends at a time even across API instances. It fails with ErrConflict if another request
+// holds the lease.
+func (s *UploadsStore) Lock(ctx context.Context, uploadId string, ttl time.Duration) error {
+	query := `
+		UPDATE uploads SET locked_until = NOW() + $2 * INTERVAL '1 second'
+		WHERE id = $1 AND (locked_until IS NULL OR locked_until < NOW())
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, uploadId, ttl.Seconds())
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *UploadsStore) Unlock(ctx context.Context, uploadId string) error {
+	query := `UPDATE uploads SET locked_until = NULL WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, uploadId)
+	return err
+}
+
+// Moves the offset forward and extends the expiry, it fails with ErrEditConflict if the offset changed meanwhile
+func (s *UploadsStore) UpdateOffset(ctx context.Context, upload *Upload, previousOffset int64) error {
+	query := `
+		UPDATE uploads SET upload_offset = $1, expires_at = $2
+		WHERE id = $3 AND upload_offset = $4 AND completed_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, upload.Offset, upload.ExpiresAt, upload.Id, previousOffset)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (s *UploadsStore) Complete(ctx context.Context, upload *Upload) error {
+	query := `
+		UPDATE uploads SET media_id = $1, completed_at = NOW()
+		WHERE id = $2
+		RETURNING completed_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, upload.MediaId, upload.Id).Scan(&upload.CompletedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UploadsStore) Delete(ctx context.Context, uploadId string) error {
+	query := `
+		DELETE FROM uploads WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, uploadId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Removes the incomplete uploads which expired before the provided time and returns their ids
+func (s *UploadsStore) DeleteExpired(ctx context.Context, before time.Time) ([]string, error) {
+	query := `
+		DELETE FROM uploads
+		WHERE completed_at IS NULL AND expires_at < $1
+		RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Returns the bytes a user occupies: stored media plus the space reserved by unfinished uploads
+func (s *UploadsStore) GetUsedStorage(ctx context.Context, userId int64) (int64, error) {
+	query := `
+		SELECT
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM media WHERE user_id = $1) +
+			(SELECT COALESCE(SUM(length), 0) FROM uploads WHERE user_id = $1 AND completed_at IS NULL)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	var used int64
+	if err := s.db.QueryRowContext(ctx, query, userId).Scan(&used); err != nil {
+		return 0, err
+	}
+	return used, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLockUpload(t *testing.T) {
+	const uploadId = "6f1c2e8a-3b4d-4c5e-9f60-718293a4b5c6"
+
+	t.Run("should take the lease for the ttl", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		s := &UploadsStore{db: db}
+
+		if err := s.Lock(context.Background(), uploadId, 2*time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		checkQueries(t, rec.queries(), []string{"UPDATE uploads SET locked_until"})
+		if !reflect.DeepEqual(rec.statements[0].args, []any{uploadId, float64(120)}) {
+			t.Errorf("lease taken with %v, want [%s 120]", rec.statements[0].args, uploadId)
+		}
+	})
+
+	t.Run("should not take the lease held by another request", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		rec.unaffected = []string{"UPDATE uploads SET locked_until"}
+		s := &UploadsStore{db: db}
+
+		if err := s.Lock(context.Background(), uploadId, 2*time.Minute); !errors.Is(err, ErrConflict) {
+			t.Fatalf("got error %v, want %v", err, ErrConflict)
+		}
+	})
+}
+
+func TestUpdateUploadOffset(t *testing.T) {
+	t.Run("should not move an offset which changed meanwhile", func(t *testing.T) {
+		db, rec := newRecordingDB()
+		rec.unaffected = []string{"UPDATE uploads SET upload_offset"}
+		s := &UploadsStore{db: db}
+
+		upload := &Upload{Id: "6f1c2e8a-3b4d-4c5e-9f60-718293a4b5c6", Offset: 200}
+		if err := s.UpdateOffset(context.Background(), upload, 100); !errors.Is(err, ErrEditConflict) {
+			t.Fatalf("got error %v, want %v", err, ErrEditConflict)
+		}
+		// The offset the chunk was written at guards the update
+		if args := rec.statements[0].args; args[3] != int64(100) {
+			t.Errorf("offset compared with %v, want 100", args[3])
+		}
+	})
+}
@@ -18,14 +18,25 @@ var (
 )
 
 type User struct {
-	Id        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  Password `json:"-"`
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleId    int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	Id          int64    `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email,omitempty"`
+	Password    Password `json:"-"`
+	DisplayName string   `json:"display_name"`
+	Bio         string   `json:"bio"`
+	Location    string   `json:"location"`
+	Website     string   `json:"website"`
+	AvatarUrl   string   `json:"avatar_url"`
+	IsPrivate   bool     `json:"is_private"` // Only approved followers see the posts of private accounts
+	Version     int64    `json:"version"`    // Used for optimistic concurrency on profile updates
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+	IsActive    bool     `json:"is_active"`
+	RoleId      int64    `json:"role_id"`
+	Role        Role     `json:"role"`
+
+	SuspendedUntil *string       `json:"suspended_until,omitempty"` // Suspended users are turned away by the API until then
+	FollowCounts   *FollowCounts `json:"follow_counts,omitempty"`   // Only loaded on profiles, never cached
 }
 
 type Password struct {
@@ -93,7 +104,8 @@ func (s *UsersStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UsersStore) GetById(ctx context.Context, userId int64) (*User, error) {
 	query := `
-		SELECT u.id, u.username, u.email, u.password, u.created_at, r.id, r.name, r.level, r.description
+		SELECT u.id, u.username, u.email, u.password, u.display_name, u.bio, u.location, u.website, u.avatar_url,
+			u.is_private, u.suspended_until, u.version, u.created_at, u.updated_at, r.id, r.name, r.level, r.description
 		FROM users AS u
 		JOIN roles AS r ON u.role_id = r.id
 		WHERE u.id = $1
@@ -113,7 +125,16 @@ func (s *UsersStore) GetById(ctx context.Context, userId int64) (*User, error) {
 		&user.Username,
 		&user.Email,
 		&user.Password.hash,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Location,
+		&user.Website,
+		&user.AvatarUrl,
+		&user.IsPrivate,
+		&user.SuspendedUntil,
+		&user.Version,
 		&user.CreatedAt,
+		&user.UpdatedAt,
 		&user.Role.Id,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -132,12 +153,14 @@ func (s *UsersStore) GetById(ctx context.Context, userId int64) (*User, error) {
 	return user, nil
 }
 
+// Lists the users whose username matches the search. Emails are only shown to their owner, so
+// they are neither returned nor searched.
 func (s *UsersStore) GetAllUsers(ctx context.Context, fq PaginatedFeedQuery) ([]User, error) {
 	log.Println("fq.Search :", fq.Search)
 	query := `
-		SELECT id, username, email, created_at
+		SELECT id, username, created_at
 		FROM users
-		WHERE (username ILIKE '%' || $3 || '%' OR email ILIKE '%' || $3 || '%')
+		WHERE username ILIKE '%' || $3 || '%'
 		LIMIT $1 OFFSET $2;
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
@@ -156,7 +179,6 @@ func (s *UsersStore) GetAllUsers(ctx context.Context, fq PaginatedFeedQuery) ([]
 		err := rows.Scan(
 			&u.Id,
 			&u.Username,
-			&u.Email,
 			&u.CreatedAt,
 		)
 		if err != nil {
@@ -307,6 +329,70 @@ func (s *UsersStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	return nil
 }
 
+// Updates the editable profile fields of a user, the update only goes through if the version still matches
+func (s *UsersStore) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET display_name = $1, bio = $2, location = $3, website = $4, avatar_url = $5, is_private = $8,
+			version = version + 1, updated_at = NOW()
+		WHERE id = $6 AND version = $7
+		RETURNING version, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		user.DisplayName,
+		user.Bio,
+		user.Location,
+		user.Website,
+		user.AvatarUrl,
+		user.Id,
+		user.Version,
+		user.IsPrivate,
+	).Scan(
+		&user.Version,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keeps the user from signing in until the given time
+func (s *UsersStore) Suspend(ctx context.Context, userId int64, until time.Time) error {
+	query := `
+		UPDATE users SET suspended_until = $2
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutduration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, until)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *UsersStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `
 		DELETE FROM user_invitations 